@@ -0,0 +1,607 @@
+// Package converter is the public entry point for using notion-to-markdown as
+// a library. A Converter fetches pages from Notion through a Client, renders
+// them to Markdown files with front matter and writes them through a Writer.
+package converter
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ManassehZhou/notion-to-markdown/internal/notionclient"
+	"github.com/ManassehZhou/notion-to-markdown/internal/renderer"
+	"github.com/ManassehZhou/notion-to-markdown/internal/writer"
+
+	"github.com/jomei/notionapi"
+)
+
+// Client is the subset of the Notion API used by a Converter. The client
+// returned by NewClient satisfies it; tests can provide a mock.
+type Client interface {
+	FetchPages(databaseID string) ([]notionapi.Page, error)
+	GetChildren(id notionapi.BlockID) ([]notionapi.Block, error)
+}
+
+// BlockGetter is implemented by clients that can fetch a single block. When a
+// Client implements it, expired Notion file URLs are refreshed before their
+// download is retried; the client returned by NewClient does.
+type BlockGetter interface {
+	GetBlock(id notionapi.BlockID) (notionapi.Block, error)
+}
+
+// CommentGetter is implemented by clients that can fetch the comments on a
+// block. When a Client implements it, comments are exported as set by
+// Config.CommentStyle; the client returned by NewClient does.
+type CommentGetter interface {
+	GetComments(id notionapi.BlockID) ([]notionapi.Comment, error)
+}
+
+// ColumnWidthGetter is implemented by clients that can fetch the width
+// ratios of the columns of a column list. When a Client implements it,
+// Config.ColumnTemplate receives each column's width; the client returned
+// by NewClient does.
+type ColumnWidthGetter interface {
+	GetColumnWidths(columnListID notionapi.BlockID) (map[notionapi.BlockID]float64, error)
+}
+
+// PageGetter is implemented by clients that can fetch a single page, which
+// ConvertPageTree requires; the client returned by NewClient does.
+type PageGetter interface {
+	GetPage(pageID string) (notionapi.Page, error)
+}
+
+// DatabaseGetter is implemented by clients that can fetch a database's
+// schema. When a Client implements it, Config.SchemaOrder orders front
+// matter keys like the database's columns and Config.DatabaseTitleType uses
+// the database's title as the default type; the client returned by
+// NewClient does.
+type DatabaseGetter interface {
+	GetDatabase(databaseID string) (*Database, error)
+}
+
+// Database is a database's schema with its property names in column order.
+type Database = notionclient.Database
+
+// Writer persists generated files.
+type Writer interface {
+	WriteFile(filename, content string) error
+}
+
+// Config controls how Notion blocks are rendered to Markdown.
+type Config = renderer.RenderConfig
+
+// Transformer post-processes the rendered Markdown body of a page.
+type Transformer = renderer.Transformer
+
+// AssetFailure describes a file that could not be downloaded.
+type AssetFailure = renderer.AssetFailure
+
+// ErrSkipPage is returned by ConvertPage when a page was intentionally not
+// written, e.g. an empty page with Config.SkipEmptyPages set.
+var ErrSkipPage = renderer.ErrSkipPage
+
+// ClientOption configures a Client created by NewClient.
+type ClientOption = notionclient.Option
+
+// WithPublishedProperty makes the client fetch only the pages whose checkbox
+// property of that name is ticked, e.g. "Published".
+func WithPublishedProperty(name string) ClientOption {
+	return notionclient.WithPublishedProperty(name)
+}
+
+// NewClient creates a Client backed by the Notion API.
+func NewClient(token string, opts ...ClientOption) Client {
+	return notionclient.New(token, opts...)
+}
+
+// NewRecordingClient creates a Client that serves API responses from JSON
+// fixtures in dir. Responses missing from dir are fetched with token and
+// saved; with an empty token nothing is fetched, so a run is reproducible
+// and needs no network access. Responses are recorded by ID only, so
+// options that change them, such as WithPublishedProperty, apply when
+// recording but not when replaying.
+func NewRecordingClient(token, dir string, opts ...ClientOption) Client {
+	var live *notionclient.Service
+	if token != "" {
+		live = notionclient.New(token, opts...)
+	}
+	return notionclient.NewRecorder(dir, live)
+}
+
+// DefaultConfig returns the default rendering configuration.
+func DefaultConfig() *Config {
+	return renderer.DefaultRenderConfig()
+}
+
+// PresetConfig returns the default configuration with the named built-in
+// preset (e.g. "mkdocs") applied.
+func PresetConfig(name string) (*Config, error) {
+	return renderer.PresetConfig(name)
+}
+
+// PresetNames returns the names of the built-in presets.
+func PresetNames() []string {
+	return renderer.PresetNames()
+}
+
+// LoadConfig loads a rendering configuration using the same search path and
+// environment overrides as the CLI. It returns the config and the path used.
+func LoadConfig(path string) (*Config, string) {
+	return renderer.LoadConfigWithFallback(path)
+}
+
+// LoadConfigWithPreset is like LoadConfig but starts from the named preset,
+// so the config file only needs to override individual fields. An empty
+// preset starts from the defaults.
+func LoadConfigWithPreset(path, preset string) (*Config, string, error) {
+	return renderer.LoadConfigWithPreset(path, preset)
+}
+
+// Options configures a Converter.
+type Options struct {
+	// OutDir is the directory generated files are written to.
+	OutDir string
+	// Config controls rendering; nil uses DefaultConfig.
+	Config *Config
+	// Writer persists files; nil writes to disk.
+	Writer Writer
+	// Transformers are applied in order to every rendered page body.
+	Transformers []Transformer
+	// StrictAssets makes a failed file download abort the conversion.
+	StrictAssets bool
+	// Progress receives progress updates; nil reports nothing.
+	Progress ProgressReporter
+	// SingleFile, when set, makes ConvertDatabase write all pages into this
+	// one file (relative to OutDir) instead of one file per page. Links
+	// between pages point at in-document anchors.
+	SingleFile string
+}
+
+// Converter turns Notion pages into Markdown files.
+type Converter struct {
+	client   Client
+	writer   Writer
+	opts     Options
+	renderer *renderer.Renderer
+
+	// pageMap maps normalized page IDs to site-relative paths
+	pageMap map[string]string
+	// pageDatabase maps normalized page IDs to the database they came from
+	pageDatabase map[string]string
+	// databases caches the databases fetched by loadDatabase
+	databases map[string]*Database
+	// sections records the Zola section indexes handled in this run
+	sections map[string]bool
+}
+
+// New constructs a Converter using client to talk to Notion.
+func New(client Client, opts Options) *Converter {
+	c := &Converter{
+		client:       client,
+		writer:       opts.Writer,
+		opts:         opts,
+		pageMap:      map[string]string{},
+		pageDatabase: map[string]string{},
+		databases:    map[string]*Database{},
+		sections:     map[string]bool{},
+	}
+	if c.writer == nil {
+		c.writer = writer.New()
+	}
+	if c.opts.Progress == nil {
+		c.opts.Progress = NopProgress{}
+	}
+	c.renderer = renderer.New(c.resolve, opts.OutDir, opts.Config)
+	if getter, ok := client.(BlockGetter); ok {
+		c.renderer.SetBlockGetter(getter.GetBlock)
+	}
+	if getter, ok := client.(CommentGetter); ok {
+		c.renderer.SetCommentGetter(getter.GetComments)
+	}
+	if getter, ok := client.(ColumnWidthGetter); ok {
+		c.renderer.SetColumnWidthGetter(getter.GetColumnWidths)
+	}
+	return c
+}
+
+// resolve maps a normalized Notion page ID to its site-relative path
+func (c *Converter) resolve(pageID string) string {
+	if path, ok := c.pageMap[pageID]; ok {
+		return path
+	}
+	return ""
+}
+
+// normalizeID removes dashes from a Notion ID to match the resolver format
+func normalizeID(id string) string {
+	return strings.ReplaceAll(id, "-", "")
+}
+
+// AddPages registers pages with the link resolver so that links between them
+// are converted to site-relative paths. ConvertDatabase calls it automatically.
+// Expired pages that are left out of the export resolve to
+// renderer.NotExported, so links to them fall back to plain text.
+func (c *Converter) AddPages(pages []notionapi.Page) {
+	for _, p := range pages {
+		if c.renderer.Expired(p) {
+			c.pageMap[normalizeID(string(p.ID))] = renderer.NotExported
+			continue
+		}
+		c.renderer.AddTranslation(p)
+		if c.opts.SingleFile != "" {
+			// Link to the page's section of the combined document
+			c.pageMap[normalizeID(string(p.ID))] = "#" + c.renderer.GetPageSlug(p)
+			continue
+		}
+		// Get the full path including content type, not just slug
+		c.pageMap[normalizeID(string(p.ID))] = c.renderer.GetPagePath(p)
+	}
+}
+
+// ConvertDatabase fetches every page of a Notion database, converts each to
+// Markdown and writes the files. It returns the number of files generated.
+func (c *Converter) ConvertDatabase(databaseID string) (int, error) {
+	return c.ConvertDatabases([]string{databaseID})
+}
+
+// ConvertDatabases converts the pages of several databases in one run, as
+// ConvertDatabase does for one. Every page is registered with the link
+// resolver before any is converted, so links between pages of different
+// databases resolve. It returns the number of files generated.
+func (c *Converter) ConvertDatabases(databaseIDs []string) (int, error) {
+	var pages []notionapi.Page
+	for _, databaseID := range databaseIDs {
+		slog.Debug("🔄 Fetching pages from Notion database...", "id", databaseID)
+		dbPages, err := c.client.FetchPages(databaseID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to query Notion database: %w", err)
+		}
+
+		if err := c.loadDatabase(databaseID); err != nil {
+			return 0, err
+		}
+
+		c.sortByWeight(dbPages)
+		slog.Debug("📊 Found pages in database", "id", databaseID, "count", len(dbPages))
+
+		// Paths depend on the database's settings, so its pages are
+		// registered while they are loaded
+		slog.Debug("🔗 Building page resolver map...")
+		c.AddPages(dbPages)
+		for _, p := range dbPages {
+			c.pageDatabase[normalizeID(string(p.ID))] = databaseID
+		}
+		// Mentions of the database link to the section listing its pages
+		if path := c.renderer.DatabasePath(databaseID); path != "" && c.opts.SingleFile == "" {
+			c.pageMap[normalizeID(databaseID)] = path
+		}
+		pages = append(pages, dbPages...)
+	}
+	if len(pages) > 100 {
+		slog.Warn("Large number of pages detected, processing may take time", "count", len(pages))
+	}
+
+	slog.Debug("📝 Converting pages to Markdown...")
+	c.opts.Progress.Start(len(pages))
+	defer c.opts.Progress.Finish()
+
+	if c.opts.SingleFile != "" {
+		return c.convertCombined(pages)
+	}
+
+	filesGenerated := 0
+	for i, p := range pages {
+		slog.Debug("Processing page", "current", i+1, "total", len(pages))
+		if err := c.loadPageDatabase(p); err != nil {
+			return filesGenerated, err
+		}
+		path, err := c.ConvertPage(p)
+		if errors.Is(err, ErrSkipPage) {
+			c.opts.Progress.Advance("")
+			continue
+		}
+		if err != nil {
+			return filesGenerated, err
+		}
+		c.opts.Progress.Advance(path)
+		filesGenerated++
+	}
+	return filesGenerated, nil
+}
+
+// sortByWeight orders pages by Config.WeightProperty, lowest first, with
+// pages without a weight last in their original order.
+func (c *Converter) sortByWeight(pages []notionapi.Page) {
+	slices.SortStableFunc(pages, func(a, b notionapi.Page) int {
+		wa, okA := c.renderer.Weight(a)
+		wb, okB := c.renderer.Weight(b)
+		switch {
+		case okA && okB:
+			return cmp.Compare(wa, wb)
+		case okA:
+			return -1
+		case okB:
+			return 1
+		}
+		return 0
+	})
+}
+
+// ConvertPageTree converts a page and, recursively, the child pages found
+// among its top-level blocks, writing each child page inside its parent's
+// directory (e.g. "wiki/setup/index.md"). Pages are laid out like the
+// "pages" type. It returns the number of files generated.
+func (c *Converter) ConvertPageTree(pageID string) (int, error) {
+	getter, ok := c.client.(PageGetter)
+	if !ok {
+		return 0, errors.New("the Notion client cannot fetch single pages")
+	}
+	root, err := getter.GetPage(pageID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch Notion page: %w", err)
+	}
+	c.renderer.SetDefaultType("pages")
+
+	slog.Debug("🌳 Walking the page tree...")
+	pages := []notionapi.Page{root}
+	blocks := map[notionapi.ObjectID][]notionapi.Block{}
+	for i := 0; i < len(pages); i++ {
+		parent := pages[i]
+		children, err := c.client.GetChildren(notionapi.BlockID(parent.ID))
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch page blocks: %w", err)
+		}
+		blocks[parent.ID] = children
+		for _, block := range children {
+			childPage, ok := block.(*notionapi.ChildPageBlock)
+			if !ok {
+				continue
+			}
+			child, err := getter.GetPage(string(childPage.ID))
+			if err != nil {
+				return 0, fmt.Errorf("failed to fetch Notion page: %w", err)
+			}
+			c.renderer.SetParent(child, parent)
+			pages = append(pages, child)
+		}
+	}
+	slog.Debug("📊 Found pages in tree", "count", len(pages))
+
+	c.AddPages(pages)
+	c.opts.Progress.Start(len(pages))
+	defer c.opts.Progress.Finish()
+
+	if c.opts.SingleFile != "" {
+		return c.convertCombined(pages)
+	}
+
+	filesGenerated := 0
+	for _, p := range pages {
+		path, err := c.convertPage(p, blocks[p.ID])
+		if errors.Is(err, ErrSkipPage) {
+			c.opts.Progress.Advance("")
+			continue
+		}
+		if err != nil {
+			return filesGenerated, err
+		}
+		c.opts.Progress.Advance(path)
+		filesGenerated++
+	}
+	return filesGenerated, nil
+}
+
+// loadDatabase fetches the database itself when the configuration uses its
+// schema or title and the client can fetch it, and applies them to the
+// pages rendered next.
+func (c *Converter) loadDatabase(databaseID string) error {
+	getter, ok := c.client.(DatabaseGetter)
+	config := c.opts.Config
+	if !ok || config == nil || !config.SchemaOrder && !config.DatabaseTitleType {
+		return nil
+	}
+	db, ok := c.databases[databaseID]
+	if !ok {
+		var err error
+		if db, err = getter.GetDatabase(databaseID); err != nil {
+			return fmt.Errorf("failed to fetch Notion database: %w", err)
+		}
+		c.databases[databaseID] = db
+	}
+	if config.SchemaOrder {
+		c.renderer.SetPropertyOrder(db.PropertyOrder)
+	}
+	if config.DatabaseTitleType {
+		c.renderer.SetDefaultType(richTextPlain(db.Title))
+	}
+	return nil
+}
+
+// loadPageDatabase loads the settings of the database a page was fetched
+// from by ConvertDatabases, if any.
+func (c *Converter) loadPageDatabase(p notionapi.Page) error {
+	if databaseID, ok := c.pageDatabase[normalizeID(string(p.ID))]; ok {
+		return c.loadDatabase(databaseID)
+	}
+	return nil
+}
+
+// richTextPlain concatenates the plain text of rich text segments
+func richTextPlain(arr []notionapi.RichText) string {
+	var b strings.Builder
+	for _, t := range arr {
+		b.WriteString(t.PlainText)
+	}
+	return b.String()
+}
+
+// ConvertPage fetches the blocks of a single page, converts it to Markdown and
+// writes the file. It returns the path of the written file.
+func (c *Converter) ConvertPage(page notionapi.Page) (string, error) {
+	// Fetch top-level blocks for the page (convert ObjectID to BlockID)
+	blocks, err := c.client.GetChildren(notionapi.BlockID(page.ID))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch page blocks: %w", err)
+	}
+	return c.convertPage(page, blocks)
+}
+
+// convertPage converts a page with its already fetched top-level blocks
+func (c *Converter) convertPage(page notionapi.Page, blocks []notionapi.Block) (string, error) {
+	filename, content, err := c.renderer.RenderPage(page, blocks, c.client.GetChildren, c.resolve, c.opts.Transformers...)
+	if errors.Is(err, ErrSkipPage) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to render page: %w", err)
+	}
+	if err := c.checkAssets(filename); err != nil {
+		return "", err
+	}
+
+	finalPath := c.outputPath(filename)
+
+	if err := c.writer.WriteFile(finalPath, content); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := c.writeSectionIndex(page); err != nil {
+		return "", err
+	}
+
+	slog.Debug("✅ Generated file", "path", finalPath)
+	return finalPath, nil
+}
+
+// writeSectionIndex writes the "_index.md" of the page's Zola section once
+// per run. An existing one is kept, so it can be edited by hand.
+func (c *Converter) writeSectionIndex(page notionapi.Page) error {
+	filename, content, ok := c.renderer.SectionIndex(page)
+	if !ok {
+		return nil
+	}
+	finalPath := c.outputPath(filename)
+	if c.sections[finalPath] {
+		return nil
+	}
+	c.sections[finalPath] = true
+	if _, err := os.Stat(finalPath); err == nil {
+		return nil
+	}
+	if err := c.writer.WriteFile(finalPath, content); err != nil {
+		return fmt.Errorf("failed to write section index: %w", err)
+	}
+	return nil
+}
+
+// convertCombined renders all pages into one document with a "## Title"
+// section and an anchor per page, and writes it to Options.SingleFile. It
+// returns the number of files written: 1, or 0 when every page was skipped.
+func (c *Converter) convertCombined(pages []notionapi.Page) (int, error) {
+	finalPath := c.outputPath(c.opts.SingleFile)
+	sections := make([]string, 0, len(pages))
+	for i, p := range pages {
+		slog.Debug("Processing page", "current", i+1, "total", len(pages))
+		if err := c.loadPageDatabase(p); err != nil {
+			return 0, err
+		}
+		blocks, err := c.client.GetChildren(notionapi.BlockID(p.ID))
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch page blocks: %w", err)
+		}
+		body, err := c.renderer.RenderBody(p, blocks, c.client.GetChildren, c.resolve, c.opts.SingleFile, c.opts.Transformers...)
+		if errors.Is(err, ErrSkipPage) {
+			c.opts.Progress.Advance("")
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to render page: %w", err)
+		}
+		if err := c.checkAssets(c.opts.SingleFile); err != nil {
+			return 0, err
+		}
+
+		section := "<a id=\"" + c.renderer.GetPageSlug(p) + "\"></a>\n\n## " + c.renderer.GetPageTitle(p)
+		if body != "" {
+			section += "\n\n" + body
+		}
+		sections = append(sections, section)
+		c.opts.Progress.Advance(finalPath)
+	}
+
+	if len(sections) == 0 {
+		return 0, nil
+	}
+	if err := c.writer.WriteFile(finalPath, strings.Join(sections, "\n\n")+"\n"); err != nil {
+		return 0, fmt.Errorf("failed to write file: %w", err)
+	}
+	slog.Debug("✅ Generated file", "path", finalPath)
+	return 1, nil
+}
+
+// outputPath places filename inside the output directory
+func (c *Converter) outputPath(filename string) string {
+	// ensure we write into the requested output directory
+	// if filename already contains a top-level path like "posts/..." we keep it,
+	// otherwise prefix with outDir
+	if c.opts.OutDir != "" && !strings.HasPrefix(filename, c.opts.OutDir+"/") {
+		return c.opts.OutDir + "/" + filename
+	}
+	return filename
+}
+
+// checkAssets fails in strict mode once any file could not be downloaded
+func (c *Converter) checkAssets(filename string) error {
+	if c.opts.StrictAssets && len(c.renderer.AssetFailures()) > 0 {
+		return fmt.Errorf("failed to download %d asset(s) for %s", len(c.renderer.AssetFailures()), filename)
+	}
+	return nil
+}
+
+// AssetFailures returns the files that could not be downloaded so far. Those
+// files are linked by their original URL instead.
+func (c *Converter) AssetFailures() []AssetFailure {
+	return c.renderer.AssetFailures()
+}
+
+// CleanOutputDir removes dir and everything in it so an export starts from a
+// pristine directory. It refuses paths whose removal would be catastrophic:
+// the empty path, the filesystem root, the home directory, and the working
+// directory or any of its parents.
+func CleanOutputDir(dir string) error {
+	if err := checkCleanPath(dir); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clean output directory %s: %w", dir, err)
+	}
+	return nil
+}
+
+// checkCleanPath returns an error when dir must not be removed by
+// CleanOutputDir
+func checkCleanPath(dir string) error {
+	if strings.TrimSpace(dir) == "" {
+		return errors.New("refusing to clean an empty output directory path")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory %s: %w", dir, err)
+	}
+	if abs == filepath.Dir(abs) {
+		return fmt.Errorf("refusing to clean the filesystem root %s", dir)
+	}
+	if home, err := os.UserHomeDir(); err == nil && abs == filepath.Clean(home) {
+		return fmt.Errorf("refusing to clean the home directory %s", dir)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(abs, wd); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("refusing to clean %s: it contains the working directory", dir)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,597 @@
+package converter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// mockClient serves pages and blocks from memory
+type mockClient struct {
+	pages    map[string][]notionapi.Page
+	children map[notionapi.BlockID][]notionapi.Block
+}
+
+func (m *mockClient) FetchPages(databaseID string) ([]notionapi.Page, error) {
+	pages, ok := m.pages[databaseID]
+	if !ok {
+		return nil, errors.New("database not found")
+	}
+	return pages, nil
+}
+
+func (m *mockClient) GetChildren(id notionapi.BlockID) ([]notionapi.Block, error) {
+	return m.children[id], nil
+}
+
+// memWriter records written files in memory
+type memWriter struct {
+	files map[string]string
+}
+
+func (w *memWriter) WriteFile(filename, content string) error {
+	w.files[filename] = content
+	return nil
+}
+
+func newPage(id, title string) notionapi.Page {
+	now := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	return notionapi.Page{
+		ID:             notionapi.ObjectID(id),
+		CreatedTime:    now,
+		LastEditedTime: now,
+		Properties: notionapi.Properties{
+			"Title": &notionapi.TitleProperty{
+				Title: []notionapi.RichText{{PlainText: title}},
+			},
+		},
+	}
+}
+
+func textBlock(text, href string) *notionapi.ParagraphBlock {
+	return &notionapi.ParagraphBlock{
+		Paragraph: notionapi.Paragraph{
+			RichText: []notionapi.RichText{{PlainText: text, Href: href}},
+		},
+	}
+}
+
+func TestConverter_ConvertDatabase(t *testing.T) {
+	first := newPage("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "First Post")
+	second := newPage("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "Second Post")
+	client := &mockClient{
+		pages: map[string][]notionapi.Page{"db": {first, second}},
+		children: map[notionapi.BlockID][]notionapi.Block{
+			notionapi.BlockID(first.ID): {
+				textBlock("Hello from the first post", ""),
+				textBlock("next post", "https://www.notion.so/workspace/Second-Post-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"),
+			},
+			notionapi.BlockID(second.ID): {textBlock("Hello from the second post", "")},
+		},
+	}
+	w := &memWriter{files: map[string]string{}}
+
+	conv := New(client, Options{OutDir: "content", Writer: w})
+	count, err := conv.ConvertDatabase("db")
+	if err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 files generated, got %d", count)
+	}
+
+	firstFile, ok := w.files["content/posts/first-post/index.md"]
+	if !ok {
+		t.Fatalf("Expected first post to be written, got files %v", w.files)
+	}
+	if !strings.Contains(firstFile, "title: First Post") {
+		t.Errorf("Expected front matter with title, got:\n%s", firstFile)
+	}
+	if !strings.Contains(firstFile, "Hello from the first post") {
+		t.Errorf("Expected body content, got:\n%s", firstFile)
+	}
+	if !strings.Contains(firstFile, "[next post](/posts/second-post/)") {
+		t.Errorf("Expected internal link to be resolved, got:\n%s", firstFile)
+	}
+	if _, ok := w.files["content/posts/second-post/index.md"]; !ok {
+		t.Errorf("Expected second post to be written, got files %v", w.files)
+	}
+}
+
+func TestConverter_ConvertDatabaseError(t *testing.T) {
+	conv := New(&mockClient{}, Options{Writer: &memWriter{files: map[string]string{}}})
+	if _, err := conv.ConvertDatabase("missing"); err == nil {
+		t.Error("Expected an error for an unknown database")
+	}
+}
+
+func TestConverter_SingleFile(t *testing.T) {
+	first := newPage("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "First Chapter")
+	second := newPage("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "Second Chapter")
+	client := &mockClient{
+		pages: map[string][]notionapi.Page{"db": {first, second}},
+		children: map[notionapi.BlockID][]notionapi.Block{
+			notionapi.BlockID(first.ID): {
+				textBlock("continue with the ", ""),
+				textBlock("second chapter", "https://www.notion.so/Second-Chapter-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"),
+			},
+			notionapi.BlockID(second.ID): {textBlock("The end.", "")},
+		},
+	}
+	w := &memWriter{files: map[string]string{}}
+
+	conv := New(client, Options{OutDir: "content", Writer: w, SingleFile: "book.md"})
+	count, err := conv.ConvertDatabase("db")
+	if err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	if count != 1 || len(w.files) != 1 {
+		t.Fatalf("Expected exactly one file, got %d: %v", count, w.files)
+	}
+
+	book, ok := w.files["content/book.md"]
+	if !ok {
+		t.Fatalf("Expected combined file at content/book.md, got %v", w.files)
+	}
+	expected := "<a id=\"first-chapter\"></a>\n\n## First Chapter\n\n" +
+		"continue with the \n\n[second chapter](#second-chapter)\n\n" +
+		"<a id=\"second-chapter\"></a>\n\n## Second Chapter\n\n" +
+		"The end.\n"
+	if book != expected {
+		t.Errorf("Unexpected combined output.\nExpected:\n%q\nGot:\n%q", expected, book)
+	}
+	if strings.Contains(book, "title:") {
+		t.Error("Expected no per-page front matter in the combined file")
+	}
+}
+
+func TestConverter_SortByWeight(t *testing.T) {
+	weighted := func(id, title string, order float64) notionapi.Page {
+		p := newPage(id, title)
+		p.Properties["Order"] = &notionapi.NumberProperty{Number: order}
+		return p
+	}
+	pages := []notionapi.Page{
+		newPage("cccccccc-cccc-cccc-cccc-cccccccccccc", "Appendix"),
+		weighted("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "Usage", 2),
+		weighted("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "Install", 1),
+	}
+	client := &mockClient{pages: map[string][]notionapi.Page{"db": pages}}
+	w := &memWriter{files: map[string]string{}}
+	config := DefaultConfig()
+	config.WeightProperty = "Order"
+
+	conv := New(client, Options{OutDir: "content", Writer: w, Config: config, SingleFile: "book.md"})
+	if _, err := conv.ConvertDatabase("db"); err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	book := w.files["content/book.md"]
+	install := strings.Index(book, "## Install")
+	usage := strings.Index(book, "## Usage")
+	appendix := strings.Index(book, "## Appendix")
+	if install < 0 || !(install < usage && usage < appendix) {
+		t.Errorf("Expected sections ordered by weight, got:\n%s", book)
+	}
+}
+
+func TestConverter_SkipEmptyPages(t *testing.T) {
+	full := newPage("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "Full Post")
+	empty := newPage("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "Empty Post")
+	client := &mockClient{
+		pages: map[string][]notionapi.Page{"db": {full, empty}},
+		children: map[notionapi.BlockID][]notionapi.Block{
+			notionapi.BlockID(full.ID): {textBlock("Some content", "")},
+		},
+	}
+
+	config := DefaultConfig()
+	config.SkipEmptyPages = true
+	w := &memWriter{files: map[string]string{}}
+	count, err := New(client, Options{OutDir: "content", Config: config, Writer: w}).ConvertDatabase("db")
+	if err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	if count != 1 || len(w.files) != 1 {
+		t.Errorf("Expected only the non-empty page to be written, got %d files: %v", count, w.files)
+	}
+	if _, ok := w.files["content/posts/empty-post/index.md"]; ok {
+		t.Error("Expected empty page to be skipped")
+	}
+
+	// The combined document leaves them out too
+	w = &memWriter{files: map[string]string{}}
+	count, err = New(client, Options{OutDir: "content", Config: config, Writer: w, SingleFile: "book.md"}).ConvertDatabase("db")
+	if err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	if book := w.files["content/book.md"]; count != 1 || strings.Contains(book, "Empty Post") || !strings.Contains(book, "Full Post") {
+		t.Errorf("Expected a combined file without the empty page, got %d files:\n%s", count, book)
+	}
+
+	// Empty pages are written by default
+	w = &memWriter{files: map[string]string{}}
+	count, err = New(client, Options{OutDir: "content", Writer: w}).ConvertDatabase("db")
+	if err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected both pages to be written by default, got %d", count)
+	}
+}
+
+func TestConverter_JekyllPaths(t *testing.T) {
+	post := newPage("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "First Post")
+	client := &mockClient{
+		pages:    map[string][]notionapi.Page{"db": {post}},
+		children: map[notionapi.BlockID][]notionapi.Block{notionapi.BlockID(post.ID): {textBlock("Hi", "")}},
+	}
+	config, err := PresetConfig("jekyll")
+	if err != nil {
+		t.Fatalf("Unexpected error loading preset: %v", err)
+	}
+
+	w := &memWriter{files: map[string]string{}}
+	if _, err := New(client, Options{OutDir: "site", Config: config, Writer: w}).ConvertDatabase("db"); err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	if _, ok := w.files["site/_posts/2025-01-15-first-post.md"]; !ok {
+		t.Errorf("Expected Jekyll post inside the output directory, got %v", w.files)
+	}
+}
+
+func TestConverter_ZolaSections(t *testing.T) {
+	first := newPage("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "First Post")
+	second := newPage("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "Second Post")
+	about := newPage("cccccccc-cccc-cccc-cccc-cccccccccccc", "About")
+	about.Properties["Type"] = &notionapi.SelectProperty{Select: notionapi.Option{Name: "pages"}}
+	client := &mockClient{
+		pages: map[string][]notionapi.Page{"db": {first, second, about}},
+		children: map[notionapi.BlockID][]notionapi.Block{
+			notionapi.BlockID(first.ID):  {textBlock("Hi", "")},
+			notionapi.BlockID(second.ID): {textBlock("Hi", "")},
+			notionapi.BlockID(about.ID):  {textBlock("Hi", "")},
+		},
+	}
+	config, err := PresetConfig("zola")
+	if err != nil {
+		t.Fatalf("Unexpected error loading preset: %v", err)
+	}
+
+	w := &memWriter{files: map[string]string{}}
+	out := t.TempDir()
+	if _, err := New(client, Options{OutDir: out, Config: config, Writer: w}).ConvertDatabase("db"); err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	if _, ok := w.files[out+"/posts/first-post/index.md"]; !ok {
+		t.Errorf("Expected the post as a page bundle, got %v", w.files)
+	}
+	expected := "+++\ntitle = \"posts\"\nsort_by = \"date\"\n+++\n"
+	if got := w.files[out+"/posts/_index.md"]; got != expected {
+		t.Errorf("Expected the posts section index '%s', got '%s'", expected, got)
+	}
+	if len(w.files) != 4 {
+		t.Errorf("Expected three pages and one section index, got %v", w.files)
+	}
+
+	// An existing section index is kept
+	if err := os.MkdirAll(filepath.Join(out, "posts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(out, "posts", "_index.md"), []byte("custom"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w = &memWriter{files: map[string]string{}}
+	if _, err := New(client, Options{OutDir: out, Config: config, Writer: w}).ConvertDatabase("db"); err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	if _, ok := w.files[out+"/posts/_index.md"]; ok {
+		t.Error("Expected the existing section index not to be overwritten")
+	}
+}
+
+func TestCleanOutputDir_RejectsDangerousPaths(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dangerous := []string{"", " ", "/", ".", "..", "./", wd, filepath.Dir(wd)}
+	if home, err := os.UserHomeDir(); err == nil {
+		dangerous = append(dangerous, home)
+	}
+	for _, dir := range dangerous {
+		if err := CleanOutputDir(dir); err == nil {
+			t.Errorf("Expected cleaning %q to be refused", dir)
+		}
+	}
+	if _, err := os.Stat(wd); err != nil {
+		t.Fatalf("Working directory must survive: %v", err)
+	}
+
+	out := filepath.Join(t.TempDir(), "content")
+	if err := os.MkdirAll(filepath.Join(out, "posts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(out, "posts", "stale.md"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CleanOutputDir(out); err != nil {
+		t.Fatalf("Unexpected error cleaning %s: %v", out, err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", out, err)
+	}
+}
+
+func TestConverter_RelativeLinks(t *testing.T) {
+	first := newPage("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "First Post")
+	second := newPage("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "Second Post")
+	client := &mockClient{
+		pages: map[string][]notionapi.Page{"db": {first, second}},
+		children: map[notionapi.BlockID][]notionapi.Block{
+			notionapi.BlockID(first.ID):  {textBlock("next post", "https://www.notion.so/workspace/Second-Post-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")},
+			notionapi.BlockID(second.ID): {textBlock("Hello", "")},
+		},
+	}
+	config := DefaultConfig()
+	config.RelativeLinks = true
+
+	w := &memWriter{files: map[string]string{}}
+	if _, err := New(client, Options{OutDir: "content", Config: config, Writer: w}).ConvertDatabase("db"); err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	firstFile := w.files["content/posts/first-post/index.md"]
+	if !strings.Contains(firstFile, "[next post](../second-post/)") {
+		t.Errorf("Expected a relative link to the second post, got:\n%s", firstFile)
+	}
+}
+
+func TestConverter_ExpiredLinks(t *testing.T) {
+	past := notionapi.Date(time.Date(2020, 6, 30, 0, 0, 0, 0, time.UTC))
+	current := newPage("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "Current Post")
+	expired := newPage("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "Summer Sale")
+	expired.Properties["Expires"] = &notionapi.DateProperty{Date: &notionapi.DateObject{Start: &past}}
+	mention := notionapi.RichText{
+		PlainText: "Summer Sale",
+		Href:      "https://www.notion.so/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		Mention:   &notionapi.Mention{Type: notionapi.MentionTypePage, Page: &notionapi.PageMention{ID: notionapi.ObjectID(expired.ID)}},
+	}
+	client := &mockClient{
+		pages: map[string][]notionapi.Page{"db": {current, expired}},
+		children: map[notionapi.BlockID][]notionapi.Block{
+			notionapi.BlockID(current.ID): {
+				textBlock("the sale", "https://www.notion.so/workspace/Summer-Sale-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"),
+				&notionapi.ParagraphBlock{Paragraph: notionapi.Paragraph{RichText: []notionapi.RichText{mention}}},
+			},
+			notionapi.BlockID(expired.ID): {textBlock("50% off", "")},
+		},
+	}
+
+	for _, relative := range []bool{false, true} {
+		config := DefaultConfig()
+		config.ExpiryProperty = "Expires"
+		config.ExpiredPages = "skip"
+		config.RelativeLinks = relative
+		w := &memWriter{files: map[string]string{}}
+		count, err := New(client, Options{OutDir: "content", Config: config, Writer: w}).ConvertDatabase("db")
+		if err != nil {
+			t.Fatalf("Unexpected error converting database: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected only the current page to be written, got %d files: %v", count, w.files)
+		}
+		got := w.files["content/posts/current-post/index.md"]
+		if strings.Contains(got, "summer-sale") || !strings.Contains(got, "the sale") || !strings.Contains(got, "Summer Sale") {
+			t.Errorf("Expected links to the expired page as plain text (relative %v), got:\n%s", relative, got)
+		}
+	}
+}
+
+func TestConverter_ConvertDatabases(t *testing.T) {
+	post := newPage("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "Release Notes")
+	guide := newPage("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "Upgrade Guide")
+	guide.Properties["Type"] = &notionapi.SelectProperty{Select: notionapi.Option{Name: "docs"}}
+	client := &mockClient{
+		pages: map[string][]notionapi.Page{"blog": {post}, "docs": {guide}},
+		children: map[notionapi.BlockID][]notionapi.Block{
+			notionapi.BlockID(post.ID):  {textBlock("upgrade guide", "https://www.notion.so/Upgrade-Guide-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")},
+			notionapi.BlockID(guide.ID): {textBlock("release notes", "https://www.notion.so/Release-Notes-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")},
+		},
+	}
+	w := &memWriter{files: map[string]string{}}
+
+	count, err := New(client, Options{OutDir: "content", Writer: w}).ConvertDatabases([]string{"blog", "docs"})
+	if err != nil {
+		t.Fatalf("Unexpected error converting databases: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 pages converted, got %d", count)
+	}
+	if got := w.files["content/posts/release-notes/index.md"]; !strings.Contains(got, "[upgrade guide](/docs/upgrade-guide/)") {
+		t.Errorf("Expected a link into the docs database, got:\n%s", got)
+	}
+	if got := w.files["content/docs/upgrade-guide/index.md"]; !strings.Contains(got, "[release notes](/posts/release-notes/)") {
+		t.Errorf("Expected a link into the blog database, got:\n%s", got)
+	}
+
+	// Database mentions link to the section listing the database's pages
+	mention := notionapi.RichText{
+		PlainText: "Docs",
+		Mention:   &notionapi.Mention{Type: notionapi.MentionTypeDatabase, Database: &notionapi.DatabaseMention{ID: "docs"}},
+	}
+	client.children[notionapi.BlockID(post.ID)] = []notionapi.Block{&notionapi.ParagraphBlock{
+		Paragraph: notionapi.Paragraph{RichText: []notionapi.RichText{mention}},
+	}}
+	config := DefaultConfig()
+	config.DatabaseTypes = map[string]string{"docs": "docs"}
+	if _, err := New(client, Options{OutDir: "content", Config: config, Writer: w}).ConvertDatabases([]string{"blog", "docs"}); err != nil {
+		t.Fatalf("Unexpected error converting databases: %v", err)
+	}
+	if got := w.files["content/posts/release-notes/index.md"]; !strings.Contains(got, "[Docs](/docs/)") {
+		t.Errorf("Expected the database mention to link to its section, got:\n%s", got)
+	}
+
+	if _, err := New(client, Options{OutDir: "content", Writer: w}).ConvertDatabases([]string{"blog", "missing"}); err == nil {
+		t.Error("Expected an error for a missing database")
+	}
+}
+
+func TestConverter_Translations(t *testing.T) {
+	translated := func(id, title, lang string) notionapi.Page {
+		p := newPage(id, title)
+		p.Properties["Lang"] = &notionapi.SelectProperty{Select: notionapi.Option{Name: lang}}
+		p.Properties["TranslationKey"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "hello"}}}
+		return p
+	}
+	en := translated("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "Hello", "en")
+	zh := translated("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "你好", "zh")
+	zh.Properties["Slug"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "hello"}}}
+	other := newPage("cccccccc-cccc-cccc-cccc-cccccccccccc", "Unrelated")
+	client := &mockClient{
+		pages: map[string][]notionapi.Page{"db": {en, zh, other}},
+		children: map[notionapi.BlockID][]notionapi.Block{
+			notionapi.BlockID(en.ID):    {textBlock("Hello", "")},
+			notionapi.BlockID(zh.ID):    {textBlock("你好", "")},
+			notionapi.BlockID(other.ID): {textBlock("Text", "")},
+		},
+	}
+	config := DefaultConfig()
+	config.LanguageProperty = "Lang"
+	config.DefaultLanguage = "en"
+	config.TranslationKeyProperty = "TranslationKey"
+	config.TranslationsField = "translations"
+	w := &memWriter{files: map[string]string{}}
+
+	if _, err := New(client, Options{OutDir: "content", Config: config, Writer: w}).ConvertDatabase("db"); err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	enFile := w.files["content/posts/hello/index.en.md"]
+	if !strings.Contains(enFile, "translationKey: hello\n") || !strings.Contains(enFile, "translations:\n    zh: /zh/posts/hello/\n") {
+		t.Errorf("Expected the English page to point at its translation, got:\n%s", enFile)
+	}
+	zhFile := w.files["content/posts/hello/index.zh.md"]
+	if !strings.Contains(zhFile, "translationKey: hello\n") || !strings.Contains(zhFile, "translations:\n    en: /posts/hello/\n") {
+		t.Errorf("Expected the Chinese page to point at its translation, got:\n%s", zhFile)
+	}
+	if otherFile := w.files["content/posts/unrelated/index.md"]; strings.Contains(otherFile, "translation") {
+		t.Errorf("Expected no translations for an unrelated page, got:\n%s", otherFile)
+	}
+}
+
+// schemaClient is a mockClient that also serves a database schema
+type schemaClient struct {
+	mockClient
+	title string
+	order []string
+}
+
+func (m *schemaClient) GetDatabase(databaseID string) (*Database, error) {
+	db := &Database{PropertyOrder: m.order}
+	db.Title = []notionapi.RichText{{PlainText: m.title}}
+	return db, nil
+}
+
+func TestConverter_SchemaOrder(t *testing.T) {
+	post := newPage("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "First Post")
+	post.Properties["Summary"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "Short"}}}
+	post.Properties["Author"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "Jane"}}}
+	client := &schemaClient{
+		mockClient: mockClient{pages: map[string][]notionapi.Page{"db": {post}}},
+		order:      []string{"Title", "Summary", "Author"},
+	}
+
+	config := DefaultConfig()
+	config.SchemaOrder = true
+	w := &memWriter{files: map[string]string{}}
+	if _, err := New(client, Options{OutDir: "content", Config: config, Writer: w}).ConvertDatabase("db"); err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	expected := "---\ntitle: First Post\nSummary: Short\nAuthor: Jane\ndate: \"2025-01-15T10:00:00Z\"\nlastmod: \"2025-01-15T10:00:00Z\"\n---\n"
+	if got := w.files["content/posts/first-post/index.md"]; !strings.HasPrefix(got, expected) {
+		t.Errorf("Expected front matter in column order:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestConverter_DatabaseTitleType(t *testing.T) {
+	first := newPage("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "First Note")
+	second := newPage("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "Second Note")
+	second.Properties["Type"] = &notionapi.SelectProperty{Select: notionapi.Option{Name: "docs"}}
+	client := &schemaClient{
+		mockClient: mockClient{
+			pages: map[string][]notionapi.Page{"db": {first, second}},
+			children: map[notionapi.BlockID][]notionapi.Block{
+				notionapi.BlockID(second.ID): {textBlock("first note", "https://www.notion.so/First-Note-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")},
+			},
+		},
+		title: "Field Notes",
+	}
+
+	config := DefaultConfig()
+	config.DatabaseTitleType = true
+	w := &memWriter{files: map[string]string{}}
+	if _, err := New(client, Options{OutDir: "content", Config: config, Writer: w}).ConvertDatabase("db"); err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+	if _, ok := w.files["content/field-notes/first-note/index.md"]; !ok {
+		t.Errorf("Expected the database title as default section, got %v", w.files)
+	}
+	secondFile, ok := w.files["content/docs/second-note/index.md"]
+	if !ok {
+		t.Fatalf("Expected an explicit type to win, got %v", w.files)
+	}
+	if !strings.Contains(secondFile, "[first note](/field-notes/first-note/)") {
+		t.Errorf("Expected links to use the default section, got:\n%s", secondFile)
+	}
+}
+
+// treeClient is a mockClient that also serves single pages
+type treeClient struct {
+	mockClient
+	byID map[string]notionapi.Page
+}
+
+func (m *treeClient) GetPage(pageID string) (notionapi.Page, error) {
+	page, ok := m.byID[pageID]
+	if !ok {
+		return notionapi.Page{}, errors.New("page not found")
+	}
+	return page, nil
+}
+
+func TestConverter_ConvertPageTree(t *testing.T) {
+	root := newPage("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "Wiki")
+	setup := newPage("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "Setup")
+	linux := newPage("cccccccc-cccc-cccc-cccc-cccccccccccc", "Linux")
+	childPage := func(id notionapi.ObjectID) *notionapi.ChildPageBlock {
+		return &notionapi.ChildPageBlock{BasicBlock: notionapi.BasicBlock{ID: notionapi.BlockID(id)}}
+	}
+	client := &treeClient{
+		mockClient: mockClient{children: map[notionapi.BlockID][]notionapi.Block{
+			notionapi.BlockID(root.ID):  {textBlock("Welcome", ""), childPage(setup.ID)},
+			notionapi.BlockID(setup.ID): {textBlock("see linux", "https://www.notion.so/Linux-cccccccccccccccccccccccccccccccc"), childPage(linux.ID)},
+			notionapi.BlockID(linux.ID): {textBlock("apt install", "")},
+		}},
+		byID: map[string]notionapi.Page{string(root.ID): root, string(setup.ID): setup, string(linux.ID): linux},
+	}
+
+	w := &memWriter{files: map[string]string{}}
+	count, err := New(client, Options{OutDir: "content", Writer: w}).ConvertPageTree(string(root.ID))
+	if err != nil {
+		t.Fatalf("Unexpected error converting page tree: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 files generated, got %d", count)
+	}
+	for _, path := range []string{"content/wiki/index.md", "content/wiki/setup/index.md", "content/wiki/setup/linux/index.md"} {
+		if _, ok := w.files[path]; !ok {
+			t.Errorf("Expected %s to be written, got %v", path, w.files)
+		}
+	}
+	if setupFile := w.files["content/wiki/setup/index.md"]; !strings.Contains(setupFile, "[see linux](/wiki/setup/linux/)") {
+		t.Errorf("Expected links to use nested paths, got:\n%s", setupFile)
+	}
+}
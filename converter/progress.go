@@ -0,0 +1,53 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+)
+
+// ProgressReporter receives progress updates while a Converter works through
+// the pages of a database, so callers can drive their own progress output.
+type ProgressReporter interface {
+	// Start is called once with the total number of pages to convert.
+	Start(total int)
+	// Advance is called after each page has been written to path. path is
+	// empty for pages that were skipped.
+	Advance(path string)
+	// Finish is called once all pages have been processed.
+	Finish()
+}
+
+// NopProgress is a ProgressReporter that ignores all updates.
+type NopProgress struct{}
+
+func (NopProgress) Start(int)      {}
+func (NopProgress) Advance(string) {}
+func (NopProgress) Finish()        {}
+
+// terminalProgress prints a dot per generated file; skipped pages print nothing
+type terminalProgress struct {
+	w        io.Writer
+	advanced bool
+}
+
+// NewTerminalProgress returns a ProgressReporter that prints a dot to w for
+// every generated file and a newline when done.
+func NewTerminalProgress(w io.Writer) ProgressReporter {
+	return &terminalProgress{w: w}
+}
+
+func (p *terminalProgress) Start(int) {}
+
+func (p *terminalProgress) Advance(path string) {
+	if path == "" {
+		return
+	}
+	p.advanced = true
+	fmt.Fprint(p.w, ".")
+}
+
+func (p *terminalProgress) Finish() {
+	if p.advanced {
+		fmt.Fprintln(p.w) // New line after dots
+	}
+}
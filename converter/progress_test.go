@@ -0,0 +1,89 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jomei/notionapi"
+)
+
+// recordingProgress records every progress update it receives
+type recordingProgress struct {
+	events []string
+}
+
+func (p *recordingProgress) Start(total int) {
+	p.events = append(p.events, fmt.Sprintf("start:%d", total))
+}
+
+func (p *recordingProgress) Advance(path string) {
+	p.events = append(p.events, "advance:"+path)
+}
+
+func (p *recordingProgress) Finish() {
+	p.events = append(p.events, "finish")
+}
+
+func TestConverter_ProgressReporter(t *testing.T) {
+	first := newPage("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "First Post")
+	second := newPage("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "Second Post")
+	client := &mockClient{
+		pages:    map[string][]notionapi.Page{"db": {first, second}},
+		children: map[notionapi.BlockID][]notionapi.Block{},
+	}
+	progress := &recordingProgress{}
+
+	conv := New(client, Options{OutDir: "content", Writer: &memWriter{files: map[string]string{}}, Progress: progress})
+	if _, err := conv.ConvertDatabase("db"); err != nil {
+		t.Fatalf("Unexpected error converting database: %v", err)
+	}
+
+	expected := []string{
+		"start:2",
+		"advance:content/posts/first-post/index.md",
+		"advance:content/posts/second-post/index.md",
+		"finish",
+	}
+	if !reflect.DeepEqual(progress.events, expected) {
+		t.Errorf("Expected events %v, got %v", expected, progress.events)
+	}
+}
+
+func TestTerminalProgress(t *testing.T) {
+	var buf bytes.Buffer
+	progress := NewTerminalProgress(&buf)
+	progress.Start(3)
+	for i := 0; i < 3; i++ {
+		progress.Advance("file.md")
+	}
+	progress.Finish()
+
+	if buf.String() != "...\n" {
+		t.Errorf("Expected three dots and a newline, got %q", buf.String())
+	}
+}
+
+func TestTerminalProgress_SkippedPages(t *testing.T) {
+	var buf bytes.Buffer
+	progress := NewTerminalProgress(&buf)
+	progress.Start(3)
+	progress.Advance("")
+	progress.Advance("file.md")
+	progress.Advance("")
+	progress.Finish()
+
+	if buf.String() != ".\n" {
+		t.Errorf("Expected one dot for the written page, got %q", buf.String())
+	}
+
+	buf.Reset()
+	progress = NewTerminalProgress(&buf)
+	progress.Start(1)
+	progress.Advance("")
+	progress.Finish()
+	if buf.String() != "" {
+		t.Errorf("Expected no output when every page was skipped, got %q", buf.String())
+	}
+}
@@ -5,3 +5,5 @@ go 1.25
 require github.com/jomei/notionapi v1.13.3
 
 require gopkg.in/yaml.v3 v3.0.1
+
+require golang.org/x/net v0.46.0
@@ -0,0 +1,138 @@
+package notionclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jomei/notionapi"
+)
+
+// ErrFixtureMissing is returned by a replay-only Recorder for a request it
+// has no recorded response for.
+var ErrFixtureMissing = errors.New("no recorded response")
+
+// Recorder serves API responses from JSON fixtures in a directory, so that
+// runs are reproducible and need no token. A response missing from the
+// directory is fetched through the live Service, when there is one, and
+// saved for later runs.
+type Recorder struct {
+	dir  string
+	live *Service
+}
+
+// NewRecorder returns a Recorder using the fixtures in dir. A nil live
+// Service replays only.
+func NewRecorder(dir string, live *Service) *Recorder {
+	return &Recorder{dir: dir, live: live}
+}
+
+// FetchPages returns the recorded pages of a database.
+func (r *Recorder) FetchPages(databaseID string) ([]notionapi.Page, error) {
+	return fixture(r, "pages", databaseID, func() ([]notionapi.Page, error) {
+		return r.live.FetchPages(databaseID)
+	})
+}
+
+// GetChildren returns the recorded child blocks of a block or page.
+func (r *Recorder) GetChildren(id notionapi.BlockID) ([]notionapi.Block, error) {
+	blocks, err := fixture(r, "children", string(id), func() (notionapi.Blocks, error) {
+		return r.live.GetChildren(id)
+	})
+	return blocks, err
+}
+
+// GetPage returns a recorded page.
+func (r *Recorder) GetPage(pageID string) (notionapi.Page, error) {
+	return fixture(r, "page", pageID, func() (notionapi.Page, error) {
+		return r.live.GetPage(pageID)
+	})
+}
+
+// GetComments returns the recorded comments on a block or page.
+func (r *Recorder) GetComments(id notionapi.BlockID) ([]notionapi.Comment, error) {
+	return fixture(r, "comments", string(id), func() ([]notionapi.Comment, error) {
+		return r.live.GetComments(id)
+	})
+}
+
+// GetBlock returns a recorded block.
+func (r *Recorder) GetBlock(id notionapi.BlockID) (notionapi.Block, error) {
+	// A single block is stored as a list, which the SDK knows how to decode
+	blocks, err := fixture(r, "block", string(id), func() (notionapi.Blocks, error) {
+		block, err := r.live.GetBlock(id)
+		if err != nil {
+			return nil, err
+		}
+		return notionapi.Blocks{block}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) != 1 {
+		return nil, fmt.Errorf("fixture block/%s: want one block, got %d", id, len(blocks))
+	}
+	return blocks[0], nil
+}
+
+// GetDatabase returns a recorded database schema.
+func (r *Recorder) GetDatabase(databaseID string) (*Database, error) {
+	return fixture(r, "database", databaseID, func() (*Database, error) {
+		return r.live.GetDatabase(databaseID)
+	})
+}
+
+// GetColumnWidths returns the recorded column widths of a column list.
+func (r *Recorder) GetColumnWidths(columnListID notionapi.BlockID) (map[notionapi.BlockID]float64, error) {
+	return fixture(r, "columns", string(columnListID), func() (map[notionapi.BlockID]float64, error) {
+		return r.live.GetColumnWidths(columnListID)
+	})
+}
+
+// fixture decodes the response recorded as kind/id, or records the one
+// returned by fetch when there is none yet.
+func fixture[T any](r *Recorder, kind, id string, fetch func() (T, error)) (T, error) {
+	var v T
+	name := filepath.Join(r.dir, kind, fixtureName(id)+".json")
+	data, err := os.ReadFile(name)
+	if err == nil {
+		if err := json.Unmarshal(data, &v); err != nil {
+			return v, fmt.Errorf("fixture %s: %w", name, err)
+		}
+		return v, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return v, err
+	}
+	if r.live == nil {
+		return v, fmt.Errorf("%s/%s: %w", kind, id, ErrFixtureMissing)
+	}
+
+	if v, err = fetch(); err != nil {
+		return v, err
+	}
+	if data, err = json.MarshalIndent(v, "", "  "); err != nil {
+		return v, err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return v, err
+	}
+	return v, os.WriteFile(name, append(data, '\n'), 0o644)
+}
+
+// fixtureName makes an ID safe to use as a file name. Notion IDs are the
+// same with or without dashes, so they are dropped.
+func fixtureName(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '-':
+			return -1
+		case r == '/' || r == '\\' || r == '.':
+			return '_'
+		}
+		return r
+	}, id)
+}
@@ -0,0 +1,82 @@
+package notionclient
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jomei/notionapi"
+)
+
+func TestRecorder_RecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	transport := &stubTransport{responses: []*http.Response{
+		jsonResponse(200, `{"object":"list","results":[`+pageJSON+`],"has_more":false}`),
+		jsonResponse(200, `{"object":"list","results":[{"object":"block","id":"block-1","type":"paragraph",
+			"paragraph":{"rich_text":[{"type":"text","text":{"content":"Hello"},"plain_text":"Hello"}]}}],"has_more":false}`),
+		jsonResponse(200, `{"object":"database","id":"db-1","properties":{
+			"Name":{"id":"title","type":"title","title":{}},
+			"Date":{"id":"d","type":"date","date":{}}}}`),
+	}}
+	live, _ := newStubService(transport)
+	rec := NewRecorder(dir, live)
+
+	if _, err := rec.FetchPages("db-1"); err != nil {
+		t.Fatalf("record FetchPages: %v", err)
+	}
+	if _, err := rec.GetChildren("page-1"); err != nil {
+		t.Fatalf("record GetChildren: %v", err)
+	}
+	if _, err := rec.GetDatabase("db-1"); err != nil {
+		t.Fatalf("record GetDatabase: %v", err)
+	}
+	if transport.calls != 3 {
+		t.Fatalf("calls = %d, want 3", transport.calls)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "children", "page1.json")); err != nil {
+		t.Errorf("children fixture not written: %v", err)
+	}
+
+	// Recorded responses are served without touching the API again, even
+	// with a live Service
+	if _, err := rec.FetchPages("db-1"); err != nil || transport.calls != 3 {
+		t.Errorf("second FetchPages: err = %v, calls = %d", err, transport.calls)
+	}
+
+	replay := NewRecorder(dir, nil)
+	pages, err := replay.FetchPages("db-1")
+	if err != nil {
+		t.Fatalf("replay FetchPages: %v", err)
+	}
+	if len(pages) != 1 || pages[0].ID != "page-1" {
+		t.Errorf("pages = %+v", pages)
+	}
+	blocks, err := replay.GetChildren("page-1")
+	if err != nil {
+		t.Fatalf("replay GetChildren: %v", err)
+	}
+	p, ok := blocks[0].(*notionapi.ParagraphBlock)
+	if len(blocks) != 1 || !ok || p.GetRichTextString() != "Hello" {
+		t.Errorf("blocks = %#v", blocks)
+	}
+	db, err := replay.GetDatabase("db-1")
+	if err != nil {
+		t.Fatalf("replay GetDatabase: %v", err)
+	}
+	if !slices.Equal(db.PropertyOrder, []string{"Name", "Date"}) {
+		t.Errorf("PropertyOrder = %v, want [Name Date]", db.PropertyOrder)
+	}
+	if db.Properties["Date"].GetType() != notionapi.PropertyConfigTypeDate {
+		t.Errorf("Date property = %#v", db.Properties["Date"])
+	}
+}
+
+func TestRecorder_ReplayMissing(t *testing.T) {
+	rec := NewRecorder(t.TempDir(), nil)
+	if _, err := rec.GetPage("page-1"); !errors.Is(err, ErrFixtureMissing) {
+		t.Errorf("err = %v, want ErrFixtureMissing", err)
+	}
+}
@@ -0,0 +1,69 @@
+package notionclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimit is the number of requests per second a Service sends by
+// default, Notion's documented average limit.
+const DefaultRateLimit = 3.0
+
+// rateLimiter is a token bucket holding a single token: calls to wait are
+// spaced at least one interval apart, in the order they were made.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // when the next token becomes available
+
+	// now and sleep are replaced in tests
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// newRateLimiter returns a limiter allowing perSecond calls per second, or
+// nil, which never waits, when perSecond is not positive.
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+		now:      time.Now,
+		sleep:    sleepContext,
+	}
+}
+
+// wait blocks until the caller may send a request, or ctx is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := l.now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		return l.sleep(ctx, d)
+	}
+	return nil
+}
+
+// sleepContext sleeps for d, returning early with ctx's error when it is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,87 @@
+package notionclient
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock stands in for time.Now and sleeping in rate limiter tests
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+func newFakeLimiter(perSecond float64) (*rateLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newRateLimiter(perSecond)
+	l.now = clock.Now
+	l.sleep = clock.Sleep
+	return l, clock
+}
+
+func TestRateLimiter_SpacesCalls(t *testing.T) {
+	l, clock := newFakeLimiter(2)
+	start := clock.now
+	var at []time.Duration
+	for i := 0; i < 4; i++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+		at = append(at, clock.now.Sub(start))
+	}
+	want := []time.Duration{0, 500 * time.Millisecond, time.Second, 1500 * time.Millisecond}
+	for i := range want {
+		if at[i] != want[i] {
+			t.Errorf("call %d at %v, want %v", i, at[i], want[i])
+		}
+	}
+}
+
+func TestRateLimiter_IdleDoesNotAccumulate(t *testing.T) {
+	l, clock := newFakeLimiter(2)
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// After a long pause the next call goes out at once, but the one after
+	// it is spaced as usual rather than allowed through as a burst.
+	clock.now = clock.now.Add(10 * time.Second)
+	for i := 0; i < 2; i++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(clock.sleeps) != 1 || clock.sleeps[0] != 500*time.Millisecond {
+		t.Errorf("sleeps = %v, want [500ms]", clock.sleeps)
+	}
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	if l := newRateLimiter(0); l != nil {
+		t.Fatalf("newRateLimiter(0) = %v, want nil", l)
+	}
+	var l *rateLimiter
+	if err := l.wait(context.Background()); err != nil {
+		t.Errorf("nil limiter wait: %v", err)
+	}
+}
+
+func TestNew_RateLimitOption(t *testing.T) {
+	if s := New("token"); s.limiter == nil || s.limiter.interval != time.Second/3 {
+		t.Errorf("default limiter = %+v, want %v interval", s.limiter, time.Second/3)
+	}
+	if s := New("token", WithRateLimit(10)); s.limiter.interval != 100*time.Millisecond {
+		t.Errorf("interval = %v, want 100ms", s.limiter.interval)
+	}
+	if s := New("token", WithRateLimit(0)); s.limiter != nil {
+		t.Error("WithRateLimit(0) should disable the limiter")
+	}
+}
@@ -0,0 +1,63 @@
+package notionclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// Defaults for retrying requests that failed with a transient error.
+const (
+	DefaultRetryAttempts = 3
+	DefaultRetryBackoff  = time.Second
+)
+
+// do runs op, waiting for the rate limiter before each attempt and retrying
+// transient failures with exponential backoff. It gives up early when the
+// Service's context is done.
+func (s *Service) do(op func(ctx context.Context) error) error {
+	ctx := s.ctx
+	backoff := s.backoff
+	for attempt := 1; ; attempt++ {
+		if err := s.limiter.wait(ctx); err != nil {
+			return err
+		}
+		err := op(ctx)
+		if err == nil || attempt >= s.attempts || !transient(err) || ctx.Err() != nil {
+			return err
+		}
+		if err := s.sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// transient reports whether err is worth retrying: a server error, an
+// exhausted 429 retry in the SDK, or a network failure.
+func transient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *notionapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Status >= 500 || apiErr.Status == 429
+	}
+	var rateErr *notionapi.RateLimitedError
+	if errors.As(err, &rateErr) {
+		return true
+	}
+	// Proxies in front of the API answer some 5xx errors with an HTML page,
+	// which the SDK fails to decode as JSON.
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
@@ -0,0 +1,140 @@
+package notionclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubTransport answers each request with the next of its responses, where
+// a nil response stands for a network error.
+type stubTransport struct {
+	responses []*http.Response
+	calls     int
+}
+
+func (t *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.calls >= len(t.responses) {
+		return nil, errors.New("unexpected request to " + req.URL.String())
+	}
+	resp := t.responses[t.calls]
+	t.calls++
+	if resp == nil {
+		return nil, &stubNetError{}
+	}
+	resp.Request = req
+	return resp, nil
+}
+
+type stubNetError struct{}
+
+func (*stubNetError) Error() string   { return "connection reset" }
+func (*stubNetError) Timeout() bool   { return false }
+func (*stubNetError) Temporary() bool { return true }
+
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+const pageJSON = `{"object":"page","id":"page-1","properties":{}}`
+
+// newStubService returns a Service sending requests to transport, with no
+// rate limit and its backoff sleeps recorded rather than slept.
+func newStubService(transport *stubTransport, opts ...Option) (*Service, *[]time.Duration) {
+	opts = append([]Option{
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithRateLimit(0),
+		WithRetry(3, 100*time.Millisecond),
+	}, opts...)
+	s := New("token", opts...)
+	var sleeps []time.Duration
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return ctx.Err()
+	}
+	return s, &sleeps
+}
+
+func TestRetry_ServerErrorThenSuccess(t *testing.T) {
+	transport := &stubTransport{responses: []*http.Response{
+		jsonResponse(503, `{"object":"error","status":503,"code":"service_unavailable","message":"unavailable"}`),
+		nil,
+		jsonResponse(200, pageJSON),
+	}}
+	s, sleeps := newStubService(transport)
+
+	page, err := s.GetPage("page-1")
+	if err != nil {
+		t.Fatalf("GetPage: %v", err)
+	}
+	if page.ID != "page-1" {
+		t.Errorf("page ID = %q", page.ID)
+	}
+	if transport.calls != 3 {
+		t.Errorf("calls = %d, want 3", transport.calls)
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}
+	if len(*sleeps) != len(want) || (*sleeps)[0] != want[0] || (*sleeps)[1] != want[1] {
+		t.Errorf("backoff = %v, want %v", *sleeps, want)
+	}
+}
+
+func TestRetry_GivesUpAfterAttempts(t *testing.T) {
+	transport := &stubTransport{responses: []*http.Response{
+		jsonResponse(502, `<html>Bad Gateway</html>`),
+		jsonResponse(502, `<html>Bad Gateway</html>`),
+		jsonResponse(502, `<html>Bad Gateway</html>`),
+	}}
+	s, _ := newStubService(transport)
+
+	if _, err := s.GetDatabase("db-1"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if transport.calls != 3 {
+		t.Errorf("calls = %d, want 3", transport.calls)
+	}
+}
+
+func TestRetry_ClientErrorNotRetried(t *testing.T) {
+	transport := &stubTransport{responses: []*http.Response{
+		jsonResponse(404, `{"object":"error","status":404,"code":"object_not_found","message":"not found"}`),
+	}}
+	s, sleeps := newStubService(transport)
+
+	if _, err := s.GetPage("missing"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if transport.calls != 1 || len(*sleeps) != 0 {
+		t.Errorf("calls = %d, sleeps = %v; want one call and no retries", transport.calls, *sleeps)
+	}
+}
+
+func TestRetry_ContextCancelInterrupts(t *testing.T) {
+	transport := &stubTransport{responses: []*http.Response{
+		jsonResponse(500, `{"object":"error","status":500,"code":"internal_server_error","message":"oops"}`),
+		jsonResponse(200, pageJSON),
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	s, _ := newStubService(transport, WithContext(ctx))
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return sleepContext(ctx, d)
+	}
+
+	_, err := s.GetPage("page-1")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if transport.calls != 1 {
+		t.Errorf("calls = %d, want 1", transport.calls)
+	}
+}
@@ -4,37 +4,382 @@
 package notionclient
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/jomei/notionapi"
 )
 
+// apiURL and notionVersion match the endpoint and API version used by the
+// Notion SDK.
+const (
+	apiURL        = "https://api.notion.com/v1"
+	notionVersion = "2022-06-28"
+)
+
+// pageSize is the largest page of results the API returns.
+const pageSize = 100
+
+// notionAPI is the part of the Notion SDK used by Service; tests provide a
+// fake.
+type notionAPI interface {
+	QueryDatabase(ctx context.Context, id notionapi.DatabaseID, req *notionapi.DatabaseQueryRequest) (*notionapi.DatabaseQueryResponse, error)
+	GetChildren(ctx context.Context, id notionapi.BlockID, p *notionapi.Pagination) (*notionapi.GetChildrenResponse, error)
+	GetBlock(ctx context.Context, id notionapi.BlockID) (notionapi.Block, error)
+	GetPage(ctx context.Context, id notionapi.PageID) (*notionapi.Page, error)
+	GetComments(ctx context.Context, id notionapi.BlockID, p *notionapi.Pagination) (*notionapi.CommentQueryResponse, error)
+}
+
+// sdkClient adapts a *notionapi.Client to notionAPI.
+type sdkClient struct {
+	client *notionapi.Client
+}
+
+func (c sdkClient) QueryDatabase(ctx context.Context, id notionapi.DatabaseID, req *notionapi.DatabaseQueryRequest) (*notionapi.DatabaseQueryResponse, error) {
+	return c.client.Database.Query(ctx, id, req)
+}
+
+func (c sdkClient) GetChildren(ctx context.Context, id notionapi.BlockID, p *notionapi.Pagination) (*notionapi.GetChildrenResponse, error) {
+	return c.client.Block.GetChildren(ctx, id, p)
+}
+
+func (c sdkClient) GetBlock(ctx context.Context, id notionapi.BlockID) (notionapi.Block, error) {
+	return c.client.Block.Get(ctx, id)
+}
+
+func (c sdkClient) GetPage(ctx context.Context, id notionapi.PageID) (*notionapi.Page, error) {
+	return c.client.Page.Get(ctx, id)
+}
+
+func (c sdkClient) GetComments(ctx context.Context, id notionapi.BlockID, p *notionapi.Pagination) (*notionapi.CommentQueryResponse, error) {
+	return c.client.Comment.Get(ctx, id, p)
+}
+
 // Service wraps a Notion API client and exposes a small set of convenience
 // methods used by the renderer and writer.
 type Service struct {
-	client *notionapi.Client
+	api        notionAPI
+	httpClient *http.Client
+	token      string
+	ctx        context.Context
+	limiter    *rateLimiter
+	filter     notionapi.Filter // applied by FetchPages; nil returns every page
+
+	// attempts and backoff control retries of transient errors
+	attempts int
+	backoff  time.Duration
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+// Option configures a Service created by New.
+type Option func(*Service)
+
+// WithRateLimit limits the Service to perSecond requests per second. A
+// value of zero or less disables the limit.
+func WithRateLimit(perSecond float64) Option {
+	return func(s *Service) {
+		s.limiter = newRateLimiter(perSecond)
+	}
+}
+
+// WithRetry makes the Service try a request up to attempts times when it
+// fails with a server or network error, waiting backoff before the first
+// retry and twice as long before each one after it.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(s *Service) {
+		s.attempts = max(attempts, 1)
+		s.backoff = backoff
+	}
+}
+
+// WithHTTPClient sends requests through client instead of
+// http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Service) {
+		s.httpClient = client
+	}
+}
+
+// WithPublishedProperty makes FetchPages return only the pages whose
+// checkbox property of that name is ticked. An empty name returns every
+// page.
+func WithPublishedProperty(name string) Option {
+	return func(s *Service) {
+		s.filter = publishedFilter(name)
+	}
+}
+
+// publishedFilter returns a query filter matching a ticked checkbox
+func publishedFilter(property string) notionapi.Filter {
+	if property == "" {
+		return nil
+	}
+	return &notionapi.PropertyFilter{
+		Property: property,
+		Checkbox: &notionapi.CheckboxFilterCondition{Equals: true},
+	}
+}
+
+// WithContext makes requests, and the waits between them, stop when ctx is
+// done.
+func WithContext(ctx context.Context) Option {
+	return func(s *Service) {
+		s.ctx = ctx
+	}
+}
+
+// New creates a Service initialized with the provided Notion integration
+// token. Requests are limited to DefaultRateLimit per second and retried
+// DefaultRetryAttempts times unless an option says otherwise.
+func New(token string, opts ...Option) *Service {
+	s := &Service{
+		httpClient: http.DefaultClient,
+		token:      token,
+		ctx:        context.Background(),
+		limiter:    newRateLimiter(DefaultRateLimit),
+		attempts:   DefaultRetryAttempts,
+		backoff:    DefaultRetryBackoff,
+		sleep:      sleepContext,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.api = sdkClient{notionapi.NewClient(notionapi.Token(token), notionapi.WithHTTPClient(s.httpClient))}
+	return s
 }
 
-// New creates a Service initialized with the provided Notion integration token.
-func New(token string) *Service {
-	return &Service{client: notionapi.NewClient(notionapi.Token(token))}
+// Database is a database's schema along with the names of its properties
+// in column order, which the SDK's property map does not keep.
+type Database struct {
+	notionapi.Database
+	PropertyOrder []string
 }
 
-// FetchPages queries the given Notion database and returns the list of pages
-// (results) returned by the API.
+// FetchPages queries the given Notion database and returns all of its
+// pages matching the Service's filter, following the API's pagination.
 func (s *Service) FetchPages(databaseID string) ([]notionapi.Page, error) {
-	resp, err := s.client.Database.Query(context.Background(), notionapi.DatabaseID(databaseID), &notionapi.DatabaseQueryRequest{})
+	var pages []notionapi.Page
+	req := &notionapi.DatabaseQueryRequest{Filter: s.filter, PageSize: pageSize}
+	for {
+		var resp *notionapi.DatabaseQueryResponse
+		err := s.do(func(ctx context.Context) (err error) {
+			resp, err = s.api.QueryDatabase(ctx, notionapi.DatabaseID(databaseID), req)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, resp.Results...)
+		if !resp.HasMore || resp.NextCursor == "" {
+			return pages, nil
+		}
+		req.StartCursor = resp.NextCursor
+	}
+}
+
+// GetChildren retrieves all child blocks of the provided block or page ID,
+// following the API's pagination.
+func (s *Service) GetChildren(id notionapi.BlockID) ([]notionapi.Block, error) {
+	var blocks []notionapi.Block
+	p := &notionapi.Pagination{PageSize: pageSize}
+	for {
+		var resp *notionapi.GetChildrenResponse
+		err := s.do(func(ctx context.Context) (err error) {
+			resp, err = s.api.GetChildren(ctx, id, p)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, resp.Results...)
+		if !resp.HasMore || resp.NextCursor == "" {
+			return blocks, nil
+		}
+		p.StartCursor = notionapi.Cursor(resp.NextCursor)
+	}
+}
+
+// GetPage retrieves a single page with its properties.
+func (s *Service) GetPage(pageID string) (notionapi.Page, error) {
+	var page *notionapi.Page
+	err := s.do(func(ctx context.Context) (err error) {
+		page, err = s.api.GetPage(ctx, notionapi.PageID(pageID))
+		return err
+	})
+	if err != nil {
+		return notionapi.Page{}, err
+	}
+	return *page, nil
+}
+
+// GetComments retrieves the unresolved comments on a block or page,
+// following the API's pagination.
+func (s *Service) GetComments(id notionapi.BlockID) ([]notionapi.Comment, error) {
+	var comments []notionapi.Comment
+	p := &notionapi.Pagination{PageSize: pageSize}
+	for {
+		var resp *notionapi.CommentQueryResponse
+		err := s.do(func(ctx context.Context) (err error) {
+			resp, err = s.api.GetComments(ctx, id, p)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, resp.Results...)
+		if !resp.HasMore || resp.NextCursor == "" {
+			return comments, nil
+		}
+		p.StartCursor = resp.NextCursor
+	}
+}
+
+// GetBlock retrieves a single block, e.g. to obtain a fresh signed file URL.
+func (s *Service) GetBlock(id notionapi.BlockID) (notionapi.Block, error) {
+	var block notionapi.Block
+	err := s.do(func(ctx context.Context) (err error) {
+		block, err = s.api.GetBlock(ctx, id)
+		return err
+	})
+	return block, err
+}
+
+// GetDatabase retrieves a database's schema. The response is decoded here
+// rather than by the SDK so that the order of its properties is kept.
+func (s *Service) GetDatabase(databaseID string) (*Database, error) {
+	var data []byte
+	err := s.do(func(ctx context.Context) (err error) {
+		data, err = s.getRaw(ctx, "/databases/"+databaseID)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	return resp.Results, nil
+	return decodeDatabase(data)
 }
 
-// GetChildren retrieves child blocks for the provided block or page ID.
-func (s *Service) GetChildren(id notionapi.BlockID) ([]notionapi.Block, error) {
-	resp, err := s.client.Block.GetChildren(context.Background(), id, nil)
+// GetColumnWidths retrieves the width ratios of the columns of a column
+// list, keyed by column ID. The SDK does not decode them, so the column
+// list's children are fetched and decoded here. Columns Notion sizes evenly
+// have no ratio and are left out.
+func (s *Service) GetColumnWidths(columnListID notionapi.BlockID) (map[notionapi.BlockID]float64, error) {
+	widths := map[notionapi.BlockID]float64{}
+	cursor := ""
+	for {
+		endpoint := fmt.Sprintf("/blocks/%s/children?page_size=%d", columnListID, pageSize)
+		if cursor != "" {
+			endpoint += "&start_cursor=" + url.QueryEscape(cursor)
+		}
+		var data []byte
+		err := s.do(func(ctx context.Context) (err error) {
+			data, err = s.getRaw(ctx, endpoint)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if cursor, err = decodeColumnWidths(data, widths); err != nil {
+			return nil, err
+		}
+		if cursor == "" {
+			return widths, nil
+		}
+	}
+}
+
+// getRaw fetches an API endpoint and returns the response body, or the API
+// error it describes.
+func (s *Service) getRaw(ctx context.Context, endpoint string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Notion-Version", notionVersion)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
-	return resp.Results, nil
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &notionapi.Error{}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = fmt.Sprintf("GET %s: %s", endpoint, resp.Status)
+		}
+		apiErr.Status = resp.StatusCode
+		return nil, apiErr
+	}
+	return data, nil
+}
+
+// decodeDatabase decodes a database object, recording the order of the keys
+// of its "properties" object.
+func decodeDatabase(data []byte) (*Database, error) {
+	db := &Database{}
+	if err := json.Unmarshal(data, &db.Database); err != nil {
+		return nil, err
+	}
+	var raw struct {
+		Properties json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if len(raw.Properties) == 0 {
+		return db, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw.Properties))
+	if _, err := dec.Token(); err != nil { // opening brace
+		return nil, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+		db.PropertyOrder = append(db.PropertyOrder, key.(string))
+	}
+	return db, nil
+}
+
+// decodeColumnWidths records the width_ratio of the columns in a page of
+// block children in widths, and returns the cursor of the next page or ""
+// after the last one.
+func decodeColumnWidths(data []byte, widths map[notionapi.BlockID]float64) (string, error) {
+	var resp struct {
+		Results []struct {
+			ID     notionapi.BlockID `json:"id"`
+			Column *struct {
+				WidthRatio *float64 `json:"width_ratio"`
+			} `json:"column"`
+		} `json:"results"`
+		HasMore    bool   `json:"has_more"`
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", err
+	}
+	for _, block := range resp.Results {
+		if block.Column != nil && block.Column.WidthRatio != nil {
+			widths[block.ID] = *block.Column.WidthRatio
+		}
+	}
+	if !resp.HasMore {
+		return "", nil
+	}
+	return resp.NextCursor, nil
 }
@@ -0,0 +1,223 @@
+package notionclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// fakeAPI serves pages, blocks and comments in pages of perPage results,
+// using the index of the next result as the cursor.
+type fakeAPI struct {
+	perPage  int
+	pages    []notionapi.Page
+	blocks   []notionapi.Block
+	comments []notionapi.Comment
+	// fail, when set, is returned by the next call instead of its results
+	fail    error
+	cursors []string
+	// filter is the filter of the last database query
+	filter notionapi.Filter
+}
+
+// window returns the bounds of the results starting at cursor, and the
+// cursor following them, or "" after the last one.
+func (f *fakeAPI) window(cursor string, total int) (int, int, string, error) {
+	f.cursors = append(f.cursors, cursor)
+	if err := f.fail; err != nil {
+		f.fail = nil
+		return 0, 0, "", err
+	}
+	start := 0
+	if cursor != "" {
+		var err error
+		if start, err = strconv.Atoi(cursor); err != nil {
+			return 0, 0, "", err
+		}
+	}
+	end := min(start+f.perPage, total)
+	next := ""
+	if end < total {
+		next = strconv.Itoa(end)
+	}
+	return start, end, next, nil
+}
+
+func (f *fakeAPI) QueryDatabase(_ context.Context, _ notionapi.DatabaseID, req *notionapi.DatabaseQueryRequest) (*notionapi.DatabaseQueryResponse, error) {
+	f.filter = req.Filter
+	start, end, next, err := f.window(string(req.StartCursor), len(f.pages))
+	if err != nil {
+		return nil, err
+	}
+	return &notionapi.DatabaseQueryResponse{Results: f.pages[start:end], HasMore: next != "", NextCursor: notionapi.Cursor(next)}, nil
+}
+
+func (f *fakeAPI) GetChildren(_ context.Context, _ notionapi.BlockID, p *notionapi.Pagination) (*notionapi.GetChildrenResponse, error) {
+	start, end, next, err := f.window(string(p.StartCursor), len(f.blocks))
+	if err != nil {
+		return nil, err
+	}
+	return &notionapi.GetChildrenResponse{Results: f.blocks[start:end], HasMore: next != "", NextCursor: next}, nil
+}
+
+func (f *fakeAPI) GetBlock(_ context.Context, id notionapi.BlockID) (notionapi.Block, error) {
+	for _, b := range f.blocks {
+		if b.GetID() == id {
+			return b, nil
+		}
+	}
+	return nil, &notionapi.Error{Status: 404, Message: "not found"}
+}
+
+func (f *fakeAPI) GetPage(_ context.Context, id notionapi.PageID) (*notionapi.Page, error) {
+	for _, p := range f.pages {
+		if p.ID == notionapi.ObjectID(id) {
+			return &p, nil
+		}
+	}
+	return nil, &notionapi.Error{Status: 404, Message: "not found"}
+}
+
+func (f *fakeAPI) GetComments(_ context.Context, _ notionapi.BlockID, p *notionapi.Pagination) (*notionapi.CommentQueryResponse, error) {
+	start, end, next, err := f.window(string(p.StartCursor), len(f.comments))
+	if err != nil {
+		return nil, err
+	}
+	return &notionapi.CommentQueryResponse{Results: f.comments[start:end], HasMore: next != "", NextCursor: notionapi.Cursor(next)}, nil
+}
+
+// newFakeService returns a Service backed by api, with no rate limit and
+// no waiting between retries.
+func newFakeService(api notionAPI) *Service {
+	s := New("token", WithRateLimit(0), WithRetry(2, 0))
+	s.api = api
+	return s
+}
+
+func TestFetchPages_Paginates(t *testing.T) {
+	api := &fakeAPI{perPage: 2}
+	for i := range 5 {
+		api.pages = append(api.pages, notionapi.Page{ID: notionapi.ObjectID("page-" + strconv.Itoa(i))})
+	}
+	pages, err := newFakeService(api).FetchPages("db")
+	if err != nil {
+		t.Fatalf("FetchPages: %v", err)
+	}
+	if len(pages) != 5 || pages[4].ID != "page-4" {
+		t.Errorf("got %d pages, want all 5 in order: %+v", len(pages), pages)
+	}
+	if want := []string{"", "2", "4"}; !slices.Equal(api.cursors, want) {
+		t.Errorf("cursors = %q, want %q", api.cursors, want)
+	}
+}
+
+func TestGetChildren_Paginates(t *testing.T) {
+	api := &fakeAPI{perPage: 100}
+	for i := range 250 {
+		api.blocks = append(api.blocks, &notionapi.DividerBlock{BasicBlock: notionapi.BasicBlock{ID: notionapi.BlockID(strconv.Itoa(i))}})
+	}
+	blocks, err := newFakeService(api).GetChildren("page")
+	if err != nil {
+		t.Fatalf("GetChildren: %v", err)
+	}
+	if len(blocks) != 250 || blocks[249].GetID() != "249" {
+		t.Errorf("got %d blocks, want all 250 in order", len(blocks))
+	}
+	if len(api.cursors) != 3 {
+		t.Errorf("requests = %d, want 3", len(api.cursors))
+	}
+}
+
+func TestGetChildren_SinglePage(t *testing.T) {
+	api := &fakeAPI{perPage: 100}
+	blocks, err := newFakeService(api).GetChildren("page")
+	if err != nil || len(blocks) != 0 || len(api.cursors) != 1 {
+		t.Errorf("blocks = %v, err = %v, requests = %d; want none in one request", blocks, err, len(api.cursors))
+	}
+}
+
+func TestFetchPages_RetriesFailedPage(t *testing.T) {
+	api := &fakeAPI{perPage: 1, pages: []notionapi.Page{{ID: "a"}, {ID: "b"}}}
+	s := newFakeService(api)
+	s.sleep = func(context.Context, time.Duration) error { return nil }
+	api.fail = &notionapi.Error{Status: 502, Message: "bad gateway"}
+
+	pages, err := s.FetchPages("db")
+	if err != nil || len(pages) != 2 {
+		t.Fatalf("pages = %v, err = %v", pages, err)
+	}
+
+	api.cursors = nil
+	api.fail = &notionapi.Error{Status: 400, Message: "bad request"}
+	if _, err := s.FetchPages("db"); err == nil {
+		t.Fatal("expected an error")
+	} else if apiErr := (*notionapi.Error)(nil); !errors.As(err, &apiErr) || apiErr.Status != 400 {
+		t.Errorf("err = %v, want the API error", err)
+	}
+}
+
+func TestPublishedFilter(t *testing.T) {
+	data, err := json.Marshal(publishedFilter("Published"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"property":"Published","checkbox":{"equals":true}}`; string(data) != want {
+		t.Errorf("filter = %s, want %s", data, want)
+	}
+	if f := publishedFilter(""); f != nil {
+		t.Errorf("publishedFilter(\"\") = %v, want nil", f)
+	}
+}
+
+func TestFetchPages_PublishedProperty(t *testing.T) {
+	api := &fakeAPI{perPage: 10}
+	s := newFakeService(api)
+	if _, err := s.FetchPages("db"); err != nil {
+		t.Fatal(err)
+	}
+	if api.filter != nil {
+		t.Errorf("unfiltered query sent filter %v", api.filter)
+	}
+
+	WithPublishedProperty("Published")(s)
+	if _, err := s.FetchPages("db"); err != nil {
+		t.Fatal(err)
+	}
+	pf, ok := api.filter.(*notionapi.PropertyFilter)
+	if !ok || pf.Property != "Published" || pf.Checkbox == nil || !pf.Checkbox.Equals {
+		t.Errorf("filter = %#v, want a ticked Published checkbox", api.filter)
+	}
+}
+
+func TestGetColumnWidths_Paginates(t *testing.T) {
+	transport := &stubTransport{responses: []*http.Response{
+		jsonResponse(200, `{"object":"list","results":[
+			{"object":"block","id":"col-1","type":"column","column":{"width_ratio":0.25}}],
+			"has_more":true,"next_cursor":"col-2"}`),
+		jsonResponse(200, `{"object":"list","results":[
+			{"object":"block","id":"col-2","type":"column","column":{"width_ratio":0.75}},
+			{"object":"block","id":"col-3","type":"column","column":{}}],
+			"has_more":false,"next_cursor":null}`),
+	}}
+	s, _ := newStubService(transport)
+
+	widths, err := s.GetColumnWidths("list-1")
+	if err != nil {
+		t.Fatalf("GetColumnWidths: %v", err)
+	}
+	want := map[notionapi.BlockID]float64{"col-1": 0.25, "col-2": 0.75}
+	if !maps.Equal(widths, want) {
+		t.Errorf("widths = %v, want %v", widths, want)
+	}
+	if got := transport.responses[1].Request.URL.Query().Get("start_cursor"); got != "col-2" {
+		t.Errorf("second request cursor = %q, want col-2", got)
+	}
+}
@@ -0,0 +1,55 @@
+package renderer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// assetManifestName is the hidden file, kept at the root of the output (or
+// in the cache dir), that records what was downloaded
+const assetManifestName = ".notion-assets.json"
+
+// assetRecord describes a downloaded file, keyed in the manifest by its path
+// relative to the manifest's directory
+type assetRecord struct {
+	// Identifier is the stable file identifier the name was derived from
+	Identifier string `json:"identifier"`
+	// SHA256 is the hash of the downloaded content
+	SHA256 string `json:"sha256"`
+	// ETag and LastModified are the validators used for conditional requests
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+
+	// extension is the one the download's Content-Disposition names, if any;
+	// it is not stored
+	extension string
+}
+
+// loadAssetManifest reads the manifest kept in dir. A missing or unreadable
+// manifest yields an empty one.
+func loadAssetManifest(dir string) map[string]assetRecord {
+	manifest := map[string]assetRecord{}
+	data, err := os.ReadFile(filepath.Join(dir, assetManifestName))
+	if err != nil {
+		return manifest
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return map[string]assetRecord{}
+	}
+	return manifest
+}
+
+// saveAssetManifest writes the manifest kept in dir
+func saveAssetManifest(dir string, manifest map[string]assetRecord) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode asset manifest: %w", err)
+	}
+	path := filepath.Join(dir, assetManifestName)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write asset manifest %s: %w", path, err)
+	}
+	return nil
+}
@@ -1,10 +1,17 @@
 package renderer
 
 import (
+	"errors"
+	"log/slog"
+	"maps"
+	"math"
 	"net/url"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/jomei/notionapi"
 )
@@ -20,51 +27,51 @@ import (
 func blockToMarkdownWithCache(block notionapi.Block, childContent string, resolve func(string) string, fileCache *FileCache, articlePath string, config *RenderConfig) (string, bool) {
 	switch b := block.(type) {
 	case *notionapi.ParagraphBlock:
-		return paragraphToMarkdown(b, resolve), false
+		return paragraphToMarkdown(b, childContent, resolve, config), false
 	case *notionapi.Heading1Block:
-		return heading1ToMarkdown(b, resolve), false
+		return heading1ToMarkdown(b, resolve, config), false
 	case *notionapi.Heading2Block:
-		return heading2ToMarkdown(b, resolve), false
+		return heading2ToMarkdown(b, resolve, config), false
 	case *notionapi.Heading3Block:
-		return heading3ToMarkdown(b, resolve), false
+		return heading3ToMarkdown(b, resolve, config), false
 	case *notionapi.BulletedListItemBlock:
-		return bulletedListItemToMarkdown(b, childContent, resolve), true
+		return bulletedListItemToMarkdown(b, childContent, resolve, config), true
 	case *notionapi.NumberedListItemBlock:
-		return numberedListItemToMarkdown(b, childContent, resolve), true
+		return numberedListItemToMarkdown(b, childContent, resolve, config), true
 	case *notionapi.ToDoBlock:
-		return toDoToMarkdown(b, childContent, resolve), true
+		return toDoToMarkdown(b, childContent, resolve, config), true
 	case *notionapi.ToggleBlock:
 		return toggleToMarkdown(b, childContent, resolve, config), false
 	case *notionapi.EquationBlock:
 		return equationToMarkdown(b, resolve, config), false
 	case *notionapi.CodeBlock:
-		return codeToMarkdown(b, resolve), false
+		return codeToMarkdown(b, resolve, config), false
 	case *notionapi.QuoteBlock:
-		return quoteToMarkdown(b, resolve), false
+		return quoteToMarkdown(b, childContent, resolve, config), false
 	case *notionapi.CalloutBlock:
 		return calloutToMarkdown(b, childContent, resolve, config), false
 	case *notionapi.DividerBlock:
-		return dividerToMarkdown(b), false
+		return dividerToMarkdown(b, config), false
 	case *notionapi.ImageBlock:
-		return imageToMarkdownWithCache(b, fileCache, articlePath), false
+		return imageToMarkdownWithCache(b, fileCache, articlePath, config), false
 	case *notionapi.BookmarkBlock:
-		return bookmarkToMarkdown(b), false
+		return bookmarkToMarkdown(b, config), false
 	case *notionapi.EmbedBlock:
-		return embedToMarkdown(b, config), false
+		return embedToMarkdown(b, resolve, config), false
 	case *notionapi.LinkPreviewBlock:
-		return linkPreviewToMarkdown(b), false
+		return linkPreviewToMarkdown(b, config), false
 	case *notionapi.FileBlock:
-		return fileToMarkdownWithCache(b, fileCache, articlePath, config), false
+		return fileToMarkdownWithCache(b, resolve, fileCache, articlePath, config), false
 	case *notionapi.PdfBlock:
-		return pdfToMarkdownWithCache(b, fileCache, articlePath, config), false
+		return pdfToMarkdownWithCache(b, resolve, fileCache, articlePath, config), false
 	case *notionapi.VideoBlock:
-		return videoToMarkdownWithCache(b, fileCache, articlePath, config), false
+		return videoToMarkdownWithCache(b, resolve, fileCache, articlePath, config), false
 	case *notionapi.TableBlock:
 		return tableToMarkdown(b, childContent), false
 	case *notionapi.TableRowBlock:
-		return tableRowToMarkdown(b, resolve), false
+		return tableRowToMarkdown(b, resolve, config), false
 	case *notionapi.ColumnListBlock:
-		return columnListToMarkdown(b, childContent), false
+		return columnListToMarkdown(b, childContent, nil, config), false
 	case *notionapi.ColumnBlock:
 		return columnToMarkdown(b, childContent), false
 	default:
@@ -72,20 +79,30 @@ func blockToMarkdownWithCache(block notionapi.Block, childContent string, resolv
 	}
 }
 
-func paragraphToMarkdown(b *notionapi.ParagraphBlock, resolve func(string) string) string {
-	return richTextArrToMarkdown(b.Paragraph.RichText, resolve)
+// paragraphToMarkdown renders a paragraph followed by its child blocks.
+// Markdown has no nested paragraphs, and indenting the children would turn
+// them into a code block, so they follow the paragraph as blocks of their own.
+func paragraphToMarkdown(b *notionapi.ParagraphBlock, childContent string, resolve func(string) string, config *RenderConfig) string {
+	text := richTextArrToMarkdown(b.Paragraph.RichText, resolve, config)
+	if childContent == "" {
+		return text
+	}
+	if text == "" {
+		return childContent
+	}
+	return text + "\n\n" + childContent
 }
 
-func heading1ToMarkdown(b *notionapi.Heading1Block, resolve func(string) string) string {
-	return "# " + richTextArrToMarkdown(b.Heading1.RichText, resolve)
+func heading1ToMarkdown(b *notionapi.Heading1Block, resolve func(string) string, config *RenderConfig) string {
+	return "# " + richTextArrToMarkdown(b.Heading1.RichText, resolve, config)
 }
 
-func heading2ToMarkdown(b *notionapi.Heading2Block, resolve func(string) string) string {
-	return "## " + richTextArrToMarkdown(b.Heading2.RichText, resolve)
+func heading2ToMarkdown(b *notionapi.Heading2Block, resolve func(string) string, config *RenderConfig) string {
+	return "## " + richTextArrToMarkdown(b.Heading2.RichText, resolve, config)
 }
 
-func heading3ToMarkdown(b *notionapi.Heading3Block, resolve func(string) string) string {
-	return "### " + richTextArrToMarkdown(b.Heading3.RichText, resolve)
+func heading3ToMarkdown(b *notionapi.Heading3Block, resolve func(string) string, config *RenderConfig) string {
+	return "### " + richTextArrToMarkdown(b.Heading3.RichText, resolve, config)
 }
 
 // renderListItemWithChild renders a list item with base content and optional child content
@@ -96,41 +113,99 @@ func renderListItemWithChild(base string, childContent string) string {
 	return base + "\n" + childContent
 }
 
-func bulletedListItemToMarkdown(b *notionapi.BulletedListItemBlock, childContent string, resolve func(string) string) string {
-	base := "- " + richTextArrToMarkdown(b.BulletedListItem.RichText, resolve)
+// bulletMarker returns the configured marker for bulleted list items, which
+// to-dos share so that adjacent items stay one list.
+func bulletMarker(config *RenderConfig) string {
+	if config.BulletMarker == "" {
+		return "-"
+	}
+	return config.BulletMarker
+}
+
+func bulletedListItemToMarkdown(b *notionapi.BulletedListItemBlock, childContent string, resolve func(string) string, config *RenderConfig) string {
+	base := bulletMarker(config) + " " + richTextArrToMarkdown(b.BulletedListItem.RichText, resolve, config)
 	return renderListItemWithChild(base, childContent)
 }
 
-func numberedListItemToMarkdown(b *notionapi.NumberedListItemBlock, childContent string, resolve func(string) string) string {
-	base := "1. " + richTextArrToMarkdown(b.NumberedListItem.RichText, resolve)
+// numberedMarker returns the marker of the numbered list item at position
+// number, e.g. "3." or "1)". Every item is numbered 1 unless
+// NumberedListIncrement is set.
+func numberedMarker(number int, config *RenderConfig) string {
+	delimiter := config.NumberedListDelimiter
+	if delimiter == "" {
+		delimiter = "."
+	}
+	if number < 1 || !config.NumberedListIncrement {
+		number = 1
+	}
+	return strconv.Itoa(number) + delimiter
+}
+
+func numberedListItemToMarkdown(b *notionapi.NumberedListItemBlock, childContent string, resolve func(string) string, config *RenderConfig) string {
+	base := numberedMarker(1, config) + " " + richTextArrToMarkdown(b.NumberedListItem.RichText, resolve, config)
 	return renderListItemWithChild(base, childContent)
 }
 
-func toDoToMarkdown(b *notionapi.ToDoBlock, childContent string, resolve func(string) string) string {
+func toDoToMarkdown(b *notionapi.ToDoBlock, childContent string, resolve func(string) string, config *RenderConfig) string {
 	checked := " "
 	if b.ToDo.Checked {
 		checked = "x"
 	}
-	base := "- [" + checked + "] " + richTextArrToMarkdown(b.ToDo.RichText, resolve)
+	text := richTextArrToMarkdown(b.ToDo.RichText, resolve, config)
+	if b.ToDo.Checked && config.StrikeCompletedToDos && text != "" {
+		text = "~~" + text + "~~"
+	}
+	base := bulletMarker(config) + " [" + checked + "] " + text
 	return renderListItemWithChild(base, childContent)
 }
 
+// toggleHeading introduces the summary of toggles rendered with
+// ToggleStyleMarkdown, a level below the headings pages usually use
+const toggleHeading = "#### "
+
 func toggleToMarkdown(b *notionapi.ToggleBlock, childContent string, resolve func(string) string, config *RenderConfig) string {
-	summary := richTextArrToMarkdown(b.Toggle.RichText, resolve)
+	summary := richTextArrToMarkdown(b.Toggle.RichText, resolve, config)
+	if config.ToggleStyle == ToggleStyleMarkdown {
+		// A heading followed by the content. The content is not indented,
+		// which Markdown would turn into a code block.
+		if childContent == "" {
+			return toggleHeading + summary
+		}
+		return toggleHeading + summary + "\n\n" + childContent
+	}
 	if childContent == "" {
 		return "> " + summary
 	}
-	childContent = dedentChildContent(childContent)
+	// Toggle children are not indented, so the content is used as is;
+	// dedenting would flatten nested lists and nested toggles.
 
 	data := map[string]string{
-		"Summary": summary,
-		"Content": childContent,
+		"Summary":         summary,
+		"Content":         childContent,
+		"IndentedContent": indentContinuation(childContent),
 	}
 	return renderTemplate(config.DetailsTemplate, data)
 }
 
-func codeToMarkdown(b *notionapi.CodeBlock, resolve func(string) string) string {
-	return "```" + b.Code.Language + "\n" + richTextArrToMarkdown(b.Code.RichText, resolve) + "\n```"
+// indentContinuation indents every line but the first by four spaces, for
+// templates whose syntax nests content by indentation (e.g. "    {{.IndentedContent}}").
+func indentContinuation(content string) string {
+	lines := strings.Split(content, "\n")
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			lines[i] = "    " + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// codeToMarkdown renders a fenced code block, or the code itself when its
+// language is the configured RawHTMLLanguage.
+func codeToMarkdown(b *notionapi.CodeBlock, resolve func(string) string, config *RenderConfig) string {
+	if config.RawHTMLLanguage != "" && strings.EqualFold(b.Code.Language, config.RawHTMLLanguage) {
+		return plainText(b.Code.RichText)
+	}
+	return "```" + b.Code.Language + "\n" + richTextArrToMarkdown(b.Code.RichText, resolve, config) + "\n```"
 }
 
 func equationToMarkdown(b *notionapi.EquationBlock, resolve func(string) string, config *RenderConfig) string {
@@ -143,13 +218,36 @@ func equationToMarkdown(b *notionapi.EquationBlock, resolve func(string) string,
 	return ""
 }
 
-func quoteToMarkdown(b *notionapi.QuoteBlock, resolve func(string) string) string {
-	return "> " + richTextArrToMarkdown(b.Quote.RichText, resolve)
+// quoteToMarkdown renders a quote and its child blocks as one contiguous
+// blockquote, with paragraphs separated by empty ">" lines.
+func quoteToMarkdown(b *notionapi.QuoteBlock, childContent string, resolve func(string) string, config *RenderConfig) string {
+	content := richTextArrToMarkdown(b.Quote.RichText, resolve, config)
+	if childContent != "" {
+		content += "\n\n" + childContent
+	}
+	return blockquote(content)
+}
+
+// blockquote prefixes every line of content with "> ", or ">" when blank
+func blockquote(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + l
+		}
+	}
+	return strings.Join(lines, "\n")
 }
 
 func calloutToMarkdown(b *notionapi.CalloutBlock, childContent string, resolve func(string) string, config *RenderConfig) string {
-	contentText := richTextArrToMarkdown(b.Callout.RichText, resolve)
+	text := richTextArrToMarkdown(b.Callout.RichText, resolve, config)
+	contentText := text
+	plainContent := contentText
+	quotedChildren := ""
 	if childContent != "" {
+		plainContent += "\n\n" + childContent
 		childContent = dedentChildContent(childContent)
 		lines := strings.Split(childContent, "\n")
 		addSeparator := false
@@ -158,7 +256,7 @@ func calloutToMarkdown(b *notionapi.CalloutBlock, childContent string, resolve f
 			if t == "" {
 				continue
 			}
-			if strings.HasPrefix(t, "-") || strings.HasPrefix(t, "1.") || strings.HasPrefix(t, "*") || strings.HasPrefix(t, "<") || strings.HasPrefix(t, "|") {
+			if strings.HasPrefix(t, "-") || strings.HasPrefix(t, "1.") || strings.HasPrefix(t, "1)") || strings.HasPrefix(t, "*") || strings.HasPrefix(t, "+") || strings.HasPrefix(t, "<") || strings.HasPrefix(t, "|") {
 				addSeparator = false
 			} else {
 				addSeparator = true
@@ -176,24 +274,85 @@ func calloutToMarkdown(b *notionapi.CalloutBlock, childContent string, resolve f
 				childLines = append(childLines, "> "+l)
 			}
 		}
-		contentText += "\n" + strings.Join(childLines, "\n")
+		quotedChildren = "\n" + strings.Join(childLines, "\n")
+		contentText += quotedChildren
+	}
+
+	icon := ""
+	if b.Callout.Icon != nil && b.Callout.Icon.Emoji != nil {
+		icon = string(*b.Callout.Icon.Emoji)
+	}
+	calloutType := calloutTypeFor(icon, b.Callout.Color)
+	if mapped, ok := config.CalloutTypeMap[calloutType]; ok {
+		calloutType = mapped
+	}
+
+	if config.CalloutStyle == CalloutStyleObsidian {
+		// Every line of the text must stay inside the blockquote
+		return "> [!" + calloutType + "]\n" + blockquote(text) + quotedChildren
 	}
 
 	data := map[string]string{
-		"Content": contentText,
+		"Content":         contentText,
+		"Body":            plainContent,
+		"IndentedContent": indentContinuation(plainContent),
+		"Icon":            icon,
+		"Type":            calloutType,
 	}
 	return renderTemplate(config.CalloutTemplate, data)
 }
 
-func dividerToMarkdown(b *notionapi.DividerBlock) string {
+// calloutIconTypes maps callout icons to admonition types
+var calloutIconTypes = map[string]string{
+	"ℹ️": "info",
+	"💡":  "tip",
+	"⚠️": "warning",
+	"❗":  "danger",
+	"🚨":  "danger",
+	"🔥":  "danger",
+	"❌":  "failure",
+	"✅":  "success",
+	"❓":  "question",
+	"🐛":  "bug",
+	"📝":  "note",
+	"📋":  "example",
+	"💬":  "quote",
+}
+
+// calloutColorTypes maps callout background colors to admonition types
+var calloutColorTypes = map[string]string{
+	"blue_background":   "info",
+	"green_background":  "success",
+	"yellow_background": "warning",
+	"orange_background": "warning",
+	"red_background":    "danger",
+}
+
+// calloutTypeFor picks the admonition type (note, tip, warning, ...) for a
+// callout from its icon, then its color, defaulting to "note".
+func calloutTypeFor(icon, color string) string {
+	if t, ok := calloutIconTypes[icon]; ok {
+		return t
+	}
+	if t, ok := calloutColorTypes[color]; ok {
+		return t
+	}
+	return "note"
+}
+
+func dividerToMarkdown(b *notionapi.DividerBlock, config *RenderConfig) string {
 	_ = b
-	return "---"
+	if config.DividerTemplate == "" {
+		return "---"
+	}
+	return config.DividerTemplate
 }
 
 // processFileURL extracts URL and handles caching for Notion file/external blocks
 type fileURLExtractor interface {
 	getFileURL() (url string, shouldCache bool)
 	getCaption() []notionapi.RichText
+	getBlockID() notionapi.BlockID
 }
 
 type imageURLExtractor struct{ block *notionapi.ImageBlock }
@@ -207,6 +366,7 @@ func (e imageURLExtractor) getFileURL() (string, bool) {
 	return "", false
 }
 func (e imageURLExtractor) getCaption() []notionapi.RichText { return e.block.Image.Caption }
+func (e imageURLExtractor) getBlockID() notionapi.BlockID    { return e.block.ID }
 
 type fileURLExtractorImpl struct{ block *notionapi.FileBlock }
 
@@ -219,6 +379,7 @@ func (e fileURLExtractorImpl) getFileURL() (string, bool) {
 	return "", false
 }
 func (e fileURLExtractorImpl) getCaption() []notionapi.RichText { return e.block.File.Caption }
+func (e fileURLExtractorImpl) getBlockID() notionapi.BlockID    { return e.block.ID }
 
 type pdfURLExtractor struct{ block *notionapi.PdfBlock }
 
@@ -231,6 +392,7 @@ func (e pdfURLExtractor) getFileURL() (string, bool) {
 	return "", false
 }
 func (e pdfURLExtractor) getCaption() []notionapi.RichText { return e.block.Pdf.Caption }
+func (e pdfURLExtractor) getBlockID() notionapi.BlockID    { return e.block.ID }
 
 type videoURLExtractor struct{ block *notionapi.VideoBlock }
 
@@ -243,9 +405,24 @@ func (e videoURLExtractor) getFileURL() (string, bool) {
 	return "", false
 }
 func (e videoURLExtractor) getCaption() []notionapi.RichText { return e.block.Video.Caption }
+func (e videoURLExtractor) getBlockID() notionapi.BlockID    { return e.block.ID }
+
+// fileURLExtractorFor returns the file URL extractor of file-like blocks
+func fileURLExtractorFor(block notionapi.Block) (fileURLExtractor, bool) {
+	switch b := block.(type) {
+	case *notionapi.ImageBlock:
+		return imageURLExtractor{b}, true
+	case *notionapi.FileBlock:
+		return fileURLExtractorImpl{b}, true
+	case *notionapi.PdfBlock:
+		return pdfURLExtractor{b}, true
+	case *notionapi.VideoBlock:
+		return videoURLExtractor{b}, true
+	}
+	return nil, false
+}
 
-func processFileURLWithCache(extractor fileURLExtractor, fileCache *FileCache, articlePath string) (url, text string) {
-	var shouldCache bool
+func processFileURLWithCache(extractor fileURLExtractor, fileCache *FileCache, articlePath string, config *RenderConfig) (url, text string) {
 	originalURL, shouldCache := extractor.getFileURL()
 
 	if originalURL == "" {
@@ -255,45 +432,77 @@ func processFileURLWithCache(extractor fileURLExtractor, fileCache *FileCache, a
 	// Extract text from caption using original URL
 	caption := extractor.getCaption()
 	if len(caption) > 0 {
-		text = captionFirstParagraph(caption, nil)
+		text = captionLabel(caption, config)
 	}
 	if text == "" {
-		text = escapeMarkdown(shortenURLLabel(originalURL))
+		text = escapeMarkdown(shortenURLLabel(originalURL, config.labelLength()))
 	}
 
-	// Cache the file only if it's a Notion-hosted file
+	// Cache Notion-hosted files, and external ones when asked to
+	if !shouldCache && config.CacheExternalAssets {
+		shouldCache = cacheableExternalURL(originalURL)
+	}
 	url = originalURL
 	if shouldCache && fileCache != nil && articlePath != "" {
-		if cachedPath, err := fileCache.CacheFile(originalURL, articlePath); err == nil {
+		if cachedPath, err := fileCache.cacheBlockFile(originalURL, extractor.getBlockID(), articlePath); err == nil {
 			url = cachedPath
+		} else if errors.Is(err, errFileTooLarge) {
+			slog.Warn("⚠️ File exceeds max_file_size, using original URL", "error", err)
+		} else {
+			// If caching fails, fall back to original URL
+			slog.Warn("⚠️ Failed to cache file, using original URL", "error", err)
+			fileCache.recordFailure(originalURL, err)
 		}
-		// If caching fails, fall back to original URL
 	}
 
 	return url, text
 }
 
-func imageToMarkdownWithCache(b *notionapi.ImageBlock, fileCache *FileCache, articlePath string) string {
-	url, alt := processFileURLWithCache(imageURLExtractor{b}, fileCache, articlePath)
+// cacheableExternalURL reports whether an external file URL can be
+// downloaded, rather than pointing at a video player or other embed page
+func cacheableExternalURL(u string) bool {
+	if _, ok := embedProvider(u); ok {
+		return false
+	}
+	return strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "http://")
+}
+
+func imageToMarkdownWithCache(b *notionapi.ImageBlock, fileCache *FileCache, articlePath string, config *RenderConfig) string {
+	url, alt := processFileURLWithCache(imageURLExtractor{b}, fileCache, articlePath, config)
 	if url == "" {
 		return ""
 	}
+	template := config.ImageTemplate
+	// Images downloaded next to the page may use their own template, e.g.
+	// a tag that only resolves files in the page's asset folder
+	if original, _ := (imageURLExtractor{b}).getFileURL(); url != original && config.LocalImageTemplate != "" {
+		template = config.LocalImageTemplate
+	}
+	if template != "" {
+		return renderTemplate(template, map[string]string{"URL": url, "Alt": alt, "QuotedAlt": quoteTagArg(alt)})
+	}
 	return "![" + escapeMarkdown(alt) + "](" + url + ")"
 }
 
+// quoteTagArg wraps s in double quotes as a single argument of a template
+// tag such as Hexo's {% asset_img %}, which has no escape for quotes.
+func quoteTagArg(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "'") + `"`
+}
+
 // renderLinkWithCaption creates a markdown link with optional caption text
-func renderLinkWithCaption(url string, caption []notionapi.RichText) string {
+func renderLinkWithCaption(url string, caption []notionapi.RichText, config *RenderConfig) string {
 	if len(caption) > 0 {
-		text := captionFirstParagraph(caption, nil)
+		text := captionLabel(caption, config)
 		if text != "" {
 			return "[" + text + "](" + url + ")"
 		}
 	}
-	return "[" + escapeMarkdown(shortenURLLabel(url)) + "](" + url + ")"
+	return "[" + escapeMarkdown(shortenURLLabel(url, config.labelLength())) + "](" + url + ")"
 }
 
-func bookmarkToMarkdown(b *notionapi.BookmarkBlock) string {
-	return renderLinkWithCaption(b.Bookmark.URL, b.Bookmark.Caption)
+func bookmarkToMarkdown(b *notionapi.BookmarkBlock, config *RenderConfig) string {
+	return renderLinkWithCaption(b.Bookmark.URL, b.Bookmark.Caption, config)
 }
 
 func tableToMarkdown(block *notionapi.TableBlock, childContent string) string {
@@ -312,15 +521,7 @@ func tableToMarkdown(block *notionapi.TableBlock, childContent string) string {
 		if strings.TrimSpace(ln) == "" {
 			continue
 		}
-		var parts []string
-		if strings.Contains(ln, " | ") {
-			parts = strings.Split(ln, " | ")
-		} else {
-			parts = strings.Split(ln, "|")
-		}
-		for i := range parts {
-			parts[i] = strings.TrimSpace(parts[i])
-		}
+		parts := splitCells(ln)
 		if len(parts) > maxCols {
 			maxCols = len(parts)
 		}
@@ -329,6 +530,16 @@ func tableToMarkdown(block *notionapi.TableBlock, childContent string) string {
 	if len(parsed) == 0 {
 		return ""
 	}
+	if block.Table.HasRowHeader {
+		for i, parts := range parsed {
+			if i == 0 && block.Table.HasColumnHeader {
+				continue
+			}
+			if first := parts[0]; first != "" && !(strings.HasPrefix(first, "**") && strings.HasSuffix(first, "**")) {
+				parts[0] = "**" + first + "**"
+			}
+		}
+	}
 	normalized := make([]string, 0, len(parsed))
 	for _, parts := range parsed {
 		if len(parts) < maxCols {
@@ -344,6 +555,14 @@ func tableToMarkdown(block *notionapi.TableBlock, childContent string) string {
 			sep[i] = "---"
 		}
 		header := normalized[0]
+		// A header row without text would render as an empty bar
+		if strings.Trim(header, "| ") == "" {
+			names := make([]string, maxCols)
+			for i := range names {
+				names[i] = "Col" + strconv.Itoa(i+1)
+			}
+			header = "| " + strings.Join(names, " | ") + " |"
+		}
 		rest := ""
 		if len(normalized) > 1 {
 			rest = "\n" + strings.Join(normalized[1:], "\n")
@@ -353,150 +572,338 @@ func tableToMarkdown(block *notionapi.TableBlock, childContent string) string {
 	return strings.Join(normalized, "\n")
 }
 
-func tableRowToMarkdown(block *notionapi.TableRowBlock, resolve func(string) string) string {
+func tableRowToMarkdown(block *notionapi.TableRowBlock, resolve func(string) string, config *RenderConfig) string {
 	cells := block.TableRow.Cells
 	if len(cells) == 0 {
 		return ""
 	}
 	cols := make([]string, 0, len(cells))
 	for _, cell := range cells {
-		cols = append(cols, strings.TrimSpace(richTextArrToMarkdown(cell, resolve)))
+		// A bare "|", e.g. in an equation like $|x|$, would end the cell
+		text := strings.TrimSpace(richTextArrToMarkdown(cell, resolve, config))
+		cols = append(cols, escapeCellPipes(text))
 	}
 	return strings.Join(cols, " | ")
 }
 
-func embedToMarkdown(b *notionapi.EmbedBlock, config *RenderConfig) string {
+// splitCells splits a row rendered by tableRowToMarkdown into its trimmed
+// cells at the "|" characters that are not escaped
+func splitCells(row string) []string {
+	var cells []string
+	start := 0
+	escaped := false
+	for i, r := range row {
+		if r == '|' && !escaped {
+			cells = append(cells, strings.TrimSpace(row[start:i]))
+			start = i + 1
+		}
+		escaped = r == '\\' && !escaped
+	}
+	return append(cells, strings.TrimSpace(row[start:]))
+}
+
+// escapeCellPipes escapes the "|" characters in s that are not escaped yet
+func escapeCellPipes(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if r == '|' && !escaped {
+			b.WriteByte('\\')
+		}
+		escaped = r == '\\' && !escaped
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func embedToMarkdown(b *notionapi.EmbedBlock, resolve func(string) string, config *RenderConfig) string {
 	url := b.Embed.URL
 	text := ""
 	if len(b.Embed.Caption) > 0 {
-		text = captionFirstParagraph(b.Embed.Caption, nil)
+		text = captionLabel(b.Embed.Caption, config)
 	}
 	if text == "" {
-		text = escapeMarkdown(shortenURLLabel(url))
+		text = escapeMarkdown(shortenURLLabel(url, config.labelLength()))
 	}
 
 	data := map[string]string{
-		"URL":  url,
-		"Text": text,
+		"URL":     url,
+		"Text":    text,
+		"Caption": captionText(b.Embed.Caption, resolve, config),
 	}
-	return renderTemplate(config.EmbedTemplate, data)
+	template := config.EmbedTemplate
+	if t, ids, ok := providerTemplate(url, config); ok {
+		template = t
+		maps.Copy(data, ids)
+	}
+	return withVisibleCaption(renderTemplate(template, data), data["Caption"], config)
 }
 
-func columnListToMarkdown(b *notionapi.ColumnListBlock, childContent string) string {
+// columnListToMarkdown renders the columns in childContent. widths holds
+// the width ratio of each column; missing ratios share what the others
+// leave, equally.
+func columnListToMarkdown(b *notionapi.ColumnListBlock, childContent string, widths []float64, config *RenderConfig) string {
 	_ = b
 	if strings.TrimSpace(childContent) == "" {
 		return ""
 	}
 	childContent = dedentChildContent(childContent)
 	parts := strings.Split(childContent, "__COLUMN_BREAK__")
+	ratios := columnRatios(widths, parts)
+
 	cols := make([]string, 0, len(parts))
-	for _, p := range parts {
+	for i, p := range parts {
 		p = strings.TrimSpace(p)
 		if p == "" {
 			continue
 		}
+		if config.ColumnsTemplate != "" {
+			if config.ColumnTemplate != "" {
+				p = renderTemplate(config.ColumnTemplate, map[string]string{"Content": p, "Width": columnWidth(ratios[i])})
+			}
+			cols = append(cols, p)
+			continue
+		}
 		cols = append(cols, "<td>\n\n"+p+"\n</td>")
 	}
 	if len(cols) == 0 {
 		return ""
 	}
+	if config.ColumnsTemplate != "" {
+		return renderTemplate(config.ColumnsTemplate, map[string]string{"Content": strings.Join(cols, "\n")})
+	}
 	return "<table><tr>" + strings.Join(cols, "") + "</tr></table>"
 }
 
+// columnRatios returns the width ratio of each part of a column list's
+// content. Columns without a ratio share what the others leave equally;
+// without any ratios, the non-empty columns share the whole width.
+func columnRatios(widths []float64, parts []string) []float64 {
+	ratios := make([]float64, len(parts))
+	if len(widths) == 0 {
+		n := 0
+		for _, p := range parts {
+			if strings.TrimSpace(p) != "" {
+				n++
+			}
+		}
+		for i := range ratios {
+			ratios[i] = 1 / float64(max(n, 1))
+		}
+		return ratios
+	}
+	left, missing := 1.0, 0
+	for _, w := range widths {
+		if w > 0 {
+			left -= w
+		} else {
+			missing++
+		}
+	}
+	for i := range ratios {
+		if i < len(widths) {
+			ratios[i] = widths[i]
+			if ratios[i] <= 0 {
+				ratios[i] = max(left, 0) / float64(missing)
+			}
+		}
+	}
+	return ratios
+}
+
+// columnWidth formats a column's width ratio as a percentage for the
+// {{.Width}} placeholder, e.g. "50" or "33.33".
+func columnWidth(ratio float64) string {
+	return strconv.FormatFloat(math.Round(ratio*10000)/100, 'f', -1, 64)
+}
+
 func columnToMarkdown(b *notionapi.ColumnBlock, childContent string) string {
 	_ = b
 	return dedentChildContent(childContent)
 }
 
-func linkPreviewToMarkdown(b *notionapi.LinkPreviewBlock) string {
-	text := shortenURLLabel(b.LinkPreview.URL)
+func linkPreviewToMarkdown(b *notionapi.LinkPreviewBlock, config *RenderConfig) string {
+	text := shortenURLLabel(b.LinkPreview.URL, config.labelLength())
 	return "[" + escapeMarkdown(text) + "](" + b.LinkPreview.URL + ")"
 }
 
-func fileToMarkdownWithCache(b *notionapi.FileBlock, fileCache *FileCache, articlePath string, config *RenderConfig) string {
-	url, text := processFileURLWithCache(fileURLExtractorImpl{b}, fileCache, articlePath)
+func fileToMarkdownWithCache(b *notionapi.FileBlock, resolve func(string) string, fileCache *FileCache, articlePath string, config *RenderConfig) string {
+	url, text := processFileURLWithCache(fileURLExtractorImpl{b}, fileCache, articlePath, config)
 	if url == "" {
 		return ""
 	}
 
 	data := map[string]string{
-		"URL":  url,
-		"Text": text,
+		"URL":     url,
+		"Text":    text,
+		"Caption": captionText(b.File.Caption, resolve, config),
 	}
-	return renderTemplate(config.FileTemplate, data)
+	return withVisibleCaption(renderTemplate(config.FileTemplate, data), data["Caption"], config)
 }
 
-func pdfToMarkdownWithCache(b *notionapi.PdfBlock, fileCache *FileCache, articlePath string, config *RenderConfig) string {
-	url, text := processFileURLWithCache(pdfURLExtractor{b}, fileCache, articlePath)
+func pdfToMarkdownWithCache(b *notionapi.PdfBlock, resolve func(string) string, fileCache *FileCache, articlePath string, config *RenderConfig) string {
+	url, text := processFileURLWithCache(pdfURLExtractor{b}, fileCache, articlePath, config)
 	if url == "" {
 		return ""
 	}
 
 	data := map[string]string{
-		"URL":  url,
-		"Text": text,
+		"URL":     url,
+		"Text":    text,
+		"Caption": captionText(b.Pdf.Caption, resolve, config),
 	}
-	return renderTemplate(config.PDFTemplate, data)
+	return withVisibleCaption(renderTemplate(config.PDFTemplate, data), data["Caption"], config)
 }
 
-func videoToMarkdownWithCache(b *notionapi.VideoBlock, fileCache *FileCache, articlePath string, config *RenderConfig) string {
-	url, text := processFileURLWithCache(videoURLExtractor{b}, fileCache, articlePath)
+func videoToMarkdownWithCache(b *notionapi.VideoBlock, resolve func(string) string, fileCache *FileCache, articlePath string, config *RenderConfig) string {
+	url, text := processFileURLWithCache(videoURLExtractor{b}, fileCache, articlePath, config)
 	if url == "" {
 		return ""
 	}
 
 	data := map[string]string{
-		"URL":  url,
-		"Text": text,
+		"URL":     url,
+		"Text":    text,
+		"Caption": captionText(b.Video.Caption, resolve, config),
+	}
+	template := config.VideoTemplate
+	// External YouTube or Vimeo videos use the provider's shortcode; uploaded
+	// files are always cached and rendered as videos
+	if b.Video.External != nil {
+		if t, ids, ok := providerTemplate(url, config); ok {
+			template = t
+			maps.Copy(data, ids)
+		}
 	}
-	return renderTemplate(config.VideoTemplate, data)
+	return withVisibleCaption(renderTemplate(template, data), data["Caption"], config)
 }
 
-func richTextArrToMarkdown(arr []notionapi.RichText, resolve func(string) string) string {
-	result := ""
+func richTextArrToMarkdown(arr []notionapi.RichText, resolve func(string) string, config *RenderConfig) string {
+	var result strings.Builder
 	for _, t := range arr {
 		txt := t.PlainText
+		if t.Equation != nil && t.Equation.Expression != "" {
+			result.WriteString(renderTemplate(config.InlineMathTemplate, map[string]string{"Expression": t.Equation.Expression}))
+			continue
+		}
+		if t.Mention != nil {
+			var done bool
+			txt, done = mentionToMarkdown(t, resolve, config)
+			if done {
+				result.WriteString(txt)
+				continue
+			}
+		}
 		if t.Href != "" {
 			url := t.Href
 			// If the link points to a Notion page, convert it to a Hugo site link.
 			// Use resolver when available.
 			if resolve != nil {
 				// the notionURLToHugoLink will attempt to extract an ID and call resolve
-				url = notionURLToHugoLink(url, resolve)
+				url = notionURLToHugoLink(url, resolve, config.LinkBasePrefix)
 			} else {
-				url = notionURLToHugoLink(url, nil)
+				url = notionURLToHugoLink(url, nil, config.LinkBasePrefix)
 			}
-			txt = "[" + escapeMarkdown(richTextAnnotationsToMarkdown(t)) + "](" + url + ")"
-			result += txt
-			continue
-		}
-		if t.Annotations.Code {
-			result += "`" + escapeBackticks(txt) + "`"
+			if url == "" {
+				// The page is left out of the export, keep just the text
+				result.WriteString(annotateText(txt, t.Annotations))
+				continue
+			}
+			result.WriteString("[" + escapeMarkdown(richTextAnnotationsToMarkdown(t)) + "](" + url + ")")
 			continue
 		}
-		wrapped := txt
-		if t.Annotations.Bold {
-			wrapped = "**" + wrapped + "**"
+		result.WriteString(annotateText(txt, t.Annotations))
+	}
+	return result.String()
+}
+
+// NotExported is returned by a resolver for pages that are known but left out
+// of the export, such as skipped expired pages. Links to them are rendered as
+// plain text rather than guessed from the page title.
+const NotExported = "-"
+
+// mentionTypeLinkMention is Notion's inline link preview mention. The SDK does
+// not define it and drops its metadata, so the link is built from the rich
+// text's href and plain text (the page title when Notion provides one).
+const mentionTypeLinkMention notionapi.MentionType = "link_mention"
+
+// mentionToMarkdown returns the text for an inline mention. Mentions that can
+// be rendered better than Notion's plain text (dates, users, databases, links) are
+// formatted according to the config; others keep their plain text. The boolean
+// result reports whether the text is final markup that must not be wrapped in
+// the rich text's href link.
+func mentionToMarkdown(t notionapi.RichText, resolve func(string) string, config *RenderConfig) (string, bool) {
+	m := t.Mention
+	switch m.Type {
+	case notionapi.MentionTypeDate:
+		if m.Date != nil {
+			return formatDateMention(m.Date, config), false
 		}
-		if t.Annotations.Italic {
-			wrapped = "*" + wrapped + "*"
+	case notionapi.MentionTypeUser:
+		if m.User != nil {
+			name := m.User.Name
+			if name == "" {
+				name = strings.TrimPrefix(t.PlainText, "@")
+			}
+			data := map[string]string{
+				"Name": name,
+				"Slug": slugify(name),
+				"ID":   string(m.User.ID),
+			}
+			return renderTemplate(config.UserMentionTemplate, data), false
 		}
-		if t.Annotations.Strikethrough {
-			wrapped = "~~" + wrapped + "~~"
+	case notionapi.MentionTypeDatabase:
+		if m.Database != nil {
+			id := strings.ReplaceAll(string(m.Database.ID), "-", "")
+			// Link to the database when it is itself part of the export
+			if resolve != nil {
+				if path := resolve(id); path != "" {
+					return "[" + escapeMarkdown(richTextAnnotationsToMarkdown(t)) + "](" + path + ")", true
+				}
+			}
+			data := map[string]string{
+				"Text": t.PlainText,
+				"ID":   id,
+				"URL":  "https://www.notion.so/" + id,
+			}
+			return annotateText(renderTemplate(config.DatabaseMentionTemplate, data), t.Annotations), true
 		}
-		if t.Annotations.Underline {
-			wrapped = "<u>" + wrapped + "</u>"
+	case mentionTypeLinkMention:
+		if t.Href != "" {
+			label := t.PlainText
+			if label == "" || label == t.Href {
+				label = shortenURLLabel(t.Href, config.labelLength())
+			}
+			return "[" + escapeMarkdown(annotateText(label, t.Annotations)) + "](" + t.Href + ")", true
 		}
-		result += wrapped
 	}
-	return result
+	return t.PlainText, false
+}
+
+// formatDateMention renders an inline date mention using the configured
+// DateFormat. Date ranges are rendered as "start → end".
+func formatDateMention(d *notionapi.DateObject, config *RenderConfig) string {
+	if d.Start == nil {
+		return ""
+	}
+	layout := config.DateFormat
+	if layout == "" {
+		layout = DefaultDateFormat
+	}
+	formatted := time.Time(*d.Start).Format(layout)
+	if d.End != nil {
+		formatted += " → " + time.Time(*d.End).Format(layout)
+	}
+	return formatted
 }
 
 // notionURLToHugoLink converts a Notion page URL to a site-relative link
 // for static site generators when possible. Example: https://www.notion.so/Workspace-Page-Title-<uuid>
 // becomes the appropriate path based on the page type (posts, gallery, etc.).
 // If the URL does not look like a Notion page link it is returned unchanged.
-func notionURLToHugoLink(raw string, resolve func(string) string) string {
+// basePrefix is prepended to the fallback path of unresolved pages. Pages the
+// resolver reports as NotExported give an empty link.
+func notionURLToHugoLink(raw string, resolve func(string) string, basePrefix string) string {
 	if raw == "" {
 		return raw
 	}
@@ -556,8 +963,12 @@ func notionURLToHugoLink(raw string, resolve func(string) string) string {
 
 	// If we have a resolver, try to resolve the UUID to the correct path
 	if resolve != nil {
-		if resolvedPath := resolve(normalizedUUID); resolvedPath != "" {
-			return resolvedPath
+		resolvedPath := resolve(normalizedUUID)
+		if resolvedPath == NotExported {
+			return ""
+		}
+		if resolvedPath != "" {
+			return withBasePrefix(resolvedPath, basePrefix)
 		}
 	}
 
@@ -578,25 +989,32 @@ func notionURLToHugoLink(raw string, resolve func(string) string) string {
 	}
 
 	// Default fallback to posts path if resolver failed
-	return "/posts/" + slug + "/"
+	return withBasePrefix("/posts/"+slug+"/", basePrefix)
 }
 
 func richTextAnnotationsToMarkdown(t notionapi.RichText) string {
-	txt := t.PlainText
-	if t.Annotations.Code {
+	return annotateText(t.PlainText, t.Annotations)
+}
+
+// annotateText wraps txt in the Markdown syntax matching its annotations
+func annotateText(txt string, a *notionapi.Annotations) string {
+	if a == nil {
+		return txt
+	}
+	if a.Code {
 		return "`" + escapeBackticks(txt) + "`"
 	}
 	wrapped := txt
-	if t.Annotations.Bold {
+	if a.Bold {
 		wrapped = "**" + wrapped + "**"
 	}
-	if t.Annotations.Italic {
+	if a.Italic {
 		wrapped = "*" + wrapped + "*"
 	}
-	if t.Annotations.Strikethrough {
+	if a.Strikethrough {
 		wrapped = "~~" + wrapped + "~~"
 	}
-	if t.Annotations.Underline {
+	if a.Underline {
 		wrapped = "<u>" + wrapped + "</u>"
 	}
 	return wrapped
@@ -625,65 +1043,64 @@ func dedentChildContent(childContent string) string {
 	return strings.Join(lines, "\n")
 }
 
-func shortenURLLabel(raw string) string {
+func shortenURLLabel(raw string, max int) string {
 	if raw == "" {
 		return ""
 	}
 
-	max := 40
-
 	// Try to parse as URL first
 	if u, err := url.Parse(raw); err == nil && u.Scheme != "" {
-		// Extract filename from path
-		filename := filepath.Base(u.Path)
+		// Extract filename from path, decoding names Notion encodes twice
+		path := decodePercent(u.Path)
+		filename := filepath.Base(path)
 
 		// Check if it's a meaningful filename (has extension and not just "/")
 		if filename != "." && filename != "/" && strings.Contains(filename, ".") {
 			// If filename is short enough, show domain + filename
-			if len(filename) <= max-10 && u.Host != "" {
-				if len(u.Host)+len(filename)+5 <= max { // +5 for "/.../""
+			if runeLen(filename) <= max-10 && u.Host != "" {
+				if len(u.Host)+runeLen(filename)+5 <= max { // +5 for "/.../""
 					return u.Host + "/.../" + filename
 				}
 			}
 
 			// If just filename fits, show it
-			if len(filename) <= max {
+			if runeLen(filename) <= max {
 				return filename
 			}
 
 			// Show truncated filename, keeping extension if possible
-			if len(filename) > max-3 {
-				ext := filepath.Ext(filename)
-				if len(ext) <= 8 && len(ext) > 0 {
-					nameLen := max - len(ext) - 3
-					if nameLen > 0 {
-						base := filename[:len(filename)-len(ext)]
-						if len(base) > nameLen {
-							return base[:nameLen] + "..." + ext
-						}
-					}
+			ext := filepath.Ext(filename)
+			if len(ext) <= 8 && len(ext) > 0 {
+				nameLen := max - runeLen(ext) - 3
+				if nameLen > 0 {
+					base := filename[:len(filename)-len(ext)]
+					return headRunes(base, nameLen) + "..." + ext
 				}
-				return filename[:max-3] + "..."
 			}
+			return headRunes(filename, max-3) + "..."
 		}
 
 		// Fallback to host + path logic
 		if u.Host != "" {
 			// Remove protocol, show clean URL
-			cleanURL := u.Host + u.Path
+			cleanURL := u.Host + path
 			if u.RawQuery != "" {
-				cleanURL += "?" + u.RawQuery
+				query, err := url.QueryUnescape(u.RawQuery)
+				if err != nil {
+					query = u.RawQuery
+				}
+				cleanURL += "?" + query
 			}
 
-			if len(cleanURL) <= max {
+			if runeLen(cleanURL) <= max {
 				return cleanURL
 			}
 
 			// Try host + first path segment
-			pathParts := strings.Split(strings.Trim(u.Path, "/"), "/")
+			pathParts := strings.Split(strings.Trim(path, "/"), "/")
 			if len(pathParts) > 0 && pathParts[0] != "" {
 				candidate := u.Host + "/" + pathParts[0]
-				if len(candidate) <= max {
+				if runeLen(candidate) <= max {
 					return candidate + "..."
 				}
 			}
@@ -696,21 +1113,79 @@ func shortenURLLabel(raw string) string {
 	}
 
 	// Fallback for non-URLs or unparseable URLs
-	if len(raw) <= max {
+	if runeLen(raw) <= max {
 		return raw
 	}
 
-	return raw[:max-3] + "..."
+	return headRunes(raw, max-3) + "..."
 }
 
-func captionFirstParagraph(arr []notionapi.RichText, resolve func(string) string) string {
-	if len(arr) == 0 {
+// decodePercent undoes percent-encoding, repeatedly for values encoded more
+// than once. Invalid escapes are left as they are.
+func decodePercent(s string) string {
+	for i := 0; i < 3; i++ {
+		decoded, err := url.PathUnescape(s)
+		if err != nil || decoded == s {
+			break
+		}
+		s = decoded
+	}
+	return s
+}
+
+// runeLen returns the number of characters in s
+func runeLen(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// headRunes returns the first n characters of s, never splitting one
+func headRunes(s string, n int) string {
+	if n <= 0 {
 		return ""
 	}
-	full := richTextArrToMarkdown(arr, resolve)
-	parts := strings.Split(full, "\n\n")
-	if len(parts) == 0 {
-		return strings.TrimSpace(full)
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// captionText renders a full caption as Markdown for the {{.Caption}}
+// placeholder.
+func captionText(arr []notionapi.RichText, resolve func(string) string, config *RenderConfig) string {
+	return strings.TrimSpace(richTextArrToMarkdown(arr, resolve, config))
+}
+
+// withVisibleCaption appends the caption as a separate line beneath an
+// embedded element when a CaptionTemplate is configured.
+func withVisibleCaption(markdown, caption string, config *RenderConfig) string {
+	if config.CaptionTemplate == "" || markdown == "" || caption == "" {
+		return markdown
+	}
+	// The template is applied to each paragraph, so inline markup like
+	// "*{{.Caption}}*" does not span a paragraph break
+	paragraphs := strings.Split(caption, "\n\n")
+	for i, p := range paragraphs {
+		paragraphs[i] = renderTemplate(config.CaptionTemplate, map[string]string{"Caption": strings.TrimSpace(p)})
+	}
+	return markdown + "\n\n" + strings.Join(paragraphs, "\n\n")
+}
+
+// captionLabel renders a caption for use as link text or alt text: its first
+// paragraph, or all paragraphs joined by spaces with FullCaptions. Links are
+// left out, since link text cannot contain links; the {{.Caption}} text keeps
+// them.
+func captionLabel(arr []notionapi.RichText, config *RenderConfig) string {
+	var b strings.Builder
+	for _, t := range arr {
+		b.WriteString(annotateText(t.PlainText, t.Annotations))
+	}
+	text := b.String()
+	if !config.FullCaptions {
+		first, _, _ := strings.Cut(text, "\n\n")
+		return strings.TrimSpace(first)
 	}
-	return strings.TrimSpace(parts[0])
+	return strings.Join(strings.Fields(text), " ")
 }
@@ -0,0 +1,490 @@
+package renderer
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+func dateRef(t time.Time) *notionapi.Date {
+	d := notionapi.Date(t)
+	return &d
+}
+
+func TestRichTextArrToMarkdown_DateMention(t *testing.T) {
+	start := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	end := time.Date(2025, 3, 5, 18, 0, 0, 0, time.UTC)
+
+	arr := []notionapi.RichText{
+		{PlainText: "Due "},
+		{
+			PlainText: "March 1, 2025 9:30 AM",
+			Mention: &notionapi.Mention{
+				Type: notionapi.MentionTypeDate,
+				Date: &notionapi.DateObject{Start: dateRef(start)},
+			},
+		},
+	}
+
+	config := DefaultRenderConfig()
+	if got := richTextArrToMarkdown(arr, nil, config); got != "Due 2025-03-01" {
+		t.Errorf("Expected 'Due 2025-03-01', got '%s'", got)
+	}
+
+	// Ranges use both ends and the configured layout
+	arr[1].Mention.Date.End = dateRef(end)
+	config.DateFormat = "Jan 2, 2006"
+	expected := "Due Mar 1, 2025 → Mar 5, 2025"
+	if got := richTextArrToMarkdown(arr, nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestRichTextArrToMarkdown_UserMention(t *testing.T) {
+	arr := []notionapi.RichText{
+		{PlainText: "Written by "},
+		{
+			PlainText: "@Jane Doe",
+			Mention: &notionapi.Mention{
+				Type: notionapi.MentionTypeUser,
+				User: &notionapi.User{ID: "user-1", Name: "Jane Doe"},
+			},
+		},
+	}
+
+	config := DefaultRenderConfig()
+	if got := richTextArrToMarkdown(arr, nil, config); got != "Written by @Jane Doe" {
+		t.Errorf("Expected default plain name, got '%s'", got)
+	}
+
+	config.UserMentionTemplate = "[{{.Name}}](/authors/{{.Slug}}/)"
+	expected := "Written by [Jane Doe](/authors/jane-doe/)"
+	if got := richTextArrToMarkdown(arr, nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestRichTextArrToMarkdown_DatabaseMention(t *testing.T) {
+	mention := notionapi.RichText{
+		PlainText: "Reading List",
+		Href:      "https://www.notion.so/0123456789abcdef0123456789abcdef",
+		Mention: &notionapi.Mention{
+			Type:     notionapi.MentionTypeDatabase,
+			Database: &notionapi.DatabaseMention{ID: "01234567-89ab-cdef-0123-456789abcdef"},
+		},
+	}
+	arr := []notionapi.RichText{{PlainText: "See "}, mention}
+
+	resolve := func(id string) string {
+		if id == "0123456789abcdef0123456789abcdef" {
+			return "/reading-list/"
+		}
+		return ""
+	}
+
+	config := DefaultRenderConfig()
+	expected := "See [Reading List](/reading-list/)"
+	if got := richTextArrToMarkdown(arr, resolve, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	// Without a resolver hit the configurable label is used
+	if got := richTextArrToMarkdown(arr, nil, config); got != "See Reading List" {
+		t.Errorf("Expected plain label, got '%s'", got)
+	}
+	config.DatabaseMentionTemplate = "[{{.Text}}]({{.URL}})"
+	expected = "See [Reading List](https://www.notion.so/0123456789abcdef0123456789abcdef)"
+	if got := richTextArrToMarkdown(arr, nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestRichTextArrToMarkdown_LinkMention(t *testing.T) {
+	arr := []notionapi.RichText{
+		{PlainText: "Read "},
+		{
+			PlainText: "The Go Blog",
+			Href:      "https://go.dev/blog/",
+			Mention:   &notionapi.Mention{Type: "link_mention"},
+		},
+	}
+
+	config := DefaultRenderConfig()
+	expected := "Read [The Go Blog](https://go.dev/blog/)"
+	if got := richTextArrToMarkdown(arr, nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	// Without a title the URL is used as the label
+	arr[1].PlainText = "https://go.dev/blog/"
+	expected = "Read [go.dev/blog/](https://go.dev/blog/)"
+	if got := richTextArrToMarkdown(arr, nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestVideoToMarkdown_VisibleCaption(t *testing.T) {
+	block := &notionapi.VideoBlock{
+		Video: notionapi.Video{
+			Caption:  []notionapi.RichText{{PlainText: "Launch demo", Text: &notionapi.Text{Content: "Launch demo"}}},
+			External: &notionapi.FileObject{URL: "https://example.com/demo.mp4"},
+		},
+	}
+
+	config := DefaultRenderConfig()
+	expected := `{{< video src="https://example.com/demo.mp4" >}}`
+	if got := videoToMarkdownWithCache(block, nil, nil, "", config); got != expected {
+		t.Errorf("Expected caption to be hidden by default, got '%s'", got)
+	}
+
+	config.CaptionTemplate = "*{{.Caption}}*"
+	expected = "{{< video src=\"https://example.com/demo.mp4\" >}}\n\n*Launch demo*"
+	if got := videoToMarkdownWithCache(block, nil, nil, "", config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	// The caption is also available to the element template itself
+	config.CaptionTemplate = ""
+	config.VideoTemplate = `<figure><video src="{{.URL}}"></video><figcaption>{{.Caption}}</figcaption></figure>`
+	expected = `<figure><video src="https://example.com/demo.mp4"></video><figcaption>Launch demo</figcaption></figure>`
+	if got := videoToMarkdownWithCache(block, nil, nil, "", config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestColumnListToMarkdown_Template(t *testing.T) {
+	block := &notionapi.ColumnListBlock{}
+	childContent := "Left\n__COLUMN_BREAK__\nRight\n__COLUMN_BREAK__\n"
+
+	config := DefaultRenderConfig()
+	expected := "<table><tr><td>\n\nLeft\n</td><td>\n\nRight\n</td></tr></table>"
+	if got := columnListToMarkdown(block, childContent, nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	config.ColumnsTemplate = "{{< columns >}}\n{{.Content}}\n{{< /columns >}}"
+	config.ColumnTemplate = "{{< column >}}\n{{.Content}}\n{{< /column >}}"
+	expected = "{{< columns >}}\n{{< column >}}\nLeft\n{{< /column >}}\n{{< column >}}\nRight\n{{< /column >}}\n{{< /columns >}}"
+	if got := columnListToMarkdown(block, childContent, nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	// Widths are percentages of the column list, shared equally by default
+	config.ColumnTemplate = `<div style="width: {{.Width}}%">{{.Content}}</div>`
+	expected = "{{< columns >}}\n<div style=\"width: 50%\">Left</div>\n<div style=\"width: 50%\">Right</div>\n{{< /columns >}}"
+	if got := columnListToMarkdown(block, childContent, nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+	threeCols := "A\n__COLUMN_BREAK__\nB\n__COLUMN_BREAK__\nC\n__COLUMN_BREAK__\n"
+	expected = "{{< columns >}}\n<div style=\"width: 25%\">A</div>\n<div style=\"width: 37.5%\">B</div>\n<div style=\"width: 37.5%\">C</div>\n{{< /columns >}}"
+	if got := columnListToMarkdown(block, threeCols, []float64{0.25, 0, 0}, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestToggleToMarkdown_MarkdownStyle(t *testing.T) {
+	block := &notionapi.ToggleBlock{
+		Toggle: notionapi.Toggle{RichText: []notionapi.RichText{{PlainText: "More", Text: &notionapi.Text{Content: "More"}}}},
+	}
+	config := DefaultRenderConfig()
+	config.ToggleStyle = ToggleStyleMarkdown
+
+	expected := "#### More\n\nFirst paragraph\n\n- nested item"
+	if got := toggleToMarkdown(block, "First paragraph\n\n- nested item", nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	expected = "#### More"
+	if got := toggleToMarkdown(block, "", nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestToDoToMarkdown_StrikeCompleted(t *testing.T) {
+	todo := func(checked bool) *notionapi.ToDoBlock {
+		return &notionapi.ToDoBlock{
+			ToDo: notionapi.ToDo{
+				RichText: []notionapi.RichText{{PlainText: "Ship it", Text: &notionapi.Text{Content: "Ship it"}}},
+				Checked:  checked,
+			},
+		}
+	}
+
+	config := DefaultRenderConfig()
+	if got := toDoToMarkdown(todo(true), "", nil, config); got != "- [x] Ship it" {
+		t.Errorf("Expected no strikethrough by default, got '%s'", got)
+	}
+
+	config.StrikeCompletedToDos = true
+	if got := toDoToMarkdown(todo(true), "", nil, config); got != "- [x] ~~Ship it~~" {
+		t.Errorf("Expected completed item to be struck through, got '%s'", got)
+	}
+	if got := toDoToMarkdown(todo(false), "", nil, config); got != "- [ ] Ship it" {
+		t.Errorf("Expected open item to be unchanged, got '%s'", got)
+	}
+}
+
+func TestCalloutToMarkdown_Obsidian(t *testing.T) {
+	emoji := notionapi.Emoji("💡")
+	block := &notionapi.CalloutBlock{
+		Callout: notionapi.Callout{
+			RichText: []notionapi.RichText{{PlainText: "Use the cache", Text: &notionapi.Text{Content: "Use the cache"}}},
+			Icon:     &notionapi.Icon{Type: "emoji", Emoji: &emoji},
+		},
+	}
+
+	config := DefaultRenderConfig()
+	config.CalloutStyle = CalloutStyleObsidian
+	expected := "> [!tip]\n> Use the cache"
+	if got := calloutToMarkdown(block, "", nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	// Without a known icon the color decides, defaulting to note
+	block.Callout.Icon = nil
+	block.Callout.Color = "red_background"
+	expected = "> [!danger]\n> Use the cache"
+	if got := calloutToMarkdown(block, "", nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+	block.Callout.Color = ""
+	expected = "> [!note]\n> Use the cache"
+	if got := calloutToMarkdown(block, "", nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	// Text spanning several lines stays inside the callout
+	block.Callout.RichText = []notionapi.RichText{{PlainText: "First line\nSecond line", Text: &notionapi.Text{Content: "First line\nSecond line"}}}
+	expected = "> [!note]\n> First line\n> Second line\n> \n> Child paragraph"
+	if got := calloutToMarkdown(block, "Child paragraph", nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestEmbedToMarkdown_ProviderTemplates(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.EmbedProviderTemplates = map[string]string{
+		ProviderYouTube: "{{< youtube {{.ID}} >}}",
+		ProviderGist:    "{{< gist {{.User}} {{.ID}} >}}",
+	}
+
+	testCases := []struct {
+		url      string
+		expected string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=42", "{{< youtube dQw4w9WgXcQ >}}"},
+		{"https://gist.github.com/spf13/7896402", "{{< gist spf13 7896402 >}}"},
+		// Providers without a template and unknown sites use the generic embed
+		{"https://vimeo.com/146022717", `{{< embed url="https://vimeo.com/146022717" >}}`},
+		{"https://example.com/widget", `{{< embed url="https://example.com/widget" >}}`},
+	}
+	for _, tc := range testCases {
+		block := &notionapi.EmbedBlock{Embed: notionapi.Embed{URL: tc.url}}
+		if got := embedToMarkdown(block, nil, config); got != tc.expected {
+			t.Errorf("For %s expected '%s', got '%s'", tc.url, tc.expected, got)
+		}
+	}
+}
+
+func TestEmbedProvider(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected embedInfo
+	}{
+		{"https://youtu.be/dQw4w9WgXcQ?si=abc", embedInfo{Provider: ProviderYouTube, ID: "dQw4w9WgXcQ"}},
+		{"https://www.youtube.com/embed/dQw4w9WgXcQ", embedInfo{Provider: ProviderYouTube, ID: "dQw4w9WgXcQ"}},
+		{"https://player.vimeo.com/video/146022717", embedInfo{Provider: ProviderVimeo, ID: "146022717"}},
+		{"https://x.com/golang/status/1234567890", embedInfo{Provider: ProviderTwitter, User: "golang", ID: "1234567890"}},
+		{"https://codepen.io/someone/pen/abcDEF", embedInfo{Provider: ProviderCodePen, User: "someone", ID: "abcDEF"}},
+	}
+	for _, tc := range testCases {
+		if got, ok := embedProvider(tc.url); !ok || got != tc.expected {
+			t.Errorf("For %s expected %+v, got %+v (%v)", tc.url, tc.expected, got, ok)
+		}
+	}
+	if _, ok := embedProvider("https://www.youtube.com/feed/subscriptions"); ok {
+		t.Error("Expected no provider for a YouTube URL without a video")
+	}
+}
+
+func TestVideoToMarkdown_ProviderShortcode(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.EmbedProviderTemplates = map[string]string{ProviderYouTube: "{{< youtube {{.ID}} >}}"}
+
+	block := &notionapi.VideoBlock{Video: notionapi.Video{External: &notionapi.FileObject{URL: "https://youtu.be/dQw4w9WgXcQ?t=10"}}}
+	if got, expected := videoToMarkdownWithCache(block, nil, nil, "", config), "{{< youtube dQw4w9WgXcQ >}}"; got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	// Other external videos keep the video template
+	block = &notionapi.VideoBlock{Video: notionapi.Video{External: &notionapi.FileObject{URL: "https://example.com/clip.mp4"}}}
+	if got, expected := videoToMarkdownWithCache(block, nil, nil, "", config), `{{< video src="https://example.com/clip.mp4" >}}`; got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestCaption_ResolvesLinks(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.CaptionTemplate = "*{{.Caption}}*"
+	resolve := func(id string) string {
+		if id == "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb" {
+			return "/docs/guide/"
+		}
+		return ""
+	}
+	caption := []notionapi.RichText{
+		{PlainText: "See the "},
+		{PlainText: "guide", Href: "https://www.notion.so/Guide-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"},
+		{PlainText: "\n\nSecond paragraph"},
+	}
+	block := &notionapi.FileBlock{File: notionapi.BlockFile{External: &notionapi.FileObject{URL: "https://example.com/report.pdf"}, Caption: caption}}
+
+	got := fileToMarkdownWithCache(block, resolve, nil, "", config)
+	// Link text cannot hold links, so only the visible caption keeps them
+	expected := "[See the guide](https://example.com/report.pdf)\n\n*See the [guide](/docs/guide/)*\n\n*Second paragraph*"
+	if got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	config.FullCaptions = true
+	config.CaptionTemplate = ""
+	got = fileToMarkdownWithCache(block, resolve, nil, "", config)
+	expected = "[See the guide Second paragraph](https://example.com/report.pdf)"
+	if got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestShortenURLLabel_DecodesFilenames(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected string
+	}{
+		{"https://example.com/files/%E6%B5%8B%E8%AF%95%E6%96%87%E6%A1%A3.pdf", "example.com/.../测试文档.pdf"},
+		// Notion encodes file names twice
+		{"https://file.notion.so/f/f/ws/abc/%25E6%25B5%258B%25E8%25AF%2595.pdf?table=block", "file.notion.so/.../测试.pdf"},
+		// Long names are cut between characters, keeping the extension
+		{"https://example.com/" + strings.Repeat("%E6%B5%8B", 50) + ".pdf", strings.Repeat("测", 33) + "....pdf"},
+	}
+	for _, tc := range testCases {
+		if got := shortenURLLabel(tc.url, DefaultMaxLabelLength); got != tc.expected {
+			t.Errorf("For %s expected '%s', got '%s'", tc.url, tc.expected, got)
+		}
+	}
+}
+
+func TestShortenURLLabel_MaxLength(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.MaxLabelLength = 20
+	raw := "https://example.com/docs/a-rather-long-document-name.pdf"
+
+	block := &notionapi.BookmarkBlock{Bookmark: notionapi.Bookmark{URL: raw}}
+	expected := "[a-rather-long....pdf](" + raw + ")"
+	if got := bookmarkToMarkdown(block, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+	if got := shortenURLLabel(raw, 80); got != "example.com/.../a-rather-long-document-name.pdf" {
+		t.Errorf("Expected the full label with a longer limit, got '%s'", got)
+	}
+}
+
+func TestCodeToMarkdown_RawHTML(t *testing.T) {
+	block := &notionapi.CodeBlock{
+		Code: notionapi.Code{
+			Language: "html",
+			RichText: []notionapi.RichText{{PlainText: "<div class=\"note\">\n  <b>Hi</b> *there*\n</div>"}},
+		},
+	}
+	config := DefaultRenderConfig()
+
+	fenced := "```html\n<div class=\"note\">\n  <b>Hi</b> *there*\n</div>\n```"
+	if got := codeToMarkdown(block, nil, config); got != fenced {
+		t.Errorf("Expected a fenced block by default, got '%s'", got)
+	}
+
+	config.RawHTMLLanguage = "HTML"
+	expected := "<div class=\"note\">\n  <b>Hi</b> *there*\n</div>"
+	if got := codeToMarkdown(block, nil, config); got != expected {
+		t.Errorf("Expected raw HTML '%s', got '%s'", expected, got)
+	}
+
+	block.Code.Language = "go"
+	if got := codeToMarkdown(block, nil, config); !strings.HasPrefix(got, "```go\n") {
+		t.Errorf("Expected other languages to stay fenced, got '%s'", got)
+	}
+}
+
+func TestDividerToMarkdown_Template(t *testing.T) {
+	config := DefaultRenderConfig()
+	if got := dividerToMarkdown(&notionapi.DividerBlock{}, config); got != "---" {
+		t.Errorf("Expected '---' by default, got '%s'", got)
+	}
+	config.DividerTemplate = "***"
+	if got := dividerToMarkdown(&notionapi.DividerBlock{}, config); got != "***" {
+		t.Errorf("Expected '***', got '%s'", got)
+	}
+}
+
+func TestTableRowToMarkdown_InlineEquation(t *testing.T) {
+	equation := func(expr string) notionapi.RichText {
+		return notionapi.RichText{PlainText: expr, Equation: &notionapi.Equation{Expression: expr}}
+	}
+	row := &notionapi.TableRowBlock{TableRow: notionapi.TableRow{Cells: [][]notionapi.RichText{
+		{{PlainText: "Energy"}},
+		{equation("E = mc^2")},
+		{{PlainText: "norm "}, equation(`|x| \| y`)},
+	}}}
+
+	config := DefaultRenderConfig()
+	expected := `Energy | $E = mc^2$ | norm $\|x\| \| y$`
+	if got := tableRowToMarkdown(row, nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	config.InlineMathTemplate = `\({{.Expression}}\)`
+	expected = `Energy | \(E = mc^2\) | norm \(\|x\| \| y\)`
+	if got := tableRowToMarkdown(row, nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+// largeRichText returns n rich text segments mixing plain, annotated and
+// linked text, as found in long paragraphs
+func largeRichText(n int) []notionapi.RichText {
+	arr := make([]notionapi.RichText, 0, n)
+	for i := range n {
+		t := notionapi.RichText{PlainText: "segment " + strconv.Itoa(i) + " "}
+		switch i % 3 {
+		case 1:
+			t.Annotations = &notionapi.Annotations{Bold: true}
+		case 2:
+			t.Href = "https://example.com/" + strconv.Itoa(i)
+		}
+		arr = append(arr, t)
+	}
+	return arr
+}
+
+func TestRichTextArrToMarkdown_LargeInput(t *testing.T) {
+	config := DefaultRenderConfig()
+	arr := largeRichText(500)
+	var expected strings.Builder
+	for _, segment := range arr {
+		expected.WriteString(richTextArrToMarkdown([]notionapi.RichText{segment}, nil, config))
+	}
+	if got := richTextArrToMarkdown(arr, nil, config); got != expected.String() {
+		t.Errorf("Expected the concatenation of all segments, got '%s'", got)
+	}
+}
+
+func BenchmarkRichTextArrToMarkdown(b *testing.B) {
+	config := DefaultRenderConfig()
+	arr := largeRichText(1000)
+	b.ReportAllocs()
+	for b.Loop() {
+		richTextArrToMarkdown(arr, nil, config)
+	}
+}
@@ -2,8 +2,12 @@ package renderer
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -24,9 +28,82 @@ type RenderConfig struct {
 	// Math equations template
 	MathTemplate string `yaml:"math_template" json:"math_template"`
 
+	// Inline equations template. Placeholder: {{.Expression}}
+	InlineMathTemplate string `yaml:"inline_math_template" json:"inline_math_template"`
+
 	// Details/Toggle blocks template
 	DetailsTemplate string `yaml:"details_template" json:"details_template"`
 
+	// Code blocks in this language (e.g. "html") are emitted verbatim,
+	// unfenced, letting pages embed raw HTML. Empty (default) fences all
+	// code blocks.
+	RawHTMLLanguage string `yaml:"raw_html_language" json:"raw_html_language"`
+
+	// Divider blocks, e.g. "***" or "<hr>" where "---" could be mistaken
+	// for a front matter delimiter. Empty uses "---".
+	DividerTemplate string `yaml:"divider_template" json:"divider_template"`
+
+	// Heading with an explicit anchor, e.g. "{{.Heading}} {#{{.ID}}}" for
+	// Hugo. Empty leaves headings unchanged.
+	HeadingAnchorTemplate string `yaml:"heading_anchor_template" json:"heading_anchor_template"`
+
+	// How toggles are rendered: ToggleStyleDetails (default) uses
+	// DetailsTemplate, ToggleStyleMarkdown emits plain Markdown without HTML:
+	// the summary as a level 4 heading followed by the content, which is not
+	// indented since Markdown would make indented text a code block
+	ToggleStyle string `yaml:"toggle_style" json:"toggle_style"`
+
+	// Marker of bulleted list items at every nesting level, also used by
+	// to-dos: "-" (default), "*" or "+"
+	BulletMarker string `yaml:"bullet_marker" json:"bullet_marker"`
+
+	// Delimiter after the number of numbered list items: "." (default) or
+	// ")"
+	NumberedListDelimiter string `yaml:"numbered_list_delimiter" json:"numbered_list_delimiter"`
+
+	// Number list items 1, 2, 3... instead of numbering every item 1 and
+	// leaving the numbering to the Markdown renderer
+	NumberedListIncrement bool `yaml:"numbered_list_increment" json:"numbered_list_increment"`
+
+	// Number of spaces nested list items are indented by. Some renderers
+	// require 2 for nested task lists.
+	ListIndent int `yaml:"list_indent" json:"list_indent"`
+
+	// Maximum length of the labels generated for bare URLs (links, files
+	// and embeds without a caption)
+	MaxLabelLength int `yaml:"max_label_length" json:"max_label_length"`
+
+	// Remove HTML tags and attributes that are not allowlisted from page
+	// bodies, for sites publishing untrusted content. Code is left as is.
+	SanitizeHTML bool `yaml:"sanitize_html" json:"sanitize_html"`
+
+	// Tags kept by SanitizeHTML, each with its allowed attributes; those
+	// under "*" are allowed on every tag. Empty uses DefaultHTMLAllowlist.
+	HTMLAllowlist map[string][]string `yaml:"html_allowlist" json:"html_allowlist"`
+
+	// How comments on blocks are exported: CommentStyleFootnotes as
+	// footnotes referenced from the commented block, CommentStyleHTML as an
+	// HTML comment at the end of the page. Empty (default) skips comments,
+	// which cost an API call per block.
+	CommentStyle string `yaml:"comment_style" json:"comment_style"`
+
+	// Properties shown at the top of the page body, in this order (e.g.
+	// ["Author", "Status"]), besides the front matter. Empty shows none.
+	BodyProperties []string `yaml:"body_properties" json:"body_properties"`
+
+	// How BodyProperties are shown: BodyPropertiesTable (default) as a
+	// table with a column per property, or BodyPropertiesDefinitions as a
+	// definition list
+	BodyPropertiesStyle string `yaml:"body_properties_style" json:"body_properties_style"`
+
+	// Strike through the text of completed to-do items
+	StrikeCompletedToDos bool `yaml:"strike_completed_todos" json:"strike_completed_todos"`
+
+	// Template wrapping runs of consecutive images, e.g.
+	// "{{< gallery >}}\n{{.Content}}\n{{< /gallery >}}". Placeholder:
+	// {{.Content}} (the images, one per line). Empty renders them one by one.
+	GalleryTemplate string `yaml:"gallery_template" json:"gallery_template"`
+
 	// Video blocks template
 	VideoTemplate string `yaml:"video_template" json:"video_template"`
 
@@ -36,54 +113,525 @@ type RenderConfig struct {
 	// Embed blocks template
 	EmbedTemplate string `yaml:"embed_template" json:"embed_template"`
 
+	// Templates for embeds from known providers, keyed by ProviderYouTube,
+	// ProviderVimeo, ProviderTwitter, ProviderGist or ProviderCodePen, e.g.
+	// "{{< youtube {{.ID}} >}}". Other embeds use EmbedTemplate. External
+	// video blocks from these providers use them too.
+	EmbedProviderTemplates map[string]string `yaml:"embed_provider_templates" json:"embed_provider_templates"`
+
 	// Callout blocks template
 	CalloutTemplate string `yaml:"callout_template" json:"callout_template"`
 
+	// How callouts are rendered: CalloutStyleTemplate (default) uses
+	// CalloutTemplate, CalloutStyleObsidian emits "> [!type]" callouts
+	CalloutStyle string `yaml:"callout_style" json:"callout_style"`
+
 	// File blocks template (for regular files)
 	FileTemplate string `yaml:"file_template" json:"file_template"`
+
+	// Renames of admonition types derived from callout icons and colors, for
+	// tools that support a different set (e.g. "success" -> "tip")
+	CalloutTypeMap map[string]string `yaml:"callout_type_map" json:"callout_type_map"`
+
+	// Column list and column templates. An empty ColumnsTemplate renders
+	// columns as an HTML table. ColumnTemplate placeholders: {{.Content}},
+	// and {{.Width}}, the column's share of the width as a percentage.
+	ColumnsTemplate string `yaml:"columns_template" json:"columns_template"`
+	ColumnTemplate  string `yaml:"column_template" json:"column_template"`
+
+	// Directory that keeps downloaded files across runs, so cleaning the
+	// output does not force downloading them again. Empty disables it.
+	CacheDir string `yaml:"cache_dir" json:"cache_dir"`
+
+	// HTTP headers (e.g. User-Agent or Authorization) sent when downloading
+	// files that are not hosted by Notion, see CacheExternalAssets. $VAR
+	// references in values are expanded from the environment.
+	AssetHeaders map[string]string `yaml:"asset_headers" json:"asset_headers"`
+
+	// Also download images, files, PDFs and videos linked from external
+	// URLs, instead of only those uploaded to Notion. Links to YouTube and
+	// other embed providers are left alone.
+	CacheExternalAssets bool `yaml:"cache_external_assets" json:"cache_external_assets"`
+
+	// Files larger than this many bytes are not downloaded; they keep
+	// linking to their original URL. Zero means no limit.
+	MaxFileSize int64 `yaml:"max_file_size" json:"max_file_size"`
+
+	// Check previously downloaded files for remote changes with conditional
+	// requests, downloading them again only when they changed
+	RevalidateAssets bool `yaml:"revalidate_assets" json:"revalidate_assets"`
+
+	// Image template. Placeholders: {{.URL}}, {{.Alt}} and {{.QuotedAlt}},
+	// the alt text in double quotes for use as a tag argument. Empty renders
+	// Markdown images.
+	ImageTemplate string `yaml:"image_template" json:"image_template"`
+
+	// Template for images downloaded next to the page, used instead of
+	// ImageTemplate for them; external and uncached images keep using
+	// ImageTemplate. Same placeholders. Empty uses ImageTemplate for all.
+	LocalImageTemplate string `yaml:"local_image_template" json:"local_image_template"`
+
+	// Visible caption rendered beneath file, PDF, video and embed blocks,
+	// applied to each paragraph of the caption. Empty keeps captions as link
+	// text only, without links.
+	CaptionTemplate string `yaml:"caption_template" json:"caption_template"`
+
+	// Use all paragraphs of captions, joined by spaces, as link and alt
+	// text instead of only the first one
+	FullCaptions bool `yaml:"full_captions" json:"full_captions"`
+
+	// Captions longer than this many characters become footnotes, with the
+	// block referencing them and the definitions at the end of the page.
+	// Zero disables it.
+	CaptionFootnoteLength int `yaml:"caption_footnote_length" json:"caption_footnote_length"`
+
+	// User @-mention template
+	UserMentionTemplate string `yaml:"user_mention_template" json:"user_mention_template"`
+
+	// Database mention template, used when the database is not part of the export
+	DatabaseMentionTemplate string `yaml:"database_mention_template" json:"database_mention_template"`
+
+	// Go time layout used for inline date mentions
+	DateFormat string `yaml:"date_format" json:"date_format"`
+
+	// Output file layout: PathStyleBundle (default) writes
+	// "<type>/<slug>/index.md" page bundles, PathStyleJekyll follows Jekyll's
+	// "_posts/YYYY-MM-DD-slug.md" convention, PathStyleHexo writes
+	// "_posts/slug.md" posts and PathStyleZola writes bundles with an
+	// "_index.md" making each type directory a Zola section
+	PathStyle string `yaml:"path_style" json:"path_style"`
+
+	// Where downloaded files are stored: AssetLayoutBundle (default) next to
+	// the article, AssetLayoutHexo in a folder named after it
+	AssetLayout string `yaml:"asset_layout" json:"asset_layout"`
+
+	// Front matter keys for the body's word count and estimated reading time
+	// in minutes. Empty keys disable the fields.
+	WordCountField   string `yaml:"word_count_field" json:"word_count_field"`
+	ReadingTimeField string `yaml:"reading_time_field" json:"reading_time_field"`
+
+	// Front matter key for the ID of the source Notion page, without
+	// dashes, e.g. "notion_id". Empty disables the field.
+	NotionIDField string `yaml:"notion_id_field" json:"notion_id_field"`
+
+	// Front matter key listing the slugs of a child page's parent pages,
+	// root first, e.g. "ancestors" for breadcrumbs when exporting a page
+	// tree. Empty disables the field.
+	AncestorsField string `yaml:"ancestors_field" json:"ancestors_field"`
+
+	// Reading speed used to estimate the reading time
+	WordsPerMinute int `yaml:"words_per_minute" json:"words_per_minute"`
+
+	// Path prefix (e.g. "/blog") prepended to the absolute site paths of
+	// pages, for sites deployed under a subpath
+	LinkBasePrefix string `yaml:"link_base_prefix" json:"link_base_prefix"`
+
+	// Write links between exported pages relative to the linking page (e.g.
+	// "../other/") instead of as absolute site paths, for sites hosted under
+	// a subpath
+	RelativeLinks bool `yaml:"relative_links" json:"relative_links"`
+
+	// Templates rendered before and after every page body, e.g. a license
+	// banner. Placeholders: {{.Title}}, {{.Slug}}, {{.ID}}, {{.URL}} (the
+	// Notion page) and {{.Path}} (the site path).
+	BodyPrefix string `yaml:"body_prefix" json:"body_prefix"`
+	BodySuffix string `yaml:"body_suffix" json:"body_suffix"`
+
+	// Footer linking to the page in Notion, e.g. "[Edit this page in
+	// Notion]({{.URL}})", added after BodySuffix. Same placeholders. Empty
+	// disables it.
+	EditLinkTemplate string `yaml:"edit_link_template" json:"edit_link_template"`
+
+	// Skip pages whose body is empty instead of writing front matter only
+	SkipEmptyPages bool `yaml:"skip_empty_pages" json:"skip_empty_pages"`
+
+	// Front matter key for a structured list of the page's headings (text,
+	// anchor and level). Empty disables the field.
+	TOCField string `yaml:"toc_field" json:"toc_field"`
+
+	// Notion property listing a page's previous slugs (multi-select or comma
+	// separated text), emitted as Hugo "aliases". Empty disables aliases.
+	AliasesProperty string `yaml:"aliases_property" json:"aliases_property"`
+
+	// Front matter syntax: FrontMatterYAML (default), FrontMatterTOML, or
+	// FrontMatterZola for TOML laid out as Zola expects
+	FrontMatterFormat string `yaml:"front_matter_format" json:"front_matter_format"`
+
+	// Go text/template producing the whole front matter, delimiters
+	// included, instead of FrontMatterFormat. It receives .Title, .Slug,
+	// .Type, .Path and .Properties (the default front matter's values) and
+	// the functions json and join. Empty uses FrontMatterFormat.
+	FrontMatterTemplate string `yaml:"front_matter_template" json:"front_matter_template"`
+
+	// Renames of front matter keys (e.g. "slug" -> "id"), matched case
+	// insensitively, applied when the front matter is written
+	FrontMatterKeys map[string]string `yaml:"front_matter_keys" json:"front_matter_keys"`
+
+	// Use the database's title (e.g. "Blog" -> "blog/slug/index.md") as the
+	// type of pages without a type property, instead of "posts"
+	DatabaseTitleType bool `yaml:"database_title_type" json:"database_title_type"`
+
+	// Default type, and so section, of pages without a type property per
+	// database, keyed by database ID (with or without dashes), e.g. to
+	// export a "Blog" and a "Docs" database in one run. Takes precedence
+	// over DatabaseTitleType.
+	DatabaseTypes map[string]string `yaml:"database_types" json:"database_types"`
+
+	// Order front matter keys like the database's columns, after the title,
+	// instead of alphabetically. Keys that are not database properties
+	// follow in alphabetical order.
+	SchemaOrder bool `yaml:"schema_order" json:"schema_order"`
+
+	// Avoid slugs Windows cannot use as file or directory names: reserved
+	// device names such as "con" or "nul" get a "_" suffix and characters
+	// like ':' or '?' are replaced. Enabled by default on Windows.
+	WindowsSafeNames bool `yaml:"windows_safe_names" json:"windows_safe_names"`
+
+	// Keep the case of page slugs (e.g. "Getting-Started") instead of
+	// lowercasing them, for hosts with case-sensitive URLs
+	PreserveSlugCase bool `yaml:"preserve_slug_case" json:"preserve_slug_case"`
+
+	// Maximum length of page slugs; longer slugs are cut at a word boundary
+	// and end in a short hash of the full slug, so they stay distinct. Zero
+	// means no limit.
+	MaxSlugLength int `yaml:"max_slug_length" json:"max_slug_length"`
+
+	// Name of the property holding the page title instead of the database's
+	// title property, which is used when the page has no such property.
+	TitleProperty string `yaml:"title_property" json:"title_property"`
+
+	// Name of a property (e.g. "Permalink") whose value, such as
+	// "/about/team/", sets the page's site path and output directory,
+	// overriding the computed ones. Empty disables it.
+	PathProperty string `yaml:"path_property" json:"path_property"`
+
+	// Name of a date property (e.g. "Expires") emitted as Hugo's
+	// "expiryDate". Pages past it are handled as set by ExpiredPages.
+	// Empty disables it.
+	ExpiryProperty string `yaml:"expiry_property" json:"expiry_property"`
+
+	// What happens to pages past their ExpiryProperty date: ExpiredMark
+	// (default) adds "expired: true" to the front matter, ExpiredSkip
+	// leaves them out of the export
+	ExpiredPages string `yaml:"expired_pages" json:"expired_pages"`
+
+	// Source of the "date" front matter: TimestampCreated, TimestampLastEdited
+	// or the name of a date property (e.g. "Published"). Empty uses the
+	// "Date" property, or the page's creation time. Pages without a date
+	// for the source keep the default; other property types are ignored.
+	DateSource string `yaml:"date_source" json:"date_source"`
+
+	// Source of the "lastmod" front matter, like DateSource. Empty uses the
+	// page's last edit time.
+	LastmodSource string `yaml:"lastmod_source" json:"lastmod_source"`
+
+	// Name of a property (e.g. "Lang") holding the page's language, which
+	// is added to its file name ("index.zh.md") or directory as set by
+	// LanguageStyle, and to the start of its site path ("/zh/..."). Empty
+	// disables languages.
+	LanguageProperty string `yaml:"language_property" json:"language_property"`
+
+	// Where the language goes: LanguageStyleSuffix (default) in the file
+	// name, or LanguageStyleDirectory as a top-level directory per language
+	LanguageStyle string `yaml:"language_style" json:"language_style"`
+
+	// Language whose site paths have no language prefix, like Hugo's
+	// defaultContentLanguage
+	DefaultLanguage string `yaml:"default_language" json:"default_language"`
+
+	// Codes of the values of LanguageProperty, e.g. "English" -> "en".
+	// Values without an entry are used as codes, lowercased.
+	Languages map[string]string `yaml:"languages" json:"languages"`
+
+	// Name of a property (e.g. "TranslationKey") shared by the
+	// translations of a page, emitted as Hugo's "translationKey". Empty
+	// disables it.
+	TranslationKeyProperty string `yaml:"translation_key_property" json:"translation_key_property"`
+
+	// Front matter key listing the site paths of a page's other
+	// translations by language, e.g. "translations: {zh: /zh/posts/hello/}",
+	// for themes that link them. Empty disables the field.
+	TranslationsField string `yaml:"translations_field" json:"translations_field"`
+
+	// Name of a number property (e.g. "Order") emitted as "weight", which
+	// Hugo and docs themes sort pages by. Pages are also converted in that
+	// order, lowest first. Empty disables it.
+	WeightProperty string `yaml:"weight_property" json:"weight_property"`
+
+	// Set "author" to the name of the page's creator when the page has no
+	// author property
+	AuthorFromCreator bool `yaml:"author_from_creator" json:"author_from_creator"`
+
+	// Front matter set by each value of the Status property, matched case
+	// insensitively, e.g. "Archived" -> {draft: true} or "Featured" ->
+	// {featured: true}. Applied after "Draft" sets draft: true, which it can
+	// override.
+	StatusFrontMatter map[string]map[string]interface{} `yaml:"status_front_matter" json:"status_front_matter"`
+
+	// Front matter defaults applied to every page (e.g. "author: Me").
+	// Values set by the page itself take precedence.
+	FrontMatterDefaults map[string]interface{} `yaml:"front_matter_defaults" json:"front_matter_defaults"`
+
+	// Front matter defaults per page type (e.g. all "docs" pages get
+	// "menu: docs"). Values set by the page itself take precedence.
+	TypeFrontMatterDefaults map[string]map[string]interface{} `yaml:"type_front_matter_defaults" json:"type_front_matter_defaults"`
 }
 
+// Toggle styles for RenderConfig.ToggleStyle
+const (
+	ToggleStyleDetails  = "details"
+	ToggleStyleMarkdown = "markdown"
+)
+
+// Front matter formats for RenderConfig.FrontMatterFormat
+const (
+	FrontMatterYAML = "yaml"
+	FrontMatterTOML = "toml"
+	FrontMatterZola = "zola"
+)
+
+// Comment styles for RenderConfig.CommentStyle
+const (
+	CommentStyleFootnotes = "footnotes"
+	CommentStyleHTML      = "html"
+)
+
+// Handling of expired pages for RenderConfig.ExpiredPages
+const (
+	ExpiredMark = "mark"
+	ExpiredSkip = "skip"
+)
+
+// Timestamps for RenderConfig.DateSource and LastmodSource
+const (
+	TimestampCreated    = "created"
+	TimestampLastEdited = "last_edited"
+)
+
+// Styles for RenderConfig.LanguageStyle
+const (
+	LanguageStyleSuffix    = "suffix"
+	LanguageStyleDirectory = "directory"
+)
+
+// Styles for RenderConfig.BodyPropertiesStyle
+const (
+	BodyPropertiesTable       = "table"
+	BodyPropertiesDefinitions = "definitions"
+)
+
+// Path styles for RenderConfig.PathStyle
+const (
+	PathStyleBundle = "bundle"
+	PathStyleJekyll = "jekyll"
+	PathStyleHexo   = "hexo"
+	PathStyleZola   = "zola"
+)
+
+// Callout styles for RenderConfig.CalloutStyle
+const (
+	CalloutStyleTemplate = "template"
+	CalloutStyleObsidian = "obsidian"
+)
+
+// DefaultDateFormat is the layout used for inline date mentions when no
+// DateFormat is configured.
+const DefaultDateFormat = "2006-01-02"
+
+// DefaultListIndent is the indentation of nested list items used when no
+// ListIndent is configured.
+const DefaultListIndent = 4
+
+// DefaultMaxLabelLength is the length URL labels are shortened to when no
+// MaxLabelLength is configured.
+const DefaultMaxLabelLength = 40
+
+// DefaultWordsPerMinute is the reading speed used when none is configured.
+const DefaultWordsPerMinute = 200
+
 // DefaultRenderConfig returns the default configuration for Hugo shortcodes
 func DefaultRenderConfig() *RenderConfig {
 	return &RenderConfig{
-		MathTemplate:    "{{< math >}}\n$$\n{{.Expression}}\n$$\n{{< /math >}}",
-		DetailsTemplate: "{{< details summary=\"{{.Summary}}\">}}\n{{.Content}}\n{{< /details >}}",
-		VideoTemplate:   "{{< video src=\"{{.URL}}\" >}}",
-		PDFTemplate:     "{{< pdf src=\"{{.URL}}\" >}}",
-		EmbedTemplate:   "{{< embed url=\"{{.URL}}\" >}}",
-		CalloutTemplate: "> {{.Content}}",
-		FileTemplate:    "[{{.Text}}]({{.URL}})",
+		MathTemplate:            "{{< math >}}\n$$\n{{.Expression}}\n$$\n{{< /math >}}",
+		DetailsTemplate:         "{{< details summary=\"{{.Summary}}\">}}\n{{.Content}}\n{{< /details >}}",
+		ToggleStyle:             ToggleStyleDetails,
+		VideoTemplate:           "{{< video src=\"{{.URL}}\" >}}",
+		PDFTemplate:             "{{< pdf src=\"{{.URL}}\" >}}",
+		EmbedTemplate:           "{{< embed url=\"{{.URL}}\" >}}",
+		InlineMathTemplate:      "${{.Expression}}$",
+		DividerTemplate:         "---",
+		CalloutTemplate:         "> {{.Content}}",
+		CalloutStyle:            CalloutStyleTemplate,
+		PathStyle:               PathStyleBundle,
+		FrontMatterFormat:       FrontMatterYAML,
+		AssetLayout:             AssetLayoutBundle,
+		FileTemplate:            "[{{.Text}}]({{.URL}})",
+		UserMentionTemplate:     "@{{.Name}}",
+		DatabaseMentionTemplate: "{{.Text}}",
+		DateFormat:              DefaultDateFormat,
+		WordsPerMinute:          DefaultWordsPerMinute,
+		ListIndent:              DefaultListIndent,
+		MaxLabelLength:          DefaultMaxLabelLength,
+		WindowsSafeNames:        runtime.GOOS == "windows",
 	}
 }
 
-// LoadConfigFromYAML loads render configuration from a YAML file
-func LoadConfigFromYAML(filepath string) (*RenderConfig, error) {
-	// If file doesn't exist, return default config
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
-		slog.Info("Config file not found, using default configuration", "file", filepath)
-		return DefaultRenderConfig(), nil
+// labelLength returns MaxLabelLength, or its default when unset. Lengths
+// below 10 leave no room for a shortened URL and are raised to 10.
+func (c *RenderConfig) labelLength() int {
+	if c.MaxLabelLength <= 0 {
+		return DefaultMaxLabelLength
 	}
+	return max(c.MaxLabelLength, 10)
+}
 
-	data, err := os.ReadFile(filepath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file %s: %w", filepath, err)
+// StdinConfigPath is the special config path that reads YAML from stdin.
+const StdinConfigPath = "-"
+
+// configSearchPaths returns the candidate config file locations in order of
+// precedence: the explicit path first, then $XDG_CONFIG_HOME and finally
+// $HOME/.config.
+func configSearchPaths(explicit string) []string {
+	var paths []string
+	if explicit != "" {
+		paths = append(paths, explicit)
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		paths = append(paths, filepath.Join(xdg, "notion-to-markdown", "config.yaml"))
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		paths = append(paths, filepath.Join(home, ".config", "notion-to-markdown", "config.yaml"))
 	}
+	return paths
+}
 
-	config := DefaultRenderConfig()
+// FindConfigFile returns the first existing config file from the search path,
+// or an empty string if none of the candidates exist.
+func FindConfigFile(explicit string) string {
+	for _, p := range configSearchPaths(explicit) {
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
+
+// parseConfig decodes YAML data on top of base
+func parseConfig(data []byte, base *RenderConfig) (*RenderConfig, error) {
+	config := base
 	if err := yaml.Unmarshal(data, config); err != nil {
 		return nil, fmt.Errorf("failed to parse YAML config: %w", err)
 	}
+	return config, nil
+}
+
+// LoadConfigFromYAML loads render configuration from a YAML file. The special
+// path "-" reads the YAML document from stdin.
+func LoadConfigFromYAML(path string) (*RenderConfig, error) {
+	return loadConfigFromYAML(path, DefaultRenderConfig())
+}
 
-	slog.Info("Loaded configuration", "file", filepath)
+// loadConfigFromYAML loads a YAML config file over base
+func loadConfigFromYAML(path string, base *RenderConfig) (*RenderConfig, error) {
+	if path == StdinConfigPath {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config from stdin: %w", err)
+		}
+		return parseConfig(data, base)
+	}
+
+	// If file doesn't exist, return the base config
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		slog.Info("Config file not found, using default configuration", "file", path)
+		return base, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	config, err := parseConfig(data, base)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Loaded configuration", "file", path)
 	return config, nil
 }
 
-// LoadConfigWithFallback tries to load from file, falls back to default if not found
-func LoadConfigWithFallback(filepath string) *RenderConfig {
-	if config, err := LoadConfigFromYAML(filepath); err == nil {
+// EnvPrefix is prepended to the upper-cased YAML key of a template to form the
+// environment variable that overrides it (e.g. N2M_VIDEO_TEMPLATE).
+const EnvPrefix = "N2M_"
+
+// applyEnvOverrides replaces template fields with the values of their
+// environment variables when those are set.
+func applyEnvOverrides(config *RenderConfig) {
+	v := reflect.ValueOf(config).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if field.Type.Kind() != reflect.String || !strings.HasSuffix(key, "_template") {
+			continue
+		}
+		name := EnvPrefix + strings.ToUpper(key)
+		if value, ok := os.LookupEnv(name); ok {
+			v.Field(i).SetString(value)
+			slog.Info("Template overridden from environment", "variable", name)
+		}
+	}
+}
+
+// LoadConfigWithFallback searches for a config file starting at path (see
+// FindConfigFile), falling back to the default configuration if none is found
+// or it cannot be loaded. It returns the config along with the path that was
+// actually used, which is empty when the defaults were applied.
+//
+// Precedence, from lowest to highest: built-in defaults, the YAML config file,
+// then N2M_*_TEMPLATE environment variables.
+func LoadConfigWithFallback(path string) (*RenderConfig, string) {
+	return loadConfigOver(path, DefaultRenderConfig)
+}
+
+// LoadConfigWithPreset works like LoadConfigWithFallback but starts from the
+// named preset (see PresetConfig) instead of the defaults, so the config file
+// and environment only override individual fields. An empty preset uses the
+// defaults.
+func LoadConfigWithPreset(path, preset string) (*RenderConfig, string, error) {
+	if preset == "" {
+		config, used := LoadConfigWithFallback(path)
+		return config, used, nil
+	}
+	if _, err := PresetConfig(preset); err != nil {
+		return nil, "", err
+	}
+	config, used := loadConfigOver(path, func() *RenderConfig {
+		config, _ := PresetConfig(preset)
 		return config
-	} else {
+	})
+	return config, used, nil
+}
+
+// loadConfigOver loads the config file found from path over the config
+// returned by base, then applies environment overrides.
+func loadConfigOver(path string, base func() *RenderConfig) (*RenderConfig, string) {
+	used := StdinConfigPath
+	if path != StdinConfigPath {
+		used = FindConfigFile(path)
+	}
+
+	config := base()
+	if used == "" {
+		slog.Info("Config file not found, using default configuration", "file", path)
+	} else if loaded, err := loadConfigFromYAML(used, base()); err != nil {
 		slog.Warn("Failed to load config, using default", "error", err)
-		return DefaultRenderConfig()
+		used = ""
+	} else {
+		config = loaded
 	}
+
+	applyEnvOverrides(config)
+	return config, used
 }
@@ -0,0 +1,104 @@
+package renderer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+}
+
+func TestLoadConfigWithFallback_SearchPrecedence(t *testing.T) {
+	tempDir := t.TempDir()
+	xdgDir := filepath.Join(tempDir, "xdg")
+	homeDir := filepath.Join(tempDir, "home")
+	t.Setenv("XDG_CONFIG_HOME", xdgDir)
+	t.Setenv("HOME", homeDir)
+
+	explicit := filepath.Join(tempDir, "explicit.yaml")
+	xdgConfig := filepath.Join(xdgDir, "notion-to-markdown", "config.yaml")
+	homeConfig := filepath.Join(homeDir, ".config", "notion-to-markdown", "config.yaml")
+
+	// Nothing exists yet: defaults are used and no path is reported
+	config, used := LoadConfigWithFallback(explicit)
+	if used != "" {
+		t.Errorf("Expected no config path to be used, got '%s'", used)
+	}
+	if config.FileTemplate != DefaultRenderConfig().FileTemplate {
+		t.Errorf("Expected default file template, got '%s'", config.FileTemplate)
+	}
+
+	// Home config is the last resort
+	writeConfigFile(t, homeConfig, "file_template: home\n")
+	config, used = LoadConfigWithFallback(explicit)
+	if used != homeConfig || config.FileTemplate != "home" {
+		t.Errorf("Expected home config, got path '%s' with template '%s'", used, config.FileTemplate)
+	}
+
+	// XDG config wins over home config
+	writeConfigFile(t, xdgConfig, "file_template: xdg\n")
+	config, used = LoadConfigWithFallback(explicit)
+	if used != xdgConfig || config.FileTemplate != "xdg" {
+		t.Errorf("Expected XDG config, got path '%s' with template '%s'", used, config.FileTemplate)
+	}
+
+	// An explicit path wins over everything
+	writeConfigFile(t, explicit, "file_template: explicit\n")
+	config, used = LoadConfigWithFallback(explicit)
+	if used != explicit || config.FileTemplate != "explicit" {
+		t.Errorf("Expected explicit config, got path '%s' with template '%s'", used, config.FileTemplate)
+	}
+}
+
+func TestLoadConfigFromYAML_Stdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	if _, err := w.WriteString("video_template: stdin\n"); err != nil {
+		t.Fatalf("Failed to write to pipe: %v", err)
+	}
+	w.Close()
+
+	origStdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = origStdin }()
+
+	config, used := LoadConfigWithFallback(StdinConfigPath)
+	if used != StdinConfigPath {
+		t.Errorf("Expected stdin to be reported as the config path, got '%s'", used)
+	}
+	if config.VideoTemplate != "stdin" {
+		t.Errorf("Expected video template from stdin, got '%s'", config.VideoTemplate)
+	}
+	// Unset fields keep their defaults
+	if config.PDFTemplate != DefaultRenderConfig().PDFTemplate {
+		t.Errorf("Expected default PDF template, got '%s'", config.PDFTemplate)
+	}
+}
+
+func TestLoadConfigWithFallback_EnvOverride(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", tempDir)
+
+	configPath := filepath.Join(tempDir, "config.yaml")
+	writeConfigFile(t, configPath, "video_template: from-yaml\npdf_template: pdf-from-yaml\n")
+	t.Setenv("N2M_VIDEO_TEMPLATE", "{{< youtube src=\"{{.URL}}\" >}}")
+
+	config, _ := LoadConfigWithFallback(configPath)
+	if config.VideoTemplate != "{{< youtube src=\"{{.URL}}\" >}}" {
+		t.Errorf("Expected video template from environment, got '%s'", config.VideoTemplate)
+	}
+	if config.PDFTemplate != "pdf-from-yaml" {
+		t.Errorf("Expected PDF template from YAML, got '%s'", config.PDFTemplate)
+	}
+}
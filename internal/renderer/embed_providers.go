@@ -0,0 +1,92 @@
+package renderer
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Embed providers recognized by embedProvider, used as keys of
+// RenderConfig.EmbedProviderTemplates
+const (
+	ProviderYouTube = "youtube"
+	ProviderVimeo   = "vimeo"
+	ProviderTwitter = "twitter"
+	ProviderGist    = "gist"
+	ProviderCodePen = "codepen"
+)
+
+var (
+	providerIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	vimeoIDRe    = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// embedInfo identifies embedded content at a known provider
+type embedInfo struct {
+	Provider string
+	// ID is the video, tweet, gist or pen ID
+	ID string
+	// User is the account owning a tweet, gist or pen
+	User string
+}
+
+// embedProvider detects the provider of an embed or video URL and extracts
+// the content's ID. ok is false for URLs of other sites or unknown shapes.
+func embedProvider(raw string) (info embedInfo, ok bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return embedInfo{}, false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	host = strings.TrimPrefix(host, "m.")
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch host {
+	case "youtu.be":
+		info = embedInfo{Provider: ProviderYouTube, ID: parts[0]}
+	case "youtube.com", "youtube-nocookie.com", "music.youtube.com":
+		if id := u.Query().Get("v"); id != "" {
+			info = embedInfo{Provider: ProviderYouTube, ID: id}
+		} else if len(parts) == 2 && (parts[0] == "embed" || parts[0] == "shorts" || parts[0] == "live" || parts[0] == "v") {
+			info = embedInfo{Provider: ProviderYouTube, ID: parts[1]}
+		}
+	case "vimeo.com", "player.vimeo.com":
+		// vimeo.com/ID, vimeo.com/channels/name/ID, player.vimeo.com/video/ID
+		for i := len(parts) - 1; i >= 0; i-- {
+			if vimeoIDRe.MatchString(parts[i]) {
+				info = embedInfo{Provider: ProviderVimeo, ID: parts[i]}
+				break
+			}
+		}
+	case "twitter.com", "x.com":
+		if len(parts) >= 3 && parts[1] == "status" {
+			info = embedInfo{Provider: ProviderTwitter, User: parts[0], ID: parts[2]}
+		}
+	case "gist.github.com":
+		if len(parts) >= 2 {
+			info = embedInfo{Provider: ProviderGist, User: parts[0], ID: strings.TrimSuffix(parts[1], ".js")}
+		}
+	case "codepen.io":
+		if len(parts) >= 3 && (parts[1] == "pen" || parts[1] == "embed") {
+			info = embedInfo{Provider: ProviderCodePen, User: parts[0], ID: parts[2]}
+		}
+	}
+	if info.Provider == "" || !providerIDRe.MatchString(info.ID) {
+		return embedInfo{}, false
+	}
+	return info, true
+}
+
+// providerTemplate returns the configured template for the provider of the
+// URL along with its placeholders, or ok false when there is none.
+func providerTemplate(raw string, config *RenderConfig) (template string, data map[string]string, ok bool) {
+	info, ok := embedProvider(raw)
+	if !ok {
+		return "", nil, false
+	}
+	template = config.EmbedProviderTemplates[info.Provider]
+	if template == "" {
+		return "", nil, false
+	}
+	return template, map[string]string{"ID": info.ID, "User": info.User}, true
+}
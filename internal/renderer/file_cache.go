@@ -2,22 +2,87 @@ package renderer
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/jomei/notionapi"
 )
 
-// FileCache handles downloading and caching files from Notion
+// FileCache handles downloading and caching files from Notion.
+//
+// Files are always streamed: downloads and copies go through io.Copy straight
+// to disk and are never read into memory as a whole, so memory use does not
+// depend on file size. Keep it that way when adding download paths.
 type FileCache struct {
 	// basePath is the root content directory (e.g., "content")
 	basePath string
 	// httpClient for downloading files
 	httpClient *http.Client
+
+	// layout is where files are stored relative to the article (see
+	// AssetLayoutBundle and AssetLayoutHexo)
+	layout string
+	// headers are sent with downloads of files not hosted by Notion
+	headers map[string]string
+	// revalidate makes existing files be checked for remote changes
+	revalidate bool
+	// maxFileSize is the largest file, in bytes, that is downloaded; 0 means
+	// no limit
+	maxFileSize int64
+	// manifests holds the asset manifest of each root directory, see
+	// manifestRoot
+	manifests map[string]map[string]assetRecord
+	// manifestMu guards the asset manifests; it is not held during downloads
+	manifestMu sync.Mutex
+	// fileLocks holds a *sync.Mutex per stored file path, so the same file is
+	// not downloaded twice at once while different files download in parallel
+	fileLocks sync.Map
+
+	// cacheDir, when set, keeps downloaded originals outside the output so
+	// they survive cleaning it; files are copied from there into the output
+	cacheDir string
+
+	// refresh, when set, re-fetches a block to get a fresh signed URL for
+	// its file once the one being downloaded has expired
+	refresh func(id notionapi.BlockID) (string, error)
+
+	// failures records files that could not be downloaded
+	mu       sync.Mutex
+	failures []AssetFailure
+}
+
+// Asset layouts for RenderConfig.AssetLayout
+const (
+	// AssetLayoutBundle stores files next to the article, referenced as "./file"
+	AssetLayoutBundle = "bundle"
+	// AssetLayoutHexo stores files in a folder named after the article (Hexo's
+	// post_asset_folder), referenced by their bare file name
+	AssetLayoutHexo = "hexo"
+)
+
+// errURLExpired is returned when the server rejects a signed file URL, which
+// for Notion-hosted files means it expired (they are valid for about an hour)
+var errURLExpired = errors.New("signed URL rejected or expired")
+
+// errFileTooLarge is returned for files exceeding the maximum file size.
+// Such files are skipped on purpose and are not reported as failures.
+var errFileTooLarge = errors.New("file exceeds the maximum file size")
+
+// AssetFailure describes a file that could not be downloaded and cached, in
+// which case the markdown keeps pointing at the original URL.
+type AssetFailure struct {
+	URL string
+	Err error
 }
 
 // NewFileCache creates a new file cache instance
@@ -34,9 +99,15 @@ func NewFileCache(basePath string) *FileCache {
 // Returns the relative path that should be used in markdown (e.g., "./image.jpg")
 // This method assumes the caller has already determined the file should be cached.
 func (fc *FileCache) CacheFile(notionURL, articlePath string) (string, error) {
-	// Get the directory where the article will be saved
-	articleDir := filepath.Dir(articlePath)
-	fullArticleDir := filepath.Join(fc.basePath, articleDir)
+	return fc.cacheBlockFile(notionURL, "", articlePath)
+}
+
+// cacheBlockFile is CacheFile for the file of block blockID, whose URL can be
+// refreshed when it expired before the download.
+func (fc *FileCache) cacheBlockFile(notionURL string, blockID notionapi.BlockID, articlePath string) (string, error) {
+	// Get the directory where the article's files will be saved
+	assetDir, refPrefix := fc.assetDir(articlePath)
+	fullArticleDir := filepath.Join(fc.basePath, assetDir)
 
 	// Ensure the directory exists
 	if err := os.MkdirAll(fullArticleDir, 0755); err != nil {
@@ -49,22 +120,218 @@ func (fc *FileCache) CacheFile(notionURL, articlePath string) (string, error) {
 		return "", fmt.Errorf("failed to generate filename: %w", err)
 	}
 
-	// Full path where the file will be saved
-	localPath := filepath.Join(fullArticleDir, filename)
+	// Without a cache dir the file is stored directly in the output
+	if fc.cacheDir == "" {
+		name, _, err := fc.fetch(notionURL, blockID, fullArticleDir, filename)
+		if err != nil {
+			return "", err
+		}
+		return refPrefix + name, nil
+	}
+
+	if err := os.MkdirAll(fc.cacheDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory %s: %w", fc.cacheDir, err)
+	}
+	name, changed, err := fc.fetch(notionURL, blockID, fc.cacheDir, filename)
+	if err != nil {
+		return "", err
+	}
+	localPath := filepath.Join(fullArticleDir, name)
+	if _, err := os.Stat(localPath); err != nil || changed {
+		if err := copyFile(filepath.Join(fc.cacheDir, name), localPath); err != nil {
+			return "", err
+		}
+	}
+	return refPrefix + name, nil
+}
+
+// fetch makes sure the file at url is stored in dir under filename, or a
+// longer variant of it when filename already belongs to another file, and
+// returns the name used and whether the file was (re)written. An existing
+// file is kept as is, unless revalidation is enabled, in which case a
+// conditional request based on the manifest entry only downloads it again
+// when it changed remotely. An expired URL is refreshed through blockID.
+// A ".bin" name, used when the URL does not reveal the extension, takes the
+// one the download's Content-Disposition names instead.
+func (fc *FileCache) fetch(url string, blockID notionapi.BlockID, dir, filename string) (string, bool, error) {
+	id := fc.extractFileIdentifier(url)
+	root := fc.manifestRoot(dir)
+	prefix := manifestPrefix(root, dir)
+	fc.manifestMu.Lock()
+	manifest := fc.manifest(root)
+	name := uniqueAssetName(manifest, prefix, filename, id)
+	if _, ok := manifest[prefix+name]; !ok {
+		// reserve the name, so files downloading at the same time notice
+		// the collision too
+		manifest[prefix+name] = assetRecord{Identifier: id}
+	}
+	fc.manifestMu.Unlock()
+
+	unlock := fc.lockFile(filepath.Join(dir, name))
+	defer unlock()
+	if filepath.Ext(name) == ".bin" {
+		if stored := storedVariant(dir, name); stored != "" {
+			name = stored
+		}
+	}
+	path := filepath.Join(dir, name)
+
+	var prev *assetRecord
+	if _, err := os.Stat(path); err == nil {
+		if !fc.revalidate {
+			return name, false, nil
+		}
+		fc.manifestMu.Lock()
+		if rec, ok := fc.manifest(root)[prefix+name]; ok && rec.SHA256 != "" {
+			prev = &rec
+		}
+		fc.manifestMu.Unlock()
+	}
+
+	rec, changed, err := fc.downloadFile(url, path, prev)
+	if errors.Is(err, errURLExpired) && fc.refresh != nil && blockID != "" {
+		slog.Debug("Refreshing expired file URL", "block", blockID)
+		fresh, refreshErr := fc.refresh(blockID)
+		if refreshErr != nil {
+			return "", false, fmt.Errorf("failed to refresh expired URL: %w", refreshErr)
+		}
+		rec, changed, err = fc.downloadFile(fresh, path, prev)
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to download file: %w", err)
+	}
+	if ext := rec.extension; ext != "" && filepath.Ext(name) == ".bin" {
+		renamed := strings.TrimSuffix(name, ".bin") + ext
+		if err := os.Rename(path, filepath.Join(dir, renamed)); err != nil {
+			return "", false, fmt.Errorf("failed to rename file %s: %w", path, err)
+		}
+		name = renamed
+	}
+	rec.Identifier = id
+	fc.manifestMu.Lock()
+	defer fc.manifestMu.Unlock()
+	manifest = fc.manifest(root)
+	manifest[prefix+name] = rec
+	if err := saveAssetManifest(root, manifest); err != nil {
+		return "", false, err
+	}
+	return name, changed, nil
+}
+
+// manifestRoot returns the directory whose manifest records the files
+// stored in dir: the cache dir, or else the root content directory, so
+// that no manifest ends up inside a published page bundle.
+func (fc *FileCache) manifestRoot(dir string) string {
+	if fc.cacheDir != "" && dir == fc.cacheDir {
+		return fc.cacheDir
+	}
+	return fc.basePath
+}
+
+// manifestPrefix returns the prefix of the manifest keys of files stored in
+// dir, which is their directory relative to root, e.g. "posts/test/"
+func manifestPrefix(root, dir string) string {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel) + "/"
+}
+
+// manifest returns the asset manifest kept in root, reading it on first
+// use. The caller must hold manifestMu.
+func (fc *FileCache) manifest(root string) map[string]assetRecord {
+	if fc.manifests == nil {
+		fc.manifests = map[string]map[string]assetRecord{}
+	}
+	manifest, ok := fc.manifests[root]
+	if !ok {
+		manifest = loadAssetManifest(root)
+		fc.manifests[root] = manifest
+	}
+	return manifest
+}
+
+// lockFile locks the stored file path and returns the function unlocking it
+func (fc *FileCache) lockFile(path string) func() {
+	mu, _ := fc.fileLocks.LoadOrStore(path, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// storedVariant returns the name under which a file stored as name, a
+// ".bin" name, was saved with its real extension, or "" when it was not.
+func storedVariant(dir, name string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	stem := strings.TrimSuffix(name, ".bin") + "."
+	for _, entry := range entries {
+		n := entry.Name()
+		if strings.HasPrefix(n, stem) && !strings.HasSuffix(n, ".part") && !entry.IsDir() {
+			return n
+		}
+	}
+	return ""
+}
+
+// uniqueAssetName returns filename, unless the manifest records it, under
+// prefix, for a different file identifier. On such a hash prefix collision
+// the prefix is lengthened until the name is free or belongs to id.
+func uniqueAssetName(manifest map[string]assetRecord, prefix, filename, id string) string {
+	ext := filepath.Ext(filename)
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(id)))
+	name := filename
+	for n := 16; ; n *= 2 {
+		rec, ok := manifest[prefix+name]
+		if !ok || rec.Identifier == "" || rec.Identifier == id || n > len(hash) {
+			return name
+		}
+		name = hash[:n] + ext
+	}
+}
 
-	// Check if file already exists
-	if _, err := os.Stat(localPath); err == nil {
-		// File already exists, return relative path
-		return "./" + filename, nil
+// copyFile streams the file at src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open cached file %s: %w", src, err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", dst, err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("failed to copy cached file to %s: %w", dst, err)
 	}
+	return nil
+}
 
-	// Download the file
-	if err := fc.downloadFile(notionURL, localPath); err != nil {
-		return "", fmt.Errorf("failed to download file: %w", err)
+// assetDir returns the directory, relative to basePath, that files of the
+// article at articlePath are stored in and the prefix used to reference them
+// from the article.
+func (fc *FileCache) assetDir(articlePath string) (dir, refPrefix string) {
+	if fc.layout == AssetLayoutHexo {
+		return strings.TrimSuffix(articlePath, filepath.Ext(articlePath)), ""
 	}
+	return filepath.Dir(articlePath), "./"
+}
 
-	// Return relative path for markdown
-	return "./" + filename, nil
+// recordFailure remembers that caching url failed with err
+func (fc *FileCache) recordFailure(url string, err error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.failures = append(fc.failures, AssetFailure{URL: url, Err: err})
+}
+
+// Failures returns the asset download failures recorded so far
+func (fc *FileCache) Failures() []AssetFailure {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return append([]AssetFailure(nil), fc.failures...)
 }
 
 // generateFilename creates a unique filename based on the URL
@@ -86,6 +353,12 @@ func (fc *FileCache) generateFilename(notionURL string) (string, error) {
 	return filename, nil
 }
 
+// isNotionHosted reports whether u points at Notion's own storage, whose
+// signed URLs must not carry extra headers
+func isNotionHosted(u *url.URL) bool {
+	return strings.Contains(u.Host, "amazonaws.com") || strings.Contains(u.Host, "notion.so") || strings.Contains(u.Host, "notion-static.com")
+}
+
 // extractFileIdentifier extracts a stable identifier from the Notion file URL
 // This removes signed parameters to ensure consistent caching
 func (fc *FileCache) extractFileIdentifier(notionURL string) string {
@@ -116,53 +389,140 @@ func (fc *FileCache) extractFileIdentifier(notionURL string) string {
 
 // extractExtension tries to extract file extension from URL
 func (fc *FileCache) extractExtension(u string) string {
-	// Remove query parameters
-
 	parsed, err := url.Parse(u)
 	if err != nil {
 		return ".bin"
 	}
-	path := parsed.Path
-
-	ext := filepath.Ext(path)
-	if ext == "" {
-		// Try to guess from URL patterns
-		if strings.Contains(u, "image") {
-			return ".jpg"
-		} else if strings.Contains(u, "video") {
-			return ".mp4"
-		} else if strings.Contains(u, "pdf") {
-			return ".pdf"
-		}
-		// Default to .bin if can't determine
-		return ".bin"
+
+	// Prefer the file name in the path, then the one signed URLs pass in
+	// their response-content-disposition parameter
+	if ext := pathExtension(parsed.Path); ext != "" {
+		return ext
+	}
+	if ext := dispositionExtension(parsed.Query().Get("response-content-disposition")); ext != "" {
+		return ext
 	}
 
+	// Try to guess from URL patterns
+	if strings.Contains(u, "image") {
+		return ".jpg"
+	} else if strings.Contains(u, "video") {
+		return ".mp4"
+	} else if strings.Contains(u, "pdf") {
+		return ".pdf"
+	}
+	// Default to .bin if can't determine
+	return ".bin"
+}
+
+// pathExtension returns the extension of the last element of p, undoing any
+// percent-encoding left after URL parsing (new Notion URLs encode file names
+// twice). Implausible extensions are ignored.
+func pathExtension(p string) string {
+	ext := filepath.Ext(decodePercent(p))
+	if len(ext) < 2 || len(ext) > 10 {
+		return ""
+	}
+	for _, r := range ext[1:] {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return ""
+		}
+	}
 	return ext
 }
 
-// downloadFile downloads a file from URL and saves it to localPath
-func (fc *FileCache) downloadFile(url, localPath string) error {
-	resp, err := fc.httpClient.Get(url)
+// dispositionExtension returns the extension of the file name in a
+// Content-Disposition value, e.g. `attachment; filename="report.pdf"`
+func dispositionExtension(value string) string {
+	if value == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return ""
+	}
+	return pathExtension(params["filename"])
+}
+
+// downloadFile downloads a file from URL and saves it to localPath. When prev
+// is set, the request is conditional on its validators and a 304 response
+// leaves localPath untouched. It returns the record of the stored file and
+// whether localPath was written. The body is streamed to disk.
+func (fc *FileCache) downloadFile(url, localPath string, prev *assetRecord) (assetRecord, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return assetRecord{}, false, fmt.Errorf("failed to create request for %s: %w", url, err)
+	}
+	if !isNotionHosted(req.URL) {
+		for name, value := range fc.headers {
+			req.Header.Set(name, os.ExpandEnv(value))
+		}
+	}
+	if prev != nil {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+
+	resp, err := fc.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to fetch URL %s: %w", url, err)
+		return assetRecord{}, false, fmt.Errorf("failed to fetch URL %s: %w", url, err)
 	}
 	defer resp.Body.Close()
 
+	if prev != nil && resp.StatusCode == http.StatusNotModified {
+		return *prev, false, nil
+	}
+	if resp.StatusCode == http.StatusForbidden {
+		return assetRecord{}, false, fmt.Errorf("HTTP %d when fetching %s: %w", resp.StatusCode, url, errURLExpired)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP %d when fetching %s", resp.StatusCode, url)
+		return assetRecord{}, false, fmt.Errorf("HTTP %d when fetching %s", resp.StatusCode, url)
 	}
 
-	file, err := os.Create(localPath)
-	if err != nil {
-		return fmt.Errorf("failed to create file %s: %w", localPath, err)
+	if fc.maxFileSize > 0 && resp.ContentLength > fc.maxFileSize {
+		return assetRecord{}, false, fmt.Errorf("%s is %d bytes: %w", url, resp.ContentLength, errFileTooLarge)
 	}
-	defer file.Close()
 
-	_, err = io.Copy(file, resp.Body)
+	// write to a temporary file so an interrupted download keeps the old file
+	tmpPath := localPath + ".part"
+	file, err := os.Create(tmpPath)
 	if err != nil {
-		return fmt.Errorf("failed to write file %s: %w", localPath, err)
+		return assetRecord{}, false, fmt.Errorf("failed to create file %s: %w", tmpPath, err)
+	}
+	hasher := sha256.New()
+	body := io.Reader(resp.Body)
+	if fc.maxFileSize > 0 {
+		// the Content-Length may be missing, so also stop reading past the limit
+		body = io.LimitReader(resp.Body, fc.maxFileSize+1)
+	}
+	n, err := io.Copy(io.MultiWriter(file, hasher), body)
+	if err == nil && fc.maxFileSize > 0 && n > fc.maxFileSize {
+		err = fmt.Errorf("%s: %w", url, errFileTooLarge)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return assetRecord{}, false, fmt.Errorf("failed to write file %s: %w", localPath, err)
+	}
+	if err := os.Rename(tmpPath, localPath); err != nil {
+		os.Remove(tmpPath)
+		return assetRecord{}, false, fmt.Errorf("failed to write file %s: %w", localPath, err)
 	}
 
-	return nil
+	rec := assetRecord{
+		SHA256:       fmt.Sprintf("%x", hasher.Sum(nil)),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		extension:    dispositionExtension(resp.Header.Get("Content-Disposition")),
+	}
+	if prev != nil && prev.SHA256 != rec.SHA256 {
+		slog.Debug("Remote file changed, downloaded again", "file", localPath)
+	}
+	return rec, true, nil
 }
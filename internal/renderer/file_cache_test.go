@@ -1,8 +1,20 @@
 package renderer
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/jomei/notionapi"
 )
 
 func TestFileCache_CacheFile(t *testing.T) {
@@ -136,3 +148,452 @@ func TestFileCache_ConsistentCaching(t *testing.T) {
 		t.Errorf("Expected same filename for same file (new format) with different signatures, got %s and %s", filename3, filename4)
 	}
 }
+
+func TestProcessFileURLWithCache_FailureAccounting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("image data"))
+	}))
+	defer server.Close()
+
+	fc := NewFileCache(t.TempDir())
+
+	okBlock := &notionapi.ImageBlock{Image: notionapi.Image{File: &notionapi.FileObject{URL: server.URL + "/ok.png"}}}
+	url, _ := processFileURLWithCache(imageURLExtractor{okBlock}, fc, "posts/test/index.md", DefaultRenderConfig())
+	if !strings.HasPrefix(url, "./") {
+		t.Errorf("Expected cached relative path, got '%s'", url)
+	}
+	if len(fc.Failures()) != 0 {
+		t.Errorf("Expected no failures after successful download, got %d", len(fc.Failures()))
+	}
+
+	missingURL := server.URL + "/missing.png"
+	missingBlock := &notionapi.ImageBlock{Image: notionapi.Image{File: &notionapi.FileObject{URL: missingURL}}}
+	for i := 0; i < 2; i++ {
+		url, _ = processFileURLWithCache(imageURLExtractor{missingBlock}, fc, "posts/test/index.md", DefaultRenderConfig())
+		if url != missingURL {
+			t.Errorf("Expected fallback to original URL, got '%s'", url)
+		}
+	}
+
+	failures := fc.Failures()
+	if len(failures) != 2 {
+		t.Fatalf("Expected 2 failures, got %d", len(failures))
+	}
+	if failures[0].URL != missingURL || failures[0].Err == nil {
+		t.Errorf("Expected failure for '%s' with an error, got %+v", missingURL, failures[0])
+	}
+}
+
+func TestFileCache_HexoAssetLayout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("image data"))
+	}))
+	defer server.Close()
+
+	config, err := PresetConfig(PresetHexo)
+	if err != nil {
+		t.Fatalf("Unexpected error loading preset: %v", err)
+	}
+	basePath := t.TempDir()
+	r := New(nil, basePath, config)
+
+	page := newTestPage("Asset Post")
+	filename := r.buildFilename(r.parseMetadata(page))
+	if filename != "_posts/asset-post.md" {
+		t.Fatalf("Expected Hexo post filename, got '%s'", filename)
+	}
+
+	block := &notionapi.ImageBlock{Image: notionapi.Image{
+		File:    &notionapi.FileObject{URL: server.URL + "/photo.png"},
+		Caption: []notionapi.RichText{{PlainText: "A photo", Text: &notionapi.Text{Content: "A photo"}}},
+	}}
+	got := imageToMarkdownWithCache(block, r.fileCache, filename, config)
+
+	// The file lands in the post's asset folder and is referenced by name
+	name, _ := r.fileCache.generateFilename(server.URL + "/photo.png")
+	if _, err := os.Stat(filepath.Join(basePath, "_posts", "asset-post", name)); err != nil {
+		t.Errorf("Expected asset in the post asset folder: %v", err)
+	}
+	if expected := "{% asset_img " + name + ` "A photo" %}`; got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestFileCache_CacheDir(t *testing.T) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write([]byte("image data"))
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	fileURL := server.URL + "/photo.png"
+
+	for run := 1; run <= 2; run++ {
+		// Every run writes to a fresh output directory
+		outDir := t.TempDir()
+		fc := NewFileCache(outDir)
+		fc.cacheDir = cacheDir
+
+		ref, err := fc.CacheFile(fileURL, "posts/test/index.md")
+		if err != nil {
+			t.Fatalf("Run %d: unexpected error caching file: %v", run, err)
+		}
+		data, err := os.ReadFile(filepath.Join(outDir, "posts", "test", strings.TrimPrefix(ref, "./")))
+		if err != nil || string(data) != "image data" {
+			t.Errorf("Run %d: expected file copied into the output, got %q (%v)", run, data, err)
+		}
+		if _, err := os.Stat(filepath.Join(outDir, "posts", "test", assetManifestName)); err == nil {
+			t.Errorf("Run %d: expected no asset manifest in the published bundle", run)
+		}
+	}
+	if len(loadAssetManifest(cacheDir)) != 1 {
+		t.Error("Expected the asset manifest to be kept in the cache dir")
+	}
+
+	if downloads != 1 {
+		t.Errorf("Expected the second run to be served from the cache dir, got %d downloads", downloads)
+	}
+}
+
+func TestFileCache_Revalidate(t *testing.T) {
+	body := "version 1"
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		etag := `"` + body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	outDir := t.TempDir()
+	fc := NewFileCache(outDir)
+	fc.revalidate = true
+	fileURL := server.URL + "/doc.pdf"
+
+	read := func() string {
+		t.Helper()
+		ref, err := fc.CacheFile(fileURL, "posts/test/index.md")
+		if err != nil {
+			t.Fatalf("Unexpected error caching file: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(outDir, "posts", "test", strings.TrimPrefix(ref, "./")))
+		if err != nil {
+			t.Fatalf("Failed to read cached file: %v", err)
+		}
+		return string(data)
+	}
+
+	if got := read(); got != "version 1" {
+		t.Errorf("Expected first download, got %q", got)
+	}
+	// Unchanged: the conditional request gets a 304
+	if got := read(); got != "version 1" || requests != 2 {
+		t.Errorf("Expected unchanged file after revalidation, got %q after %d requests", got, requests)
+	}
+	// Changed remotely: downloaded again and the new hash is recorded
+	body = "version 2"
+	if got := read(); got != "version 2" {
+		t.Errorf("Expected changed file to be downloaded again, got %q", got)
+	}
+	manifest := loadAssetManifest(outDir)
+	if len(manifest) != 1 {
+		t.Fatalf("Expected one manifest entry, got %v", manifest)
+	}
+	expectedHash := fmt.Sprintf("%x", sha256.Sum256([]byte("version 2")))
+	for _, rec := range manifest {
+		if rec.SHA256 != expectedHash || rec.ETag != `"version 2"` {
+			t.Errorf("Expected manifest to describe version 2, got %+v", rec)
+		}
+	}
+}
+
+func TestFileCache_Headers(t *testing.T) {
+	var userAgent, auth string
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		userAgent = r.Header.Get("User-Agent")
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	t.Setenv("ASSET_TOKEN", "secret")
+	fc := NewFileCache(t.TempDir())
+	fc.headers = map[string]string{
+		"User-Agent":    "notion-to-markdown-test",
+		"Authorization": "Bearer $ASSET_TOKEN",
+	}
+	block := &notionapi.ImageBlock{Image: notionapi.Image{External: &notionapi.FileObject{URL: server.URL + "/asset.png"}}}
+	config := DefaultRenderConfig()
+
+	// External files are only downloaded when asked to
+	if got := imageToMarkdownWithCache(block, fc, "posts/test/index.md", config); !strings.Contains(got, server.URL) || requests != 0 {
+		t.Errorf("Expected the external URL to be kept by default, got '%s' after %d requests", got, requests)
+	}
+
+	config.CacheExternalAssets = true
+	got := imageToMarkdownWithCache(block, fc, "posts/test/index.md", config)
+	if strings.Contains(got, server.URL) || !strings.Contains(got, "](./") {
+		t.Errorf("Expected the external image to be cached, got '%s'", got)
+	}
+	if userAgent != "notion-to-markdown-test" {
+		t.Errorf("Expected configured User-Agent, got '%s'", userAgent)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Expected expanded Authorization header, got '%s'", auth)
+	}
+
+	// Embed providers are pages, not files
+	if cacheableExternalURL("https://www.youtube.com/watch?v=dQw4w9WgXcQ") {
+		t.Error("Expected YouTube links not to be downloaded")
+	}
+}
+
+func TestFileCache_EncodedFilenameExtension(t *testing.T) {
+	fc := NewFileCache("test")
+
+	testCases := []struct {
+		url      string
+		expected string
+	}{
+		{"https://prod-files-secure.s3.us-west-2.amazonaws.com/ws/abc/%E6%B5%8B%E8%AF%95.pdf?X-Amz-Algorithm=AWS4-HMAC-SHA256", ".pdf"},
+		{"https://file.notion.so/f/f/ws/abc/%25E6%25B5%258B%25E8%25AF%2595%252Epdf?table=block", ".pdf"},
+		{"https://file.notion.so/f/f/ws/abc/download?response-content-disposition=attachment%3B%20filename%3D%22report.docx%22", ".docx"},
+		{"https://file.notion.so/f/f/ws/abc/download?response-content-disposition=attachment%3B%20filename%2A%3DUTF-8%27%27%25E6%25B5%258B.xlsx", ".xlsx"},
+	}
+
+	for _, tc := range testCases {
+		filename, err := fc.generateFilename(tc.url)
+		if err != nil {
+			t.Fatalf("Unexpected error for URL %s: %v", tc.url, err)
+		}
+		if ext := filepath.Ext(filename); ext != tc.expected {
+			t.Errorf("For URL %s, expected extension %s, got %s", tc.url, tc.expected, ext)
+		}
+	}
+}
+
+func TestFileCache_ContentDispositionExtension(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Disposition", `attachment; filename="slides.pptx"`)
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	outDir := t.TempDir()
+	fc := NewFileCache(outDir)
+	for run := 1; run <= 2; run++ {
+		ref, err := fc.CacheFile(server.URL+"/download", "posts/test/index.md")
+		if err != nil {
+			t.Fatalf("Run %d: unexpected error caching file: %v", run, err)
+		}
+		if filepath.Ext(ref) != ".pptx" {
+			t.Errorf("Run %d: expected extension from Content-Disposition, got '%s'", run, ref)
+		}
+		data, err := os.ReadFile(filepath.Join(outDir, "posts", "test", strings.TrimPrefix(ref, "./")))
+		if err != nil || string(data) != "data" {
+			t.Errorf("Run %d: expected the file under '%s', got %q (%v)", run, ref, data, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected the cached file to be reused without a request, got %d requests", requests)
+	}
+}
+
+// serverTransport sends every request to server, whatever host it is for
+type serverTransport struct {
+	server *httptest.Server
+}
+
+func (st serverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(st.server.URL)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestFileCache_FilenameCollision(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	// Both identifiers hash to the 8-char prefix 1acf894c
+	const host = "https://prod-files-secure.s3.us-west-2.amazonaws.com"
+	first := host + "/ws/file-4999/image.png?X-Amz-Signature=a"
+	second := host + "/ws/file-36104/image.png?X-Amz-Signature=b"
+
+	tempDir := t.TempDir()
+	dir := filepath.Join(tempDir, "posts", "test")
+	refs := map[string]string{}
+	for run := 1; run <= 2; run++ {
+		fc := NewFileCache(tempDir)
+		fc.httpClient = &http.Client{Transport: serverTransport{server}}
+		// The second run sees the files in the opposite order
+		urls := []string{first, second}
+		if run == 2 {
+			urls = []string{second, first}
+		}
+		for _, u := range urls {
+			ref, err := fc.CacheFile(u, "posts/test/index.md")
+			if err != nil {
+				t.Fatalf("Run %d: unexpected error caching %s: %v", run, u, err)
+			}
+			if run == 2 && ref != refs[u] {
+				t.Errorf("Expected stable filename '%s' for %s, got '%s'", refs[u], u, ref)
+			}
+			refs[u] = ref
+		}
+	}
+
+	if refs[first] != "./1acf894c.png" || refs[second] == refs[first] {
+		t.Fatalf("Expected distinct filenames on collision, got '%s' and '%s'", refs[first], refs[second])
+	}
+	for u, path := range map[string]string{first: "/ws/file-4999/image.png", second: "/ws/file-36104/image.png"} {
+		data, err := os.ReadFile(filepath.Join(dir, strings.TrimPrefix(refs[u], "./")))
+		if err != nil || string(data) != path {
+			t.Errorf("Expected %s under '%s', got '%s' (%v)", path, refs[u], data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, assetManifestName)); err == nil {
+		t.Error("Expected no asset manifest in the published bundle")
+	}
+}
+
+func TestFileCache_RefreshExpiredURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "fresh" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	r := New(nil, t.TempDir(), nil)
+	var refreshed notionapi.BlockID
+	r.SetBlockGetter(func(id notionapi.BlockID) (notionapi.Block, error) {
+		refreshed = id
+		return &notionapi.ImageBlock{Image: notionapi.Image{File: &notionapi.FileObject{URL: server.URL + "/photo.png?sig=fresh"}}}, nil
+	})
+
+	block := &notionapi.ImageBlock{
+		BasicBlock: notionapi.BasicBlock{ID: notionapi.BlockID("image-block")},
+		Image:      notionapi.Image{File: &notionapi.FileObject{URL: server.URL + "/photo.png?sig=stale"}},
+	}
+	url, _ := processFileURLWithCache(imageURLExtractor{block}, r.fileCache, "posts/test/index.md", r.config)
+	if refreshed != "image-block" {
+		t.Errorf("Expected the block to be re-fetched, got '%s'", refreshed)
+	}
+	if !strings.HasPrefix(url, "./") {
+		t.Errorf("Expected cached relative path after refresh, got '%s'", url)
+	}
+	if len(r.AssetFailures()) != 0 {
+		t.Errorf("Expected no failures, got %v", r.AssetFailures())
+	}
+}
+
+func TestFileCache_MaxFileSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1048576")
+		w.Write(make([]byte, 1048576))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	fc := NewFileCache(tempDir)
+	fc.maxFileSize = 1024
+
+	videoURL := server.URL + "/large.mp4"
+	block := &notionapi.VideoBlock{Video: notionapi.Video{File: &notionapi.FileObject{URL: videoURL}}}
+	url, _ := processFileURLWithCache(videoURLExtractor{block}, fc, "posts/test/index.md", DefaultRenderConfig())
+	if url != videoURL {
+		t.Errorf("Expected oversized file to keep its original URL, got '%s'", url)
+	}
+	if len(fc.Failures()) != 0 {
+		t.Errorf("Expected skipped file not to count as a failure, got %v", fc.Failures())
+	}
+	name, _ := fc.generateFilename(videoURL)
+	if _, err := os.Stat(filepath.Join(tempDir, "posts", "test", name)); err == nil {
+		t.Error("Expected oversized file not to be written")
+	}
+}
+
+// patternReader yields n bytes of a repeating pattern without holding them
+// in memory
+type patternReader struct {
+	n, off int64
+}
+
+func (p *patternReader) Read(b []byte) (int, error) {
+	if p.off >= p.n {
+		return 0, io.EOF
+	}
+	b = b[:min(int64(len(b)), p.n-p.off)]
+	for i := range b {
+		b[i] = byte((p.off + int64(i)) % 251)
+	}
+	p.off += int64(len(b))
+	return len(b), nil
+}
+
+func TestFileCache_StreamsLargeFiles(t *testing.T) {
+	const size = 4 << 20
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// no Content-Length, so the size is only known while streaming
+		io.Copy(w, &patternReader{n: size})
+	}))
+	defer server.Close()
+
+	outDir, cacheDir := t.TempDir(), t.TempDir()
+	fc := NewFileCache(outDir)
+	fc.cacheDir = cacheDir
+	ref, err := fc.CacheFile(server.URL+"/large.mp4", "posts/test/index.md")
+	if err != nil {
+		t.Fatalf("Unexpected error caching file: %v", err)
+	}
+	expected := sha256.New()
+	io.Copy(expected, &patternReader{n: size})
+	for _, path := range []string{filepath.Join(cacheDir, strings.TrimPrefix(ref, "./")), filepath.Join(outDir, "posts", "test", strings.TrimPrefix(ref, "./"))} {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Expected the file at %s: %v", path, err)
+		}
+		got := sha256.New()
+		n, _ := io.Copy(got, f)
+		f.Close()
+		if n != size || !bytes.Equal(got.Sum(nil), expected.Sum(nil)) {
+			t.Errorf("Expected %s to hold the %d streamed bytes, got %d bytes", path, size, n)
+		}
+	}
+
+	// Past max_file_size the download stops with errFileTooLarge and leaves
+	// nothing behind, not even the partial file
+	fc = NewFileCache(t.TempDir())
+	fc.maxFileSize = 64 << 10
+	_, err = fc.CacheFile(server.URL+"/other.mp4", "posts/test/index.md")
+	if !errors.Is(err, errFileTooLarge) {
+		t.Fatalf("Expected errFileTooLarge, got %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(fc.basePath, "posts", "test"))
+	if len(entries) != 0 {
+		t.Errorf("Expected no file to be written, found %v", entries)
+	}
+}
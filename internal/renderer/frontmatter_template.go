@@ -0,0 +1,69 @@
+package renderer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// frontMatterData is the data FrontMatterTemplate is executed with.
+type frontMatterData struct {
+	Title string
+	Slug  string
+	// Type is the page's type, which decides its section, e.g. "posts"
+	Type string
+	// Path is the page's site path as links to it are written, e.g.
+	// "/posts/hello/", including LinkBasePrefix
+	Path string
+	// Properties holds the values the default front matter would contain,
+	// with FrontMatterKeys renames applied
+	Properties map[string]interface{}
+}
+
+// frontMatterFuncs are the functions available to FrontMatterTemplate.
+var frontMatterFuncs = template.FuncMap{
+	// json encodes a value inline; JSON is also valid YAML and, for strings
+	// and lists of them, TOML
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join": func(v interface{}, sep string) string {
+		switch list := v.(type) {
+		case []string:
+			return strings.Join(list, sep)
+		case []interface{}:
+			parts := make([]string, 0, len(list))
+			for _, item := range list {
+				parts = append(parts, valueText(item))
+			}
+			return strings.Join(parts, sep)
+		}
+		return valueText(v)
+	},
+}
+
+// templateFrontMatter renders the page's front matter with
+// FrontMatterTemplate, which writes the delimiters itself.
+func (r *Renderer) templateFrontMatter(m metadata) (string, error) {
+	if r.frontMatterTemplate == nil {
+		tmpl, err := template.New("front_matter").Funcs(frontMatterFuncs).Parse(r.config.FrontMatterTemplate)
+		if err != nil {
+			return "", fmt.Errorf("front matter template: %w", err)
+		}
+		r.frontMatterTemplate = tmpl
+	}
+	data := frontMatterData{
+		Title:      m.Title,
+		Slug:       m.Slug,
+		Type:       m.pathType,
+		Path:       withBasePrefix(r.pagePath(m), r.config.LinkBasePrefix),
+		Properties: r.renameFrontMatterKeys(m),
+	}
+	var b strings.Builder
+	if err := r.frontMatterTemplate.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("front matter template: %w", err)
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n\n", nil
+}
@@ -0,0 +1,234 @@
+package renderer
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tomlDatetime is a value written as a bare TOML datetime instead of a string
+type tomlDatetime string
+
+// zolaPageKeys are the front matter keys Zola reads at the top level. Other
+// keys must live in the [taxonomies] or [extra] tables.
+var zolaPageKeys = map[string]bool{
+	"title":           true,
+	"description":     true,
+	"date":            true,
+	"updated":         true,
+	"weight":          true,
+	"draft":           true,
+	"slug":            true,
+	"path":            true,
+	"aliases":         true,
+	"authors":         true,
+	"template":        true,
+	"in_search_index": true,
+	"render":          true,
+}
+
+// zolaFrontMatter rearranges page properties into Zola's layout: lastmod
+// becomes updated, tags and categories go to [taxonomies] and everything
+// Zola does not know to [extra].
+func zolaFrontMatter(props map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	taxonomies := map[string]interface{}{}
+	extra := map[string]interface{}{}
+	for k, v := range props {
+		key := strings.ToLower(k)
+		if key == "lastmod" {
+			key = "updated"
+		}
+		switch {
+		case key == "date" || key == "updated":
+			if str, ok := v.(string); ok {
+				v = tomlDatetime(str)
+			}
+			out[key] = v
+		case key == "tags" || key == "categories":
+			if str, ok := v.(string); ok {
+				v = []string{str}
+			}
+			taxonomies[key] = v
+		case zolaPageKeys[key]:
+			out[key] = v
+		default:
+			extra[k] = v
+		}
+	}
+	if len(taxonomies) > 0 {
+		out["taxonomies"] = taxonomies
+	}
+	if len(extra) > 0 {
+		out["extra"] = extra
+	}
+	return out
+}
+
+// encodeTOML writes a front matter map as a TOML document, with top-level
+// keys in the given order. Nested maps at the top level become [tables];
+// deeper values are written inline.
+func encodeTOML(props map[string]interface{}, keys []string) string {
+	var b strings.Builder
+	var tables []string
+	for _, k := range keys {
+		v := reflect.ValueOf(props[k])
+		for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.Map {
+			tables = append(tables, k)
+			continue
+		}
+		if value, ok := tomlValue(props[k]); ok {
+			b.WriteString(tomlKey(k) + " = " + value + "\n")
+		}
+	}
+	for _, k := range tables {
+		b.WriteString("\n[" + tomlKey(k) + "]\n")
+		v := reflect.ValueOf(props[k])
+		for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+			v = v.Elem()
+		}
+		entries := map[string]interface{}{}
+		for _, key := range v.MapKeys() {
+			entries[fmt.Sprint(key.Interface())] = v.MapIndex(key).Interface()
+		}
+		for _, key := range sortedKeys(entries) {
+			if value, ok := tomlValue(entries[key]); ok {
+				b.WriteString(tomlKey(key) + " = " + value + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// tomlValue encodes a single value inline. It reports false for nil values,
+// which TOML cannot represent.
+func tomlValue(value interface{}) (string, bool) {
+	if dt, ok := value.(tomlDatetime); ok {
+		return string(dt), true
+	}
+	v := reflect.ValueOf(value)
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "", false
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return tomlString(v.String()), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	case reflect.Slice, reflect.Array:
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if item, ok := tomlValue(v.Index(i).Interface()); ok {
+				items = append(items, item)
+			}
+		}
+		return "[" + strings.Join(items, ", ") + "]", true
+	case reflect.Map:
+		entries := map[string]interface{}{}
+		for _, key := range v.MapKeys() {
+			entries[fmt.Sprint(key.Interface())] = v.MapIndex(key).Interface()
+		}
+		return tomlInlineTable(entries, sortedKeys(entries)), true
+	case reflect.Struct:
+		// structs are written with their YAML field names, e.g. toc entries
+		entries := map[string]interface{}{}
+		var keys []string
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			entries[name] = v.Field(i).Interface()
+			keys = append(keys, name)
+		}
+		return tomlInlineTable(entries, keys), true
+	}
+	return tomlString(fmt.Sprint(value)), true
+}
+
+// tomlInlineTable writes entries as "{ key = value, ... }" in the given order
+func tomlInlineTable(entries map[string]interface{}, keys []string) string {
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if value, ok := tomlValue(entries[k]); ok {
+			parts = append(parts, tomlKey(k)+" = "+value)
+		}
+	}
+	if len(parts) == 0 {
+		return "{}"
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
+
+// tomlKey returns k as a bare key when possible and quoted otherwise
+func tomlKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for _, r := range k {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return tomlString(k)
+		}
+	}
+	return k
+}
+
+// tomlString quotes s as a TOML basic string
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,6 +1,8 @@
 package renderer
 
 import (
+	"errors"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -493,3 +495,743 @@ func TestDynamicProperties(t *testing.T) {
 		}
 	}
 }
+
+func TestParseMetadata_TypeFrontMatterDefaults(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.TypeFrontMatterDefaults = map[string]map[string]interface{}{
+		"docs": {"menu": "docs", "layout": "doc"},
+	}
+	renderer := New(nil, "test", config)
+
+	newPage := func(pageType, layout string) notionapi.Page {
+		props := notionapi.Properties{
+			"Title": &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: "Page"}}},
+			"Type":  &notionapi.SelectProperty{Select: notionapi.Option{Name: pageType}},
+		}
+		if layout != "" {
+			props["layout"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: layout}}}
+		}
+		return notionapi.Page{Properties: props}
+	}
+
+	meta := renderer.parseMetadata(newPage("Docs", "wide"))
+	if meta.Properties["menu"] != "docs" {
+		t.Errorf("Expected menu default for docs page, got '%v'", meta.Properties["menu"])
+	}
+	if meta.Properties["layout"] != "wide" {
+		t.Errorf("Expected page layout to win over default, got '%v'", meta.Properties["layout"])
+	}
+
+	meta = renderer.parseMetadata(newPage("blog", ""))
+	if _, exists := meta.Properties["menu"]; exists {
+		t.Errorf("Expected no docs defaults on blog page, got %v", meta.Properties)
+	}
+}
+
+func TestParseMetadata_FrontMatterDefaults(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.FrontMatterDefaults = map[string]interface{}{
+		"author":  "Me",
+		"license": "CC-BY",
+	}
+	renderer := New(nil, "test", config)
+
+	page := notionapi.Page{
+		Properties: notionapi.Properties{
+			"Title":  &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: "Page"}}},
+			"author": &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "Guest"}}},
+		},
+	}
+
+	meta := renderer.parseMetadata(page)
+	if meta.Properties["license"] != "CC-BY" {
+		t.Errorf("Expected global license default, got '%v'", meta.Properties["license"])
+	}
+	if meta.Properties["author"] != "Guest" {
+		t.Errorf("Expected page author to override default, got '%v'", meta.Properties["author"])
+	}
+
+	// Keys are matched case-insensitively, so no second author is added
+	delete(page.Properties, "author")
+	page.Properties["Author"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "Guest"}}}
+	meta = renderer.parseMetadata(page)
+	if _, ok := meta.Properties["author"]; ok {
+		t.Errorf("Expected no default author next to the page's Author, got %v", meta.Properties)
+	}
+}
+
+func TestParseMetadata_Aliases(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.AliasesProperty = "Old Slugs"
+	renderer := New(nil, "test", config)
+
+	page := notionapi.Page{
+		Properties: notionapi.Properties{
+			"Title": &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: "New Title"}}},
+			"Old Slugs": &notionapi.MultiSelectProperty{
+				MultiSelect: []notionapi.Option{{Name: "/posts/old-title"}, {Name: "/posts/older-title"}},
+			},
+		},
+	}
+
+	meta := renderer.parseMetadata(page)
+	aliases, ok := meta.Properties["aliases"].([]string)
+	if !ok || len(aliases) != 2 || aliases[0] != "/posts/old-title" || aliases[1] != "/posts/older-title" {
+		t.Errorf("Expected aliases from Old Slugs, got %v", meta.Properties["aliases"])
+	}
+	if _, exists := meta.Properties["Old Slugs"]; exists {
+		t.Errorf("Expected Old Slugs to be replaced by aliases, got %v", meta.Properties)
+	}
+
+	// Text properties are split on commas
+	page.Properties["Old Slugs"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "first, second"}}}
+	meta = renderer.parseMetadata(page)
+	if aliases, _ := meta.Properties["aliases"].([]string); len(aliases) != 2 || aliases[1] != "second" {
+		t.Errorf("Expected aliases from text property, got %v", meta.Properties["aliases"])
+	}
+}
+
+func TestJekyllPathStyle(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.PathStyle = PathStyleJekyll
+	renderer := New(nil, "test", config)
+
+	page := notionapi.Page{
+		Properties: notionapi.Properties{
+			"Title": &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: "Hello Jekyll"}}},
+			"Date": &notionapi.DateProperty{Date: &notionapi.DateObject{
+				Start: dateRef(time.Date(2025, 3, 7, 9, 0, 0, 0, time.UTC)),
+			}},
+			"Categories": &notionapi.MultiSelectProperty{MultiSelect: []notionapi.Option{{Name: "Dev Notes"}, {Name: "go"}}},
+		},
+	}
+
+	if got, expected := renderer.buildFilename(renderer.parseMetadata(page)), "dev-notes/_posts/2025-03-07-hello-jekyll.md"; got != expected {
+		t.Errorf("Expected filename '%s', got '%s'", expected, got)
+	}
+	if got, expected := renderer.GetPagePath(page), "/dev-notes/2025/03/07/hello-jekyll.html"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+
+	// Undated posts are drafts
+	delete(page.Properties, "Date")
+	if got, expected := renderer.buildFilename(renderer.parseMetadata(page)), "_drafts/hello-jekyll.md"; got != expected {
+		t.Errorf("Expected filename '%s', got '%s'", expected, got)
+	}
+}
+
+func TestGetPagePath_LinkBasePrefix(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.LinkBasePrefix = "/blog/"
+	renderer := New(nil, "test", config)
+
+	page := newTestPage("Test Article")
+	if got, expected := renderer.GetPagePath(page), "/blog/posts/test-article/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+
+	// Paths that already carry the prefix are not prefixed again
+	page.Properties["Type"] = &notionapi.SelectProperty{Select: notionapi.Option{Name: "blog"}}
+	if got, expected := renderer.GetPagePath(page), "/blog/test-article/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+	config.PathProperty = "Permalink"
+	page.Properties["Permalink"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "/blog/about/"}}}
+	if got, expected := renderer.GetPagePath(page), "/blog/about/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+	if got, expected := withBasePrefix("/blog", "blog"), "/blog"; got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+	if got, expected := withBasePrefix("/blogroll/", "blog"), "/blog/blogroll/"; got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	// Resolved paths are prefixed unless they already carry the prefix
+	arr := []notionapi.RichText{{PlainText: "other", Href: "https://www.notion.so/Other-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"}}
+	for _, resolved := range []string{"/blog/posts/other/", "/posts/other/"} {
+		resolve := func(id string) string { return resolved }
+		if got, expected := richTextArrToMarkdown(arr, resolve, config), "[other](/blog/posts/other/)"; got != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, got)
+		}
+	}
+
+	// The fallback path of unresolved pages is prefixed
+	if got, expected := richTextArrToMarkdown(arr, nil, config), "[other](/blog/posts/other/)"; got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestGetPagePath_DatabaseTypes(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.DatabaseTypes = map[string]string{
+		"11111111-1111-1111-1111-111111111111": "docs",
+		"22222222222222222222222222222222":     "notes",
+	}
+	renderer := New(nil, "test", config)
+
+	page := newTestPage("Getting Started")
+	page.Parent = notionapi.Parent{Type: notionapi.ParentTypeDatabaseID, DatabaseID: "11111111111111111111111111111111"}
+	if got, expected := renderer.GetPagePath(page), "/docs/getting-started/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+
+	page.Parent.DatabaseID = "22222222-2222-2222-2222-222222222222"
+	if got, expected := renderer.GetPagePath(page), "/notes/getting-started/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+
+	// An explicit type wins over the database's
+	page.Properties["Type"] = &notionapi.SelectProperty{Select: notionapi.Option{Name: "pages:about"}}
+	if got, expected := renderer.GetPagePath(page), "/getting-started/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+	delete(page.Properties, "Type")
+
+	// Other databases keep the renderer's default
+	page.Parent.DatabaseID = "33333333333333333333333333333333"
+	if got, expected := renderer.GetPagePath(page), "/posts/getting-started/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+}
+
+func TestBuildFilename_Language(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.LanguageProperty = "Lang"
+	config.DefaultLanguage = "en"
+	config.Languages = map[string]string{"中文": "zh"}
+	renderer := New(nil, "test", config)
+
+	page := newTestPage("Hello World")
+	page.Properties["Lang"] = &notionapi.SelectProperty{Select: notionapi.Option{Name: "zh"}}
+	meta := renderer.parseMetadata(page)
+	if got, expected := renderer.buildFilename(meta), "posts/hello-world/index.zh.md"; got != expected {
+		t.Errorf("Expected filename '%s', got '%s'", expected, got)
+	}
+	if got, expected := renderer.GetPagePath(page), "/zh/posts/hello-world/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+	if _, ok := meta.Properties["Lang"]; ok {
+		t.Error("Expected the language property to be left out of the front matter")
+	}
+
+	// Mapped values, and the default language, which keeps unprefixed links
+	page.Properties["Lang"] = &notionapi.SelectProperty{Select: notionapi.Option{Name: "中文"}}
+	if got, expected := renderer.buildFilename(renderer.parseMetadata(page)), "posts/hello-world/index.zh.md"; got != expected {
+		t.Errorf("Expected filename '%s', got '%s'", expected, got)
+	}
+	page.Properties["Lang"] = &notionapi.SelectProperty{Select: notionapi.Option{Name: "EN"}}
+	if got, expected := renderer.buildFilename(renderer.parseMetadata(page)), "posts/hello-world/index.en.md"; got != expected {
+		t.Errorf("Expected filename '%s', got '%s'", expected, got)
+	}
+	if got, expected := renderer.GetPagePath(page), "/posts/hello-world/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+
+	config.LanguageStyle = LanguageStyleDirectory
+	page.Properties["Lang"] = &notionapi.SelectProperty{Select: notionapi.Option{Name: "zh"}}
+	if got, expected := renderer.buildFilename(renderer.parseMetadata(page)), "zh/posts/hello-world/index.md"; got != expected {
+		t.Errorf("Expected filename '%s', got '%s'", expected, got)
+	}
+
+	// Pages without a language are unchanged
+	delete(page.Properties, "Lang")
+	if got, expected := renderer.buildFilename(renderer.parseMetadata(page)), "posts/hello-world/index.md"; got != expected {
+		t.Errorf("Expected filename '%s', got '%s'", expected, got)
+	}
+}
+
+func TestGetPagePath_PathProperty(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.PathProperty = "Permalink"
+	renderer := New(nil, "test", config)
+
+	page := newTestPage("Meet the Team")
+	page.Properties["Permalink"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "/about/team/"}}}
+	if got, expected := renderer.GetPagePath(page), "/about/team/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+	filename, _, err := renderer.RenderPage(page, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+	if expected := "about/team/index.md"; filename != expected {
+		t.Errorf("Expected filename '%s', got '%s'", expected, filename)
+	}
+
+	// Paths cannot leave the output directory
+	page.Properties["Permalink"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "../../etc"}}}
+	if got, expected := renderer.GetPagePath(page), "/etc/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+
+	// Pages without a value keep the computed path
+	delete(page.Properties, "Permalink")
+	if got, expected := renderer.GetPagePath(page), "/posts/meet-the-team/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+}
+
+func TestParseMetadata_NotionIDField(t *testing.T) {
+	page := newTestPage("Traceable")
+	page.ID = "12345678-90ab-cdef-1234-567890abcdef"
+
+	if _, ok := New(nil, "test", nil).parseMetadata(page).Properties["notion_id"]; ok {
+		t.Error("Expected no notion_id by default")
+	}
+
+	config := DefaultRenderConfig()
+	config.NotionIDField = "notion_id"
+	_, content, err := New(nil, "test", config).RenderPage(page, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+	if !strings.Contains(content, "notion_id: 1234567890abcdef1234567890abcdef\n") {
+		t.Errorf("Expected the normalized page ID in front matter, got:\n%s", content)
+	}
+}
+
+func TestParseMetadata_AncestorsField(t *testing.T) {
+	page := func(id, title string) notionapi.Page {
+		p := newTestPage(title)
+		p.ID = notionapi.ObjectID(id)
+		return p
+	}
+	root := page("aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", "Wiki")
+	setup := page("bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", "Setup")
+	linux := page("cccccccc-cccc-cccc-cccc-cccccccccccc", "Linux")
+
+	config := DefaultRenderConfig()
+	config.AncestorsField = "ancestors"
+	renderer := New(nil, "test", config)
+	renderer.SetParent(setup, root)
+	renderer.SetParent(linux, setup)
+
+	ancestors, _ := renderer.parseMetadata(linux).Properties["ancestors"].([]string)
+	if expected := []string{"wiki", "setup"}; !slices.Equal(ancestors, expected) {
+		t.Errorf("Expected ancestors %v, got %v", expected, ancestors)
+	}
+	if got, expected := renderer.GetPagePath(linux), "/posts/wiki/setup/linux/"; got != expected {
+		t.Errorf("Expected path '%s', got '%s'", expected, got)
+	}
+	if _, ok := renderer.parseMetadata(root).Properties["ancestors"]; ok {
+		t.Error("Expected no ancestors for the root page")
+	}
+}
+
+func TestParseMetadata_AuthorFromCreator(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.AuthorFromCreator = true
+	renderer := New(nil, "test", config)
+
+	page := newTestPage("Credited")
+	page.CreatedBy = notionapi.User{ID: "user-1", Name: "Jane Doe"}
+	if meta := renderer.parseMetadata(page); meta.Properties["author"] != "Jane Doe" {
+		t.Errorf("Expected author from the creator, got '%v'", meta.Properties["author"])
+	}
+
+	// An author property wins
+	page.Properties["Author"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "Guest"}}}
+	meta := renderer.parseMetadata(page)
+	if _, ok := meta.Properties["author"]; ok || meta.Properties["Author"] != "Guest" {
+		t.Errorf("Expected the Author property to be kept alone, got %v", meta.Properties)
+	}
+
+	// Disabled by default
+	page = newTestPage("Uncredited")
+	page.CreatedBy = notionapi.User{ID: "user-1", Name: "Jane Doe"}
+	if meta := New(nil, "test", nil).parseMetadata(page); meta.Properties["author"] != nil {
+		t.Errorf("Expected no author by default, got '%v'", meta.Properties["author"])
+	}
+}
+
+func TestParseMetadata_TitleProperty(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.TitleProperty = "headline"
+	renderer := New(nil, "test", config)
+
+	page := notionapi.Page{
+		Properties: notionapi.Properties{
+			"Headline": &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: "Breaking News"}}},
+			"Summary":  &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "Short"}}},
+		},
+	}
+	meta := renderer.parseMetadata(page)
+	if meta.Title != "Breaking News" || meta.Properties["title"] != "Breaking News" {
+		t.Errorf("Expected title from Headline, got '%s' (%v)", meta.Title, meta.Properties["title"])
+	}
+	if meta.Slug != "breaking-news" {
+		t.Errorf("Expected slug from the title, got '%s'", meta.Slug)
+	}
+	if _, ok := meta.Properties["Headline"]; ok {
+		t.Error("Expected Headline not to be repeated in the front matter")
+	}
+}
+
+func TestParseMetadata_TitleTypeProperty(t *testing.T) {
+	page := notionapi.Page{
+		Properties: notionapi.Properties{
+			"Topic": &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: "Go Generics"}}},
+		},
+	}
+	meta := New(nil, "test", nil).parseMetadata(page)
+	if meta.Title != "Go Generics" || meta.Properties["title"] != "Go Generics" {
+		t.Errorf("Expected title from the title-type Topic property, got '%s' (%v)", meta.Title, meta.Properties["title"])
+	}
+	if _, ok := meta.Properties["Topic"]; ok {
+		t.Error("Expected Topic not to be repeated in the front matter")
+	}
+
+	// An explicit title property still wins
+	config := DefaultRenderConfig()
+	config.TitleProperty = "Headline"
+	page.Properties["Headline"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "Generics in Go 1.18"}}}
+	if meta := New(nil, "test", config).parseMetadata(page); meta.Title != "Generics in Go 1.18" {
+		t.Errorf("Expected the configured title property to win, got '%s'", meta.Title)
+	}
+}
+
+func TestParseMetadata_ComputedSlug(t *testing.T) {
+	renderer := New(nil, "test", nil)
+
+	page := newTestPage("Quarterly Report")
+	page.Properties["Slug"] = &notionapi.FormulaProperty{Formula: notionapi.Formula{Type: notionapi.FormulaTypeString, String: "2025 Q1 Report"}}
+	if meta := renderer.parseMetadata(page); meta.Slug != "2025-q1-report" {
+		t.Errorf("Expected slug from the formula, got '%s'", meta.Slug)
+	}
+
+	page.Properties["Slug"] = &notionapi.RollupProperty{Rollup: notionapi.Rollup{
+		Type: notionapi.RollupTypeArray,
+		Array: notionapi.PropertyArray{
+			&notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "rolled-up-slug"}}},
+		},
+	}}
+	if meta := renderer.parseMetadata(page); meta.Slug != "rolled-up-slug" {
+		t.Errorf("Expected slug from the rollup, got '%s'", meta.Slug)
+	}
+
+	// Other formula results become front matter values
+	page.Properties["Score"] = &notionapi.FormulaProperty{Formula: notionapi.Formula{Type: notionapi.FormulaTypeNumber, Number: 4.5}}
+	if meta := renderer.parseMetadata(page); meta.Properties["Score"] != 4.5 {
+		t.Errorf("Expected formula number in front matter, got %v", meta.Properties["Score"])
+	}
+}
+
+func TestParseMetadata_MaxSlugLength(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.MaxSlugLength = 30
+	renderer := New(nil, "test", config)
+
+	meta := renderer.parseMetadata(newTestPage("A Surprisingly Long Title About Static Site Generators"))
+	if expected := "a-surprisingly-long-d8c43e"; meta.Slug != expected {
+		t.Errorf("Expected slug truncated at a word boundary with a hash '%s', got '%s'", expected, meta.Slug)
+	}
+
+	// Titles sharing a long prefix keep distinct slugs
+	other := renderer.parseMetadata(newTestPage("A Surprisingly Long Title About Static Site Hosting"))
+	if other.Slug == meta.Slug || len(other.Slug) > 30 {
+		t.Errorf("Expected distinct slugs of at most 30 characters, got '%s' and '%s'", meta.Slug, other.Slug)
+	}
+
+	testCases := []struct {
+		slug     string
+		max      int
+		expected string
+	}{
+		{"short-slug", 30, "short-slug"},
+		{"exactly-ten", 11, "exactly-ten"},
+		{"cut-before-dash", 10, "cut-7709f0"},
+		{"supercalifragilistic-expialidocious", 15, "supercal-8bfe16"},
+		{"supercalifragilistic", 5, "cb570"},
+		{"anything", 0, "anything"},
+	}
+	for _, tc := range testCases {
+		if got := truncateSlug(tc.slug, tc.max); got != tc.expected {
+			t.Errorf("truncateSlug(%q, %d): expected '%s', got '%s'", tc.slug, tc.max, tc.expected, got)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	testCases := map[string]string{
+		"  Hello, World!!  ": "hello-world",
+		"foo - bar":          "foo-bar",
+		"--already-dashed--": "already-dashed",
+		"snake_case title":   "snake_case-title",
+		"!!!":                "",
+	}
+	for input, expected := range testCases {
+		if got := slugify(input); got != expected {
+			t.Errorf("slugify(%q): expected '%s', got '%s'", input, expected, got)
+		}
+	}
+}
+
+func TestParseMetadata_PreserveSlugCase(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.PreserveSlugCase = true
+	if meta := New(nil, "test", config).parseMetadata(newTestPage("Getting Started with Go")); meta.Slug != "Getting-Started-with-Go" {
+		t.Errorf("Expected case-preserving slug, got '%s'", meta.Slug)
+	}
+	if meta := New(nil, "test", nil).parseMetadata(newTestPage("Getting Started with Go")); meta.Slug != "getting-started-with-go" {
+		t.Errorf("Expected lowercase slug by default, got '%s'", meta.Slug)
+	}
+}
+
+func TestParseMetadata_WindowsSafeNames(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.WindowsSafeNames = true
+	renderer := New(nil, "test", config)
+
+	page := newTestPage("CON")
+	meta := renderer.parseMetadata(page)
+	if meta.Slug != "con_" {
+		t.Errorf("Expected reserved name to be avoided, got '%s'", meta.Slug)
+	}
+	if filename := renderer.buildFilename(meta); filename != "posts/con_/index.md" {
+		t.Errorf("Expected safe filename, got '%s'", filename)
+	}
+	if path := renderer.GetPagePath(page); path != "/posts/con_/" {
+		t.Errorf("Expected the page path to match the file, got '%s'", path)
+	}
+
+	testCases := map[string]string{
+		"nul.txt":   "nul_.txt",
+		"Com1":      "Com1_",
+		"what?":     "what-",
+		"trailing.": "trailing",
+		"console":   "console",
+	}
+	for input, expected := range testCases {
+		if got := windowsSafeName(input); got != expected {
+			t.Errorf("windowsSafeName(%q): expected '%s', got '%s'", input, expected, got)
+		}
+	}
+}
+
+func TestParseMetadata_WeightProperty(t *testing.T) {
+	page := newTestPage("Installation")
+	page.Properties["Order"] = &notionapi.NumberProperty{Number: 3}
+
+	meta := New(nil, "test", DefaultRenderConfig()).parseMetadata(page)
+	if _, ok := meta.Properties["weight"]; ok {
+		t.Error("Expected no weight without weight_property")
+	}
+	if meta.Properties["Order"] != 3.0 {
+		t.Errorf("Expected the number property in front matter, got %v", meta.Properties["Order"])
+	}
+
+	config := DefaultRenderConfig()
+	config.WeightProperty = "order"
+	meta = New(nil, "test", config).parseMetadata(page)
+	if meta.Properties["weight"] != 3 {
+		t.Errorf("Expected weight 3, got %#v", meta.Properties["weight"])
+	}
+	if _, ok := meta.Properties["Order"]; ok {
+		t.Error("Expected the order property to be replaced by weight")
+	}
+
+	page.Properties["Order"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: " 2.5 "}}}
+	if meta := New(nil, "test", config).parseMetadata(page); meta.Properties["weight"] != 2.5 {
+		t.Errorf("Expected weight 2.5 from text, got %#v", meta.Properties["weight"])
+	}
+}
+
+func TestExtractPropertyValue_Fallback(t *testing.T) {
+	cases := []struct {
+		name     string
+		prop     notionapi.Property
+		expected interface{}
+	}{
+		{"url", &notionapi.URLProperty{Type: notionapi.PropertyTypeURL, URL: "https://example.com"}, "https://example.com"},
+		{"email", &notionapi.EmailProperty{Type: notionapi.PropertyTypeEmail, Email: "jane@example.com"}, "jane@example.com"},
+		{"checkbox", &notionapi.CheckboxProperty{Type: notionapi.PropertyTypeCheckbox, Checkbox: false}, false},
+		{"empty url", &notionapi.URLProperty{Type: notionapi.PropertyTypeURL}, nil},
+		{"empty people", &notionapi.PeopleProperty{Type: notionapi.PropertyTypePeople}, nil},
+	}
+	for _, tc := range cases {
+		if got := extractPropertyValue(tc.prop); got != tc.expected {
+			t.Errorf("%s: expected %#v, got %#v", tc.name, tc.expected, got)
+		}
+	}
+
+	// Structured values keep their fields
+	people := &notionapi.PeopleProperty{Type: notionapi.PropertyTypePeople, People: []notionapi.User{{Name: "Jane"}}}
+	list, ok := extractPropertyValue(people).([]interface{})
+	if !ok || len(list) != 1 {
+		t.Fatalf("Expected a list of people, got %#v", extractPropertyValue(people))
+	}
+	if user, ok := list[0].(map[string]interface{}); !ok || user["name"] != "Jane" {
+		t.Errorf("Expected the person's name to be kept, got %#v", list[0])
+	}
+
+	page := newTestPage("Links")
+	page.Properties["Website"] = &notionapi.URLProperty{Type: notionapi.PropertyTypeURL, URL: "https://example.com"}
+	if meta := New(nil, "test", DefaultRenderConfig()).parseMetadata(page); meta.Properties["Website"] != "https://example.com" {
+		t.Errorf("Expected the URL property in front matter, got %v", meta.Properties["Website"])
+	}
+}
+
+func TestExtractPropertyValue_Timestamps(t *testing.T) {
+	at := time.Date(2025, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	created := &notionapi.CreatedTimeProperty{Type: notionapi.PropertyTypeCreatedTime, CreatedTime: at}
+	if got, expected := extractPropertyValue(created), "2025-02-03T04:05:06Z"; got != expected {
+		t.Errorf("created_time: expected %v, got %#v", expected, got)
+	}
+
+	edited := &notionapi.LastEditedTimeProperty{Type: notionapi.PropertyTypeLastEditedTime, LastEditedTime: at.Add(time.Hour)}
+	if got, expected := extractPropertyValue(edited), "2025-02-03T05:05:06Z"; got != expected {
+		t.Errorf("last_edited_time: expected %v, got %#v", expected, got)
+	}
+
+	page := newTestPage("Timestamps")
+	page.Properties["Created"] = created
+	page.Properties["Edited"] = edited
+	meta := New(nil, "test", DefaultRenderConfig()).parseMetadata(page)
+	if meta.Properties["Created"] != "2025-02-03T04:05:06Z" || meta.Properties["Edited"] != "2025-02-03T05:05:06Z" {
+		t.Errorf("Expected both timestamp columns in front matter, got %v and %v", meta.Properties["Created"], meta.Properties["Edited"])
+	}
+}
+
+func TestExtractPropertyValue_Users(t *testing.T) {
+	creator := &notionapi.CreatedByProperty{Type: notionapi.PropertyTypeCreatedBy, CreatedBy: notionapi.User{Name: "Jane Doe"}}
+	if got := extractPropertyValue(creator); got != "Jane Doe" {
+		t.Errorf("created_by: expected the creator's name, got %#v", got)
+	}
+
+	editor := &notionapi.LastEditedByProperty{Type: notionapi.PropertyTypeLastEditedBy, LastEditedBy: notionapi.User{ID: "u2", Name: "John Roe"}}
+	if got := extractPropertyValue(editor); got != "John Roe" {
+		t.Errorf("last_edited_by: expected the editor's name, got %#v", got)
+	}
+
+	// Without the user capability only the ID is known, which is dropped
+	anonymous := &notionapi.LastEditedByProperty{Type: notionapi.PropertyTypeLastEditedBy, LastEditedBy: notionapi.User{ID: "u3"}}
+	if got := extractPropertyValue(anonymous); got != nil {
+		t.Errorf("Expected no value for a user without a name, got %#v", got)
+	}
+}
+
+func TestExtractPropertyValue_UniqueID(t *testing.T) {
+	prefix := "TASK"
+	withPrefix := &notionapi.UniqueIDProperty{Type: notionapi.PropertyTypeUniqueID, UniqueID: notionapi.UniqueID{Prefix: &prefix, Number: 42}}
+	if got := extractPropertyValue(withPrefix); got != "TASK-42" {
+		t.Errorf("Expected 'TASK-42', got %#v", got)
+	}
+
+	plain := &notionapi.UniqueIDProperty{Type: notionapi.PropertyTypeUniqueID, UniqueID: notionapi.UniqueID{Number: 7}}
+	if got := extractPropertyValue(plain); got != "7" {
+		t.Errorf("Expected '7', got %#v", got)
+	}
+
+	// A unique ID makes a stable slug
+	page := newTestPage("Fix the Login Bug")
+	page.Properties["Slug"] = withPrefix
+	if meta := New(nil, "test", DefaultRenderConfig()).parseMetadata(page); meta.Slug != "task-42" {
+		t.Errorf("Expected slug 'task-42', got '%s'", meta.Slug)
+	}
+}
+
+func TestParseMetadata_DateSource(t *testing.T) {
+	published := notionapi.Date(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	page := newTestPage("Launch Day")
+	page.CreatedTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	page.LastEditedTime = time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	page.Properties["Published"] = &notionapi.DateProperty{Date: &notionapi.DateObject{Start: &published}}
+
+	config := DefaultRenderConfig()
+	config.DateSource = "published"
+	config.LastmodSource = TimestampCreated
+	meta := New(nil, "test", config).parseMetadata(page)
+	if got := meta.Properties["date"]; got != "2025-04-01T09:00:00Z" {
+		t.Errorf("Expected date from the Published property, got %v", got)
+	}
+	if got := meta.Properties["lastmod"]; got != "2025-01-01T00:00:00Z" {
+		t.Errorf("Expected lastmod from the creation time, got %v", got)
+	}
+	if _, ok := meta.Properties["Published"]; ok {
+		t.Error("Expected the source property to be left out of the front matter")
+	}
+
+	// Pages without a value keep the default
+	delete(page.Properties, "Published")
+	meta = New(nil, "test", config).parseMetadata(page)
+	if got := meta.Properties["date"]; got != "2025-01-01T00:00:00Z" {
+		t.Errorf("Expected the creation time as date, got %v", got)
+	}
+
+	// Properties that are not dates are ignored
+	page.Properties["When"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "Spring 2024"}}}
+	config.DateSource = "When"
+	meta = New(nil, "test", config).parseMetadata(page)
+	if got := meta.Properties["date"]; got != "2025-01-01T00:00:00Z" {
+		t.Errorf("Expected a text property not to be used as date, got %v", got)
+	}
+	if got := meta.Properties["When"]; got != "Spring 2024" {
+		t.Errorf("Expected the text property to stay in the front matter, got %v", got)
+	}
+
+	config.DateSource = TimestampLastEdited
+	meta = New(nil, "test", config).parseMetadata(page)
+	if got := meta.Properties["date"]; got != "2025-05-01T00:00:00Z" {
+		t.Errorf("Expected the last edit time as date, got %v", got)
+	}
+}
+
+func TestRenderPage_ExpiryProperty(t *testing.T) {
+	past := notionapi.Date(time.Date(2020, 6, 30, 0, 0, 0, 0, time.UTC))
+	future := notionapi.Date(time.Now().AddDate(1, 0, 0).UTC().Truncate(time.Second))
+	page := newTestPage("Summer Sale")
+	page.Properties["Expires"] = &notionapi.DateProperty{Date: &notionapi.DateObject{Start: &past}}
+	blocks := []notionapi.Block{paragraph("50% off")}
+
+	config := DefaultRenderConfig()
+	config.ExpiryProperty = "Expires"
+	renderer := New(nil, t.TempDir(), config)
+	meta := renderer.parseMetadata(page)
+	if meta.Properties["expired"] != true || meta.Properties["expiryDate"] != "2020-06-30T00:00:00Z" {
+		t.Errorf("Expected an expired page with its expiry date, got %v", meta.Properties)
+	}
+	if _, ok := meta.Properties["Expires"]; ok {
+		t.Error("Expected the expiry property to be replaced by expiryDate")
+	}
+
+	config.ExpiredPages = ExpiredSkip
+	if _, _, err := renderer.RenderPage(page, blocks, nil, nil); !errors.Is(err, ErrSkipPage) {
+		t.Errorf("Expected the expired page to be skipped, got %v", err)
+	}
+
+	page.Properties["Expires"] = &notionapi.DateProperty{Date: &notionapi.DateObject{Start: &future}}
+	_, content, err := renderer.RenderPage(page, blocks, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+	if !strings.Contains(content, "expiryDate:") || strings.Contains(content, "expired:") {
+		t.Errorf("Expected a current page with only its expiry date, got:\n%s", content)
+	}
+}
+
+func TestParseMetadata_StatusFrontMatter(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.StatusFrontMatter = map[string]map[string]interface{}{
+		"Featured": {"featured": true},
+		"Archived": {"draft": true},
+	}
+	renderer := New(nil, "test", config)
+
+	page := newTestPage("Launch")
+	page.Properties["Status"] = &notionapi.StatusProperty{Status: notionapi.Option{Name: "featured"}}
+	meta := renderer.parseMetadata(page)
+	if meta.Properties["featured"] != true || meta.Properties["status"] != "featured" {
+		t.Errorf("Expected featured: true with the status kept, got %v", meta.Properties)
+	}
+	if _, ok := meta.Properties["draft"]; ok {
+		t.Errorf("Expected no draft flag for a featured page, got %v", meta.Properties["draft"])
+	}
+
+	page.Properties["Status"] = &notionapi.StatusProperty{Status: notionapi.Option{Name: "Archived"}}
+	meta = renderer.parseMetadata(page)
+	if meta.Properties["draft"] != true {
+		t.Errorf("Expected an archived page to be a draft, got %v", meta.Properties)
+	}
+}
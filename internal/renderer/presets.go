@@ -0,0 +1,142 @@
+package renderer
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Built-in presets that switch the templates to the syntax of a specific
+// static site generator or documentation tool.
+const (
+	PresetHugo       = "hugo"
+	PresetHexo       = "hexo"
+	PresetJekyll     = "jekyll"
+	PresetMkDocs     = "mkdocs"
+	PresetDocusaurus = "docusaurus"
+	PresetZola       = "zola"
+)
+
+// presets maps preset names to the adjustments they make over
+// DefaultRenderConfig
+var presets = map[string]func(*RenderConfig){
+	PresetHugo:       func(*RenderConfig) {}, // the defaults target Hugo
+	PresetHexo:       applyHexoPreset,
+	PresetJekyll:     applyJekyllPreset,
+	PresetMkDocs:     applyMkDocsPreset,
+	PresetDocusaurus: applyDocusaurusPreset,
+	PresetZola:       applyZolaPreset,
+}
+
+// htmlDetailsTemplate renders toggles as plain HTML details elements
+const htmlDetailsTemplate = "<details>\n<summary>{{.Summary}}</summary>\n\n{{.Content}}\n\n</details>"
+
+// applyHexoPreset targets Hexo with post asset folders and the NexT theme's
+// note tag for callouts. Use Hexo's source directory as the output.
+func applyHexoPreset(c *RenderConfig) {
+	c.PathStyle = PathStyleHexo
+	c.AssetLayout = AssetLayoutHexo
+	// asset_img only finds files in the post's asset folder
+	c.LocalImageTemplate = "{% asset_img {{.URL}} {{.QuotedAlt}} %}"
+	c.CalloutStyle = CalloutStyleTemplate
+	c.CalloutTemplate = "{% note {{.Type}} %}\n{{.Body}}\n{% endnote %}"
+	c.CalloutTypeMap = map[string]string{
+		"note":     "default",
+		"tip":      "success",
+		"question": "info",
+		"example":  "info",
+		"quote":    "default",
+		"failure":  "danger",
+		"bug":      "danger",
+	}
+	c.ToggleStyle = ToggleStyleDetails
+	c.DetailsTemplate = htmlDetailsTemplate
+	c.MathTemplate = "$$\n{{.Expression}}\n$$"
+	c.VideoTemplate = "{% video \"{{.URL}}\" %}"
+	c.PDFTemplate = "[{{.Text}}]({{.URL}})"
+	c.EmbedTemplate = "<iframe src=\"{{.URL}}\" width=\"100%\" height=\"400\"></iframe>"
+}
+
+// applyJekyllPreset targets Jekyll with kramdown: plain HTML and Markdown
+// only, since Liquid includes depend on the site.
+func applyJekyllPreset(c *RenderConfig) {
+	c.PathStyle = PathStyleJekyll
+	c.ToggleStyle = ToggleStyleDetails
+	c.DetailsTemplate = htmlDetailsTemplate
+	c.MathTemplate = "$$\n{{.Expression}}\n$$"
+	c.VideoTemplate = "<video controls src=\"{{.URL}}\"></video>"
+	c.PDFTemplate = "[{{.Text}}]({{.URL}})"
+	c.EmbedTemplate = "<iframe src=\"{{.URL}}\" width=\"100%\" height=\"400\"></iframe>"
+}
+
+// applyZolaPreset targets Zola: TOML front matter with taxonomies and extra
+// tables, sections with an "_index.md" and "{{ name(args) }}" shortcodes.
+func applyZolaPreset(c *RenderConfig) {
+	c.FrontMatterFormat = FrontMatterZola
+	c.PathStyle = PathStyleZola
+	c.ToggleStyle = ToggleStyleDetails
+	c.DetailsTemplate = htmlDetailsTemplate
+	c.MathTemplate = "{% katex(block=true) %}\n{{.Expression}}\n{% end %}"
+	c.VideoTemplate = "{{ video(src=\"{{.URL}}\") }}"
+	c.PDFTemplate = "[{{.Text}}]({{.URL}})"
+	c.EmbedTemplate = "<iframe src=\"{{.URL}}\" width=\"100%\" height=\"400\"></iframe>"
+}
+
+// applyMkDocsPreset targets MkDocs Material: "!!!" admonitions, collapsible
+// "???" blocks for toggles and arithmatex math.
+func applyMkDocsPreset(c *RenderConfig) {
+	c.CalloutStyle = CalloutStyleTemplate
+	c.CalloutTemplate = "!!! {{.Type}}\n\n    {{.IndentedContent}}"
+	c.ToggleStyle = ToggleStyleDetails
+	c.DetailsTemplate = "??? note \"{{.Summary}}\"\n\n    {{.IndentedContent}}"
+	c.MathTemplate = "$$\n{{.Expression}}\n$$"
+	c.VideoTemplate = "<video controls src=\"{{.URL}}\"></video>"
+	c.PDFTemplate = "[{{.Text}}]({{.URL}})"
+	c.EmbedTemplate = "<iframe src=\"{{.URL}}\" width=\"100%\" height=\"400\"></iframe>"
+}
+
+// applyDocusaurusPreset targets Docusaurus MDX: ":::" admonitions limited to
+// the types Docusaurus knows, HTML details and its doc front matter keys.
+func applyDocusaurusPreset(c *RenderConfig) {
+	c.CalloutStyle = CalloutStyleTemplate
+	c.CalloutTemplate = ":::{{.Type}}\n\n{{.Body}}\n\n:::"
+	c.CalloutTypeMap = map[string]string{
+		"success":  "tip",
+		"question": "info",
+		"example":  "info",
+		"quote":    "note",
+		"failure":  "danger",
+		"bug":      "danger",
+	}
+	c.ToggleStyle = ToggleStyleDetails
+	c.DetailsTemplate = htmlDetailsTemplate
+	c.MathTemplate = "$$\n{{.Expression}}\n$$"
+	c.VideoTemplate = "<video controls src=\"{{.URL}}\"></video>"
+	c.PDFTemplate = "[{{.Text}}]({{.URL}})"
+	c.EmbedTemplate = "<iframe src=\"{{.URL}}\" width=\"100%\" height=\"400\"></iframe>"
+	c.FrontMatterKeys = map[string]string{
+		"slug":  "id",
+		"order": "sidebar_position",
+	}
+}
+
+// PresetNames returns the names of the built-in presets in sorted order.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PresetConfig returns the default configuration with the named preset
+// applied over it.
+func PresetConfig(name string) (*RenderConfig, error) {
+	apply, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (available: %v)", name, PresetNames())
+	}
+	config := DefaultRenderConfig()
+	apply(config)
+	return config, nil
+}
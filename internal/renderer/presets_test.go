@@ -0,0 +1,182 @@
+package renderer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jomei/notionapi"
+)
+
+func TestPresetConfig_MkDocs(t *testing.T) {
+	config, err := PresetConfig(PresetMkDocs)
+	if err != nil {
+		t.Fatalf("Unexpected error loading preset: %v", err)
+	}
+
+	block := &notionapi.CalloutBlock{
+		Callout: notionapi.Callout{
+			RichText: []notionapi.RichText{{PlainText: "Remember this", Text: &notionapi.Text{Content: "Remember this"}}},
+		},
+	}
+	expected := "!!! note\n\n    Remember this\n\n    - and this"
+	if got := calloutToMarkdown(block, "- and this", nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	if _, err := PresetConfig("unknown"); err == nil {
+		t.Error("Expected an error for an unknown preset")
+	}
+}
+
+func TestPresetConfig_Docusaurus(t *testing.T) {
+	config, err := PresetConfig(PresetDocusaurus)
+	if err != nil {
+		t.Fatalf("Unexpected error loading preset: %v", err)
+	}
+
+	emoji := notionapi.Emoji("✅")
+	block := &notionapi.CalloutBlock{
+		Callout: notionapi.Callout{
+			RichText: []notionapi.RichText{{PlainText: "All done", Text: &notionapi.Text{Content: "All done"}}},
+			Icon:     &notionapi.Icon{Type: "emoji", Emoji: &emoji},
+		},
+	}
+	expected := ":::tip\n\nAll done\n\nNext steps\n\n:::"
+	if got := calloutToMarkdown(block, "Next steps", nil, config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	// Front matter keys are renamed for Docusaurus
+	page := newTestPage("Getting Started")
+	page.Properties["Order"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "2"}}}
+	_, content, err := New(nil, t.TempDir(), config).RenderPage(page, []notionapi.Block{paragraph("Hi")}, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+	if !strings.Contains(content, "id: getting-started\n") || !strings.Contains(content, "sidebar_position: \"2\"\n") {
+		t.Errorf("Expected id and sidebar_position front matter, got:\n%s", content)
+	}
+	if strings.Contains(content, "slug:") {
+		t.Errorf("Expected slug to be renamed to id, got:\n%s", content)
+	}
+
+	// The page's slug wins over slug and id properties
+	page.Properties["Slug"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "Intro Page"}}}
+	page.Properties["ID"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "legacy-7"}}}
+	_, content, err = New(nil, t.TempDir(), config).RenderPage(page, []notionapi.Block{paragraph("Hi")}, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+	if strings.Count(content, "id: ") != 1 || !strings.Contains(content, "id: intro-page\n") || strings.Contains(content, "legacy-7") {
+		t.Errorf("Expected the page's slug as its id, got:\n%s", content)
+	}
+}
+
+func TestPresetConfig_HexoImages(t *testing.T) {
+	config, err := PresetConfig(PresetHexo)
+	if err != nil {
+		t.Fatalf("Unexpected error loading preset: %v", err)
+	}
+	caption := []notionapi.RichText{{PlainText: "A cat"}}
+
+	// asset_img only finds files in the post's asset folder, so external
+	// and uncached images stay Markdown images
+	external := &notionapi.ImageBlock{Image: notionapi.Image{
+		External: &notionapi.FileObject{URL: "https://example.com/cat.png"},
+		Caption:  caption,
+	}}
+	expected := "![A cat](https://example.com/cat.png)"
+	if got := imageToMarkdownWithCache(external, NewFileCache(t.TempDir()), "_posts/cats.md", config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+	uploaded := &notionapi.ImageBlock{Image: notionapi.Image{
+		File:    &notionapi.FileObject{URL: "https://file.notion.so/f/cat.png"},
+		Caption: caption,
+	}}
+	expected = "![A cat](https://file.notion.so/f/cat.png)"
+	if got := imageToMarkdownWithCache(uploaded, nil, "", config); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	if got := quoteTagArg(`A "fat" cat`); got != `"A 'fat' cat"` {
+		t.Errorf("Expected alt text quoted as one argument, got %s", got)
+	}
+}
+
+func TestLoadConfigWithPreset(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "xdg"))
+	t.Setenv("HOME", filepath.Join(tempDir, "home"))
+
+	configPath := filepath.Join(tempDir, "config.yaml")
+	writeConfigFile(t, configPath, "video_template: custom\n")
+
+	config, used, err := LoadConfigWithPreset(configPath, PresetHexo)
+	if err != nil {
+		t.Fatalf("Unexpected error loading preset: %v", err)
+	}
+	if used != configPath {
+		t.Errorf("Expected config file to be used, got '%s'", used)
+	}
+
+	expected := map[string]string{
+		"math":    "$$\n{{.Expression}}\n$$",
+		"details": "<details>\n<summary>{{.Summary}}</summary>\n\n{{.Content}}\n\n</details>",
+		"callout": "{% note {{.Type}} %}\n{{.Body}}\n{% endnote %}",
+		"video":   "custom", // explicit config wins over the preset
+	}
+	got := map[string]string{
+		"math":    config.MathTemplate,
+		"details": config.DetailsTemplate,
+		"callout": config.CalloutTemplate,
+		"video":   config.VideoTemplate,
+	}
+	for name, want := range expected {
+		if got[name] != want {
+			t.Errorf("Expected %s template '%s', got '%s'", name, want, got[name])
+		}
+	}
+	if config.FileTemplate != DefaultRenderConfig().FileTemplate {
+		t.Errorf("Expected untouched fields to keep their defaults, got '%s'", config.FileTemplate)
+	}
+
+	if _, _, err := LoadConfigWithPreset(configPath, "unknown"); err == nil {
+		t.Error("Expected an error for an unknown preset")
+	}
+}
+
+func TestPresetConfig_Zola(t *testing.T) {
+	config, err := PresetConfig(PresetZola)
+	if err != nil {
+		t.Fatalf("Unexpected error loading preset: %v", err)
+	}
+
+	page := newTestPage("Hello \"Zola\"")
+	page.Properties["Tags"] = &notionapi.MultiSelectProperty{MultiSelect: []notionapi.Option{{Name: "rust"}, {Name: "ssg"}}}
+	page.Properties["Summary"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "Short"}}}
+	video := &notionapi.VideoBlock{Video: notionapi.Video{External: &notionapi.FileObject{URL: "https://example.com/clip.mp4"}}}
+
+	_, content, err := New(nil, t.TempDir(), config).RenderPage(page, []notionapi.Block{video}, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+
+	expected := `+++
+date = 2025-01-15T10:00:00Z
+title = "Hello \"Zola\""
+updated = 2025-01-15T10:00:00Z
+
+[extra]
+Summary = "Short"
+
+[taxonomies]
+tags = ["rust", "ssg"]
++++
+
+{{ video(src="https://example.com/clip.mp4") }}
+`
+	if content != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, content)
+	}
+}
@@ -5,9 +5,21 @@
 package renderer
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
+	"unicode"
 
 	"github.com/jomei/notionapi"
 	"gopkg.in/yaml.v3"
@@ -26,48 +38,448 @@ type Renderer struct {
 
 	// config controls how non-standard markdown elements are rendered
 	config *RenderConfig
+
+	// propertyOrder lists the database's property names in column order
+	propertyOrder []string
+
+	// defaultType is the type of pages without a type property
+	defaultType string
+
+	// parents maps the normalized IDs of pages in a page tree to their
+	// parent page
+	parents map[string]notionapi.Page
+
+	// comments fetches the comments on a block when CommentStyle is set
+	comments func(id notionapi.BlockID) ([]notionapi.Comment, error)
+
+	// columnWidths fetches the width ratios of the columns of a column list,
+	// keyed by column ID, for the {{.Width}} placeholder of ColumnTemplate
+	columnWidths func(id notionapi.BlockID) (map[notionapi.BlockID]float64, error)
+
+	// children caches the child blocks fetched during the run by block ID,
+	// so subtrees shared between pages (e.g. synced blocks) are fetched once
+	children map[notionapi.BlockID][]notionapi.Block
+
+	// translations groups the pages registered by AddTranslation by their
+	// translation key
+	translations map[string][]notionapi.Page
+
+	// frontMatterTemplate is FrontMatterTemplate, parsed on first use
+	frontMatterTemplate *template.Template
 }
 
 // New constructs a Renderer with link resolver, file caching and custom config.
+// A nil config falls back to DefaultRenderConfig.
 func New(resolve func(string) string, basePath string, config *RenderConfig) *Renderer {
+	if config == nil {
+		config = DefaultRenderConfig()
+	}
+	fileCache := NewFileCache(basePath)
+	fileCache.layout = config.AssetLayout
+	fileCache.cacheDir = config.CacheDir
+	fileCache.revalidate = config.RevalidateAssets
+	fileCache.headers = config.AssetHeaders
+	fileCache.maxFileSize = config.MaxFileSize
 	return &Renderer{
 		resolve:   resolve,
-		fileCache: NewFileCache(basePath),
+		fileCache: fileCache,
 		config:    config,
 	}
 }
 
+// SetBlockGetter lets expired Notion file URLs be refreshed by re-fetching
+// their block with get before the download is retried.
+func (r *Renderer) SetBlockGetter(get func(id notionapi.BlockID) (notionapi.Block, error)) {
+	r.fileCache.refresh = func(id notionapi.BlockID) (string, error) {
+		block, err := get(id)
+		if err != nil {
+			return "", err
+		}
+		extractor, ok := fileURLExtractorFor(block)
+		if !ok {
+			return "", fmt.Errorf("block %s has no file", id)
+		}
+		url, _ := extractor.getFileURL()
+		if url == "" {
+			return "", fmt.Errorf("block %s has no file", id)
+		}
+		return url, nil
+	}
+}
+
+// SetPropertyOrder sets the names of the database's properties in column
+// order, which front matter keys follow when SchemaOrder is set.
+func (r *Renderer) SetPropertyOrder(names []string) {
+	r.propertyOrder = names
+}
+
+// SetDefaultType sets the type, and so the section, of pages without a type
+// property.
+func (r *Renderer) SetDefaultType(t string) {
+	r.defaultType = t
+}
+
+// SetCommentGetter sets the function fetching the comments on a block,
+// which are exported when CommentStyle is set.
+func (r *Renderer) SetCommentGetter(get func(id notionapi.BlockID) ([]notionapi.Comment, error)) {
+	r.comments = get
+}
+
+// SetColumnWidthGetter sets the function fetching the width ratios of the
+// columns of a column list, which ColumnTemplate receives as {{.Width}}.
+// Without it, columns share the width equally.
+func (r *Renderer) SetColumnWidthGetter(get func(id notionapi.BlockID) (map[notionapi.BlockID]float64, error)) {
+	r.columnWidths = get
+}
+
+// SetParent records that page is a child page of parent. With
+// PathStyleBundle, child pages are written inside their parent's directory,
+// e.g. "parent/child/index.md".
+func (r *Renderer) SetParent(page, parent notionapi.Page) {
+	if r.parents == nil {
+		r.parents = map[string]notionapi.Page{}
+	}
+	r.parents[strings.ReplaceAll(string(page.ID), "-", "")] = parent
+}
+
+// AddTranslation registers a page with the group of pages sharing its
+// TranslationKeyProperty value, so that TranslationsField lists the other
+// pages of the group. Pages without a key are ignored.
+func (r *Renderer) AddTranslation(page notionapi.Page) {
+	key := r.translationKey(page)
+	if key == "" {
+		return
+	}
+	if r.translations == nil {
+		r.translations = map[string][]notionapi.Page{}
+	}
+	r.translations[key] = append(r.translations[key], page)
+}
+
+// translationKey returns the value of the page's TranslationKeyProperty
+func (r *Renderer) translationKey(page notionapi.Page) string {
+	if r.config.TranslationKeyProperty == "" {
+		return ""
+	}
+	for k, prop := range page.Properties {
+		if strings.EqualFold(k, r.config.TranslationKeyProperty) {
+			if str, ok := extractPropertyValue(prop).(string); ok {
+				return strings.TrimSpace(str)
+			}
+		}
+	}
+	return ""
+}
+
+// addTranslations lists the site paths of the other translations of the
+// page, by language, in the TranslationsField front matter.
+func (r *Renderer) addTranslations(m *metadata, page notionapi.Page) {
+	if r.config.TranslationsField == "" {
+		return
+	}
+	translations := map[string]string{}
+	for _, other := range r.translations[r.translationKey(page)] {
+		if other.ID == page.ID {
+			continue
+		}
+		om := r.parseMetadata(other)
+		if om.lang != "" && om.lang != m.lang {
+			translations[om.lang] = withBasePrefix(r.pagePath(om), r.config.LinkBasePrefix)
+		}
+	}
+	if len(translations) > 0 {
+		m.Properties[r.config.TranslationsField] = translations
+	}
+}
+
+// ErrSkipPage is returned by RenderPage for pages that should not be written,
+// such as empty pages when SkipEmptyPages is set.
+var ErrSkipPage = errors.New("page skipped")
+
+// Transformer post-processes the rendered Markdown body of a page, e.g. to
+// inject custom shortcodes or run a formatter. It receives the source page and
+// the current body and returns the new body.
+type Transformer func(page notionapi.Page, markdown string) (string, error)
+
 // RenderPage converts a Notion page and its provided top-level blocks into a
 // filename and file content (YAML front matter + Markdown body). The
 // getChildren callback is used to lazily fetch block children; this keeps the
 // method side-effect free for testing when a mock callback is provided.
-func (r *Renderer) RenderPage(page notionapi.Page, blocks []notionapi.Block, getChildren func(notionapi.BlockID) ([]notionapi.Block, error), resolve func(string) string) (string, string, error) {
+// Optional transformers are applied in order to the rendered body.
+func (r *Renderer) RenderPage(page notionapi.Page, blocks []notionapi.Block, getChildren func(notionapi.BlockID) ([]notionapi.Block, error), resolve func(string) string, transforms ...Transformer) (string, string, error) {
 	meta := r.parseMetadata(page)
+	if r.skipExpired(page, meta) {
+		return "", "", ErrSkipPage
+	}
 	filename := r.buildFilename(meta)
 
+	body, doc, err := r.renderBody(page, blocks, getChildren, resolve, filename, transforms)
+	if err != nil {
+		return "", "", err
+	}
+	if _, titled := meta.Properties["title"]; !titled {
+		slog.Warn("⚠️ Page has no title, using fallback slug", "page", page.ID, "slug", meta.Slug)
+	}
+	if r.skipEmpty(page, meta, body) {
+		return "", "", ErrSkipPage
+	}
+	if strings.TrimSpace(body) == "" {
+		slog.Warn("⚠️ Page has an empty body", "page", page.ID, "title", meta.Title)
+	}
+	r.addReadingStats(&meta, body)
+	r.addTranslations(&meta, page)
+	if r.config.TOCField != "" && len(doc.headings) > 0 {
+		meta.Properties[r.config.TOCField] = doc.headings
+	}
+
+	body = r.wrapBody(page, meta, body)
+
+	fm, err := r.buildFrontMatter(meta)
+	if err != nil {
+		return "", "", err
+	}
+	// POSIX tools and linters expect exactly one trailing newline
+	return filename, strings.TrimRight(fm+body, "\n") + "\n", nil
+}
+
+// wrapBody surrounds body with the configured BodyPrefix and BodySuffix
+// and appends the EditLinkTemplate footer.
+func (r *Renderer) wrapBody(page notionapi.Page, m metadata, body string) string {
+	if r.config.BodyPrefix == "" && r.config.BodySuffix == "" && r.config.EditLinkTemplate == "" {
+		return body
+	}
+	data := map[string]string{
+		"Title": m.Title,
+		"Slug":  m.Slug,
+		"ID":    string(page.ID),
+		"URL":   notionPageURL(page),
+		"Path":  r.GetPagePath(page),
+	}
+	parts := make([]string, 0, 4)
+	for _, part := range []string{
+		renderTemplate(r.config.BodyPrefix, data),
+		body,
+		renderTemplate(r.config.BodySuffix, data),
+		renderTemplate(r.config.EditLinkTemplate, data),
+	} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// notionPageURL returns the URL of the page in Notion, derived from its ID
+// when the API did not include it
+func notionPageURL(page notionapi.Page) string {
+	if page.URL != "" {
+		return page.URL
+	}
+	return "https://www.notion.so/" + strings.ReplaceAll(string(page.ID), "-", "")
+}
+
+// RenderBody renders only the Markdown body of a page, without front matter.
+// articlePath is the output file the body will be written to; downloaded files
+// are stored next to it. Optional transformers are applied to the result.
+// Like RenderPage, it returns ErrSkipPage for pages that should not be written.
+func (r *Renderer) RenderBody(page notionapi.Page, blocks []notionapi.Block, getChildren func(notionapi.BlockID) ([]notionapi.Block, error), resolve func(string) string, articlePath string, transforms ...Transformer) (string, error) {
+	meta := r.parseMetadata(page)
+	if r.skipExpired(page, meta) {
+		return "", ErrSkipPage
+	}
+	body, _, err := r.renderBody(page, blocks, getChildren, resolve, articlePath, transforms)
+	if err != nil {
+		return "", err
+	}
+	if r.skipEmpty(page, meta, body) {
+		return "", ErrSkipPage
+	}
+	return body, nil
+}
+
+// skipExpired reports whether the page is expired and ExpiredPages drops it
+func (r *Renderer) skipExpired(page notionapi.Page, meta metadata) bool {
+	if !meta.expired || r.config.ExpiredPages != ExpiredSkip {
+		return false
+	}
+	slog.Info("⏰ Skipping expired page", "page", page.ID, "title", meta.Title, "expired", meta.Properties["expiryDate"])
+	return true
+}
+
+// Expired reports whether ExpiredPages drops the page because it is past its
+// ExpiryProperty date, so that links to it are not resolved
+func (r *Renderer) Expired(page notionapi.Page) bool {
+	return r.config.ExpiredPages == ExpiredSkip && r.parseMetadata(page).expired
+}
+
+// skipEmpty reports whether body is empty and SkipEmptyPages drops the page
+func (r *Renderer) skipEmpty(page notionapi.Page, meta metadata, body string) bool {
+	if !r.config.SkipEmptyPages || strings.TrimSpace(body) != "" {
+		return false
+	}
+	slog.Warn("⚠️ Skipping page with empty body", "page", page.ID, "title", meta.Title)
+	return true
+}
+
+// renderBody renders and transforms the body, returning it along with the
+// state collected while rendering.
+func (r *Renderer) renderBody(page notionapi.Page, blocks []notionapi.Block, getChildren func(notionapi.BlockID) ([]notionapi.Block, error), resolve func(string) string, articlePath string, transforms []Transformer) (string, *document, error) {
 	// render body using recursive helper
 	// prefer resolver passed to RenderPage, otherwise use renderer's resolver
 	if resolve == nil {
 		resolve = r.resolve
 	}
-	body, err := r.renderBlocksRecursive(blocks, getChildren, resolve, filename)
+	if r.config.RelativeLinks && resolve != nil {
+		resolve = relativeResolver(resolve, r.GetPagePath(page))
+	}
+	doc := &document{}
+	body, err := r.renderBlocksRecursive(doc, blocks, r.cachedChildren(getChildren), resolve, articlePath)
 	if err != nil {
-		return "", "", err
+		return "", nil, err
+	}
+	// Empty pages stay empty, so that SkipEmptyPages still applies
+	if props := r.bodyProperties(page); props != "" && strings.TrimSpace(body) != "" {
+		body = props + "\n\n" + body
+	}
+	if len(doc.footnotes) > 0 {
+		body += "\n\n" + strings.Join(doc.footnotes, "\n")
 	}
+	if section := doc.commentSection(r.config.CommentStyle); section != "" {
+		body += "\n\n" + section
+	}
+	body = collapseBlankLines(body)
+	for _, transform := range transforms {
+		if body, err = transform(page, body); err != nil {
+			return "", nil, err
+		}
+	}
+	if r.config.SanitizeHTML {
+		body = sanitizeHTML(body, r.config.HTMLAllowlist)
+	}
+	return body, doc, nil
+}
 
-	fm, err := r.buildFrontMatter(meta)
-	if err != nil {
-		return "", "", err
+// bodyProperties renders the page's BodyProperties that have a value as a
+// table or definition list.
+func (r *Renderer) bodyProperties(page notionapi.Page) string {
+	var names, values []string
+	for _, want := range r.config.BodyProperties {
+		for k, prop := range page.Properties {
+			if !strings.EqualFold(k, want) {
+				continue
+			}
+			if value := propertyText(prop, r.config); value != "" {
+				names = append(names, k)
+				values = append(values, value)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	if r.config.BodyPropertiesStyle == BodyPropertiesDefinitions {
+		for i, name := range names {
+			if i > 0 {
+				b.WriteString("\n\n")
+			}
+			b.WriteString(name + "\n: " + values[i])
+		}
+		return b.String()
+	}
+	row := func(cells []string) {
+		for _, cell := range cells {
+			b.WriteString("| " + escapeCellPipes(cell) + " ")
+		}
+		b.WriteString("|\n")
+	}
+	row(names)
+	b.WriteString(strings.Repeat("| --- ", len(names)) + "|\n")
+	row(values)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// propertyText renders a property's value as text for the page body
+func propertyText(prop notionapi.Property, config *RenderConfig) string {
+	switch v := prop.(type) {
+	case *notionapi.DateProperty:
+		if v.Date != nil {
+			return formatDateMention(v.Date, config)
+		}
+		return ""
+	case *notionapi.CheckboxProperty:
+		if v.Checkbox {
+			return "Yes"
+		}
+		return "No"
+	case *notionapi.RichTextProperty:
+		return plainText(v.RichText)
+	case *notionapi.TitleProperty:
+		return plainText(v.Title)
+	}
+	return valueText(extractPropertyValue(prop))
+}
+
+// valueText formats a front matter value as text
+func valueText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		return strings.Join(v, ", ")
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, valueText(item))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(value)
+}
+
+// cachedChildren wraps getChildren so that the children of each block are
+// fetched at most once per Renderer. Failed fetches are not cached.
+func (r *Renderer) cachedChildren(getChildren func(notionapi.BlockID) ([]notionapi.Block, error)) func(notionapi.BlockID) ([]notionapi.Block, error) {
+	if getChildren == nil {
+		return nil
+	}
+	return func(id notionapi.BlockID) ([]notionapi.Block, error) {
+		if children, ok := r.children[id]; ok {
+			return children, nil
+		}
+		children, err := getChildren(id)
+		if err != nil {
+			return nil, err
+		}
+		if r.children == nil {
+			r.children = map[notionapi.BlockID][]notionapi.Block{}
+		}
+		r.children[id] = children
+		return children, nil
 	}
-	return filename, fm + body, nil
+}
+
+// AssetFailures returns the files that could not be downloaded while
+// rendering. Those files are linked by their original URL instead.
+func (r *Renderer) AssetFailures() []AssetFailure {
+	return r.fileCache.Failures()
 }
 
 // metadata gathers the common properties used in frontmatter and filename logic.
 type metadata struct {
 	// Core fields needed for functionality
-	Title    string `yaml:"title"`
-	Slug     string `yaml:"slug,omitempty"`
-	pathType string `yaml:"-"` // Used internally for path generation logic
+	Title    string    `yaml:"title"`
+	Slug     string    `yaml:"slug,omitempty"`
+	pathType string    `yaml:"-"` // Used internally for path generation logic
+	path     string    `yaml:"-"` // Explicit site path from PathProperty, e.g. "about/team"
+	parent   *metadata // Parent page in a page tree
+	lang     string    // Language code from LanguageProperty, e.g. "zh"
+	expired  bool      // The date of ExpiryProperty has passed
 
 	// All properties including user-defined ones
 	Properties map[string]interface{} `yaml:",inline"`
@@ -91,13 +503,19 @@ func (r *Renderer) parseMetadata(page notionapi.Page) metadata {
 	for k, prop := range page.Properties {
 		lowerKey := strings.ToLower(k)
 
-		// Handle special properties that affect internal logic
-		switch lowerKey {
-		case "title", "name":
-			if tp, ok := prop.(*notionapi.TitleProperty); ok && len(tp.Title) > 0 {
+		// The database's title property is the title whatever its name
+		if tp, ok := prop.(*notionapi.TitleProperty); ok {
+			if len(tp.Title) > 0 {
 				m.Title = tp.Title[0].PlainText
 				m.Properties["title"] = m.Title
 			}
+			continue
+		}
+
+		// Handle special properties that affect internal logic
+		switch lowerKey {
+		case "title", "name":
+			// other properties with these names would clash with the title
 		case "slug":
 			value := extractPropertyValue(prop)
 			if str, ok := value.(string); ok && str != "" {
@@ -150,6 +568,14 @@ func (r *Renderer) parseMetadata(page notionapi.Page) metadata {
 					m.Properties["draft"] = true
 				}
 				// Note: We don't set draft: false to allow omitempty behavior
+				// Configured effects, e.g. Archived -> draft: true
+				for status, values := range r.config.StatusFrontMatter {
+					if strings.EqualFold(status, statusName) {
+						for key, value := range values {
+							m.Properties[key] = value
+						}
+					}
+				}
 			}
 		default:
 			// Handle all other properties dynamically
@@ -160,11 +586,38 @@ func (r *Renderer) parseMetadata(page notionapi.Page) metadata {
 		}
 	}
 
+	// Configured sources replace the default timestamps
+	if value := r.timestamp(page, r.config.DateSource, &m); value != "" {
+		m.Properties["date"] = value
+	}
+	if value := r.timestamp(page, r.config.LastmodSource, &m); value != "" {
+		m.Properties["lastmod"] = value
+	}
+
+	// A configured title property replaces the "title"/"name" property
+	if r.config.TitleProperty != "" {
+		if key, title := titleProperty(page, r.config.TitleProperty); title != "" {
+			if !strings.EqualFold(key, "title") {
+				delete(m.Properties, key)
+			}
+			m.Title = title
+			m.Properties["title"] = title
+		}
+	}
+
 	// Set defaults
 	if m.Slug == "" {
 		m.Slug = m.Title
 	}
-	m.Slug = slugify(m.Slug)
+	if r.config.PreserveSlugCase {
+		m.Slug = slugifyCase(m.Slug)
+	} else {
+		m.Slug = slugify(m.Slug)
+	}
+	m.Slug = truncateSlug(m.Slug, r.config.MaxSlugLength)
+	if r.config.WindowsSafeNames {
+		m.Slug = windowsSafeName(m.Slug)
+	}
 
 	// Set default pathType if not set
 	if m.pathType == "" {
@@ -174,10 +627,271 @@ func (r *Renderer) parseMetadata(page notionapi.Page) metadata {
 			}
 		}
 	}
+	if m.pathType == "" {
+		m.pathType = strings.ToLower(r.pageDefaultType(page))
+	}
+
+	if parent, ok := r.parents[strings.ReplaceAll(string(page.ID), "-", "")]; ok {
+		pm := r.parseMetadata(parent)
+		m.parent = &pm
+	}
+	if r.config.AncestorsField != "" && m.parent != nil {
+		var ancestors []string
+		for p := m.parent; p != nil; p = p.parent {
+			ancestors = append([]string{p.Slug}, ancestors...)
+		}
+		m.Properties[r.config.AncestorsField] = ancestors
+	}
+
+	if r.config.PathProperty != "" {
+		for k, prop := range page.Properties {
+			if !strings.EqualFold(k, r.config.PathProperty) {
+				continue
+			}
+			if str, ok := extractPropertyValue(prop).(string); ok {
+				// Cleaning a rooted path also drops ".." segments
+				m.path = strings.Trim(path.Clean("/"+strings.TrimSpace(str)), "/")
+			}
+		}
+	}
+
+	// Previous slugs become aliases so old URLs keep working
+	if r.config.AliasesProperty != "" {
+		for k, prop := range page.Properties {
+			if !strings.EqualFold(k, r.config.AliasesProperty) {
+				continue
+			}
+			delete(m.Properties, k)
+			if aliases := propertyList(prop); len(aliases) > 0 {
+				m.Properties["aliases"] = aliases
+			}
+		}
+	}
+
+	// The language is expressed by the file name or directory
+	if r.config.LanguageProperty != "" {
+		for k, prop := range page.Properties {
+			if !strings.EqualFold(k, r.config.LanguageProperty) {
+				continue
+			}
+			delete(m.Properties, k)
+			if str, ok := extractPropertyValue(prop).(string); ok {
+				m.lang = r.languageCode(str)
+			}
+		}
+	}
+
+	// Hugo associates translations by their translationKey
+	if key := r.translationKey(page); key != "" {
+		for k := range page.Properties {
+			if strings.EqualFold(k, r.config.TranslationKeyProperty) {
+				delete(m.Properties, k)
+			}
+		}
+		m.Properties["translationKey"] = key
+	}
+
+	// Expiring pages get Hugo's expiryDate, and are marked once expired
+	if r.config.ExpiryProperty != "" {
+		for k, prop := range page.Properties {
+			if !strings.EqualFold(k, r.config.ExpiryProperty) {
+				continue
+			}
+			delete(m.Properties, k)
+			str, ok := extractPropertyValue(prop).(string)
+			if !ok {
+				continue
+			}
+			expires, err := time.Parse(time.RFC3339, str)
+			if err != nil {
+				continue
+			}
+			m.Properties["expiryDate"] = str
+			m.expired = !expires.After(time.Now())
+			if m.expired && r.config.ExpiredPages != ExpiredSkip {
+				m.Properties["expired"] = true
+			}
+		}
+	}
+
+	// The ordering property becomes the page's weight
+	if weight, ok := r.Weight(page); ok {
+		for k := range page.Properties {
+			if strings.EqualFold(k, r.config.WeightProperty) {
+				delete(m.Properties, k)
+			}
+		}
+		if weight == math.Trunc(weight) {
+			m.Properties["weight"] = int(weight)
+		} else {
+			m.Properties["weight"] = weight
+		}
+	}
+
+	// Pages without an author property are credited to their creator. The
+	// API only includes the user's name when the integration may read users.
+	if r.config.AuthorFromCreator && page.CreatedBy.Name != "" && !hasKey(m.Properties, "author") {
+		m.Properties["author"] = page.CreatedBy.Name
+	}
+
+	if r.config.NotionIDField != "" && page.ID != "" {
+		m.Properties[r.config.NotionIDField] = strings.ReplaceAll(string(page.ID), "-", "")
+	}
+
+	// Fill in the configured defaults, the more specific per-type ones first
+	for pathType, defaults := range r.config.TypeFrontMatterDefaults {
+		if strings.EqualFold(pathType, m.pathType) {
+			mergeDefaults(m.Properties, defaults)
+		}
+	}
+	mergeDefaults(m.Properties, r.config.FrontMatterDefaults)
 
 	return m
 }
 
+// propertyList returns the values of a multi-select property, or the comma
+// separated entries of a text property.
+func propertyList(prop notionapi.Property) []string {
+	var values []string
+	switch v := extractPropertyValue(prop).(type) {
+	case []string:
+		values = v
+	case string:
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// titleProperty returns the key and text of the page's property called name
+// (compared case-insensitively), falling back to its title-type property
+func titleProperty(page notionapi.Page, name string) (key, title string) {
+	for k, prop := range page.Properties {
+		if strings.EqualFold(k, name) {
+			if str, ok := extractPropertyValue(prop).(string); ok && str != "" {
+				return k, str
+			}
+		}
+	}
+	for k, prop := range page.Properties {
+		if tp, ok := prop.(*notionapi.TitleProperty); ok && len(tp.Title) > 0 {
+			return k, tp.Title[0].PlainText
+		}
+	}
+	return "", ""
+}
+
+// hasKey reports whether props has key, compared case-insensitively
+func hasKey(props map[string]interface{}, key string) bool {
+	for k := range props {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeDefaults copies default front matter values into props, keeping any
+// value the page already sets under the same key in any case.
+func mergeDefaults(props, defaults map[string]interface{}) {
+	for k, v := range defaults {
+		if !hasKey(props, k) {
+			props[k] = v
+		}
+	}
+}
+
+// timestamp returns the page's timestamp named by source, a
+// TimestampCreated, TimestampLastEdited or a property name, or "" when the
+// page has no such value. Only date and timestamp properties qualify; a
+// property used as a source is removed from m.
+func (r *Renderer) timestamp(page notionapi.Page, source string, m *metadata) string {
+	const layout = "2006-01-02T15:04:05Z07:00"
+	switch {
+	case source == "":
+		return ""
+	case strings.EqualFold(source, TimestampCreated):
+		if !page.CreatedTime.IsZero() {
+			return page.CreatedTime.Format(layout)
+		}
+		return ""
+	case strings.EqualFold(source, TimestampLastEdited):
+		if !page.LastEditedTime.IsZero() {
+			return page.LastEditedTime.Format(layout)
+		}
+		return ""
+	}
+	for k, prop := range page.Properties {
+		if !strings.EqualFold(k, source) {
+			continue
+		}
+		str, _ := extractPropertyValue(prop).(string)
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			continue
+		}
+		if !strings.EqualFold(k, "date") {
+			delete(m.Properties, k)
+		}
+		return str
+	}
+	return ""
+}
+
+// languageCode maps a language property value, e.g. "中文", to its code
+// through Languages, and lowercases it
+func (r *Renderer) languageCode(value string) string {
+	value = strings.TrimSpace(value)
+	for name, code := range r.config.Languages {
+		if strings.EqualFold(name, value) {
+			value = code
+			break
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// pageDefaultType returns the type of a page without a type property: the
+// one configured for its database, or the renderer's default.
+func (r *Renderer) pageDefaultType(page notionapi.Page) string {
+	if page.Parent.DatabaseID != "" {
+		id := normalizeDatabaseID(string(page.Parent.DatabaseID))
+		for k, t := range r.config.DatabaseTypes {
+			if normalizeDatabaseID(k) == id {
+				return t
+			}
+		}
+	}
+	return r.defaultType
+}
+
+func normalizeDatabaseID(id string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(id), "-", ""))
+}
+
+// Weight returns the value of the page's WeightProperty, a number or text
+// holding one. It reports false when the page has no such value.
+func (r *Renderer) Weight(page notionapi.Page) (float64, bool) {
+	if r.config.WeightProperty == "" {
+		return 0, false
+	}
+	for k, prop := range page.Properties {
+		if !strings.EqualFold(k, r.config.WeightProperty) {
+			continue
+		}
+		switch v := extractPropertyValue(prop).(type) {
+		case float64:
+			return v, true
+		case string:
+			weight, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return weight, err == nil
+		}
+	}
+	return 0, false
+}
+
 // extractPropertyValue extracts the value from various Notion property types
 func extractPropertyValue(prop notionapi.Property) interface{} {
 	switch v := prop.(type) {
@@ -193,6 +907,31 @@ func extractPropertyValue(prop notionapi.Property) interface{} {
 		if v.Date != nil && v.Date.Start != nil {
 			return time.Time(*v.Date.Start).Format("2006-01-02T15:04:05Z07:00")
 		}
+	case *notionapi.NumberProperty:
+		return v.Number
+	case *notionapi.CreatedTimeProperty:
+		if !v.CreatedTime.IsZero() {
+			return v.CreatedTime.Format("2006-01-02T15:04:05Z07:00")
+		}
+	case *notionapi.LastEditedTimeProperty:
+		if !v.LastEditedTime.IsZero() {
+			return v.LastEditedTime.Format("2006-01-02T15:04:05Z07:00")
+		}
+	case *notionapi.CreatedByProperty:
+		// The API only includes names when the integration may read users
+		if v.CreatedBy.Name != "" {
+			return v.CreatedBy.Name
+		}
+	case *notionapi.LastEditedByProperty:
+		if v.LastEditedBy.Name != "" {
+			return v.LastEditedBy.Name
+		}
+	case *notionapi.UniqueIDProperty:
+		// A string, e.g. "TASK-42", so that it can be used as a slug
+		if v.UniqueID.Prefix != nil && *v.UniqueID.Prefix != "" {
+			return *v.UniqueID.Prefix + "-" + strconv.Itoa(v.UniqueID.Number)
+		}
+		return strconv.Itoa(v.UniqueID.Number)
 	case *notionapi.SelectProperty:
 		return v.Select.Name
 	case *notionapi.MultiSelectProperty:
@@ -203,10 +942,274 @@ func extractPropertyValue(prop notionapi.Property) interface{} {
 		return values
 	case *notionapi.StatusProperty:
 		return v.Status.Name
+	case *notionapi.FormulaProperty:
+		switch v.Formula.Type {
+		case notionapi.FormulaTypeString:
+			if v.Formula.String != "" {
+				return v.Formula.String
+			}
+		case notionapi.FormulaTypeNumber:
+			return v.Formula.Number
+		case notionapi.FormulaTypeBoolean:
+			return v.Formula.Boolean
+		case notionapi.FormulaTypeDate:
+			if v.Formula.Date != nil && v.Formula.Date.Start != nil {
+				return time.Time(*v.Formula.Date.Start).Format("2006-01-02T15:04:05Z07:00")
+			}
+		}
+	case *notionapi.RollupProperty:
+		switch v.Rollup.Type {
+		case notionapi.RollupTypeNumber:
+			return v.Rollup.Number
+		case notionapi.RollupTypeDate:
+			if v.Rollup.Date != nil && v.Rollup.Date.Start != nil {
+				return time.Time(*v.Rollup.Date.Start).Format("2006-01-02T15:04:05Z07:00")
+			}
+		case notionapi.RollupTypeArray:
+			// A rollup of a single value (e.g. a related page's title) is
+			// that value, so it can be used as a slug
+			var values []interface{}
+			for _, item := range v.Rollup.Array {
+				if value := extractPropertyValue(item); value != nil {
+					values = append(values, value)
+				}
+			}
+			if len(values) == 1 {
+				return values[0]
+			}
+			if len(values) > 0 {
+				return values
+			}
+		}
+	default:
+		return fallbackPropertyValue(prop)
 	}
 	return nil
 }
 
+// fallbackPropertyValue returns the value of a property type without a case
+// in extractPropertyValue as it appears in the API's JSON, so that it is not
+// lost: a scalar, or a structure of maps and lists. Empty values are nil.
+func fallbackPropertyValue(prop notionapi.Property) interface{} {
+	if prop == nil {
+		return nil
+	}
+	slog.Debug("Using the JSON value of a property type without dedicated handling", "type", prop.GetType())
+	data, err := json.Marshal(prop)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	switch value := fields[string(prop.GetType())].(type) {
+	case nil:
+		return nil
+	case string:
+		if value == "" {
+			return nil
+		}
+		return value
+	case []interface{}:
+		if len(value) == 0 {
+			return nil
+		}
+		return value
+	case map[string]interface{}:
+		if len(value) == 0 {
+			return nil
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// document holds the state collected while rendering the body of one page.
+type document struct {
+	// headings lists the page's headings in order, for the toc front matter
+	headings []tocEntry
+	// anchors counts the uses of each heading anchor, to keep them unique
+	anchors map[string]int
+	// comments lists the comments on the page's blocks, already formatted
+	comments []string
+	// footnotes lists the definitions of the page's numbered footnotes
+	footnotes []string
+}
+
+// splitLongCaption returns a copy of a media block without its caption when
+// the caption is longer than maxLen characters, along with the caption.
+// Other blocks are returned unchanged with a nil caption.
+func splitLongCaption(block notionapi.Block, maxLen int) (notionapi.Block, []notionapi.RichText) {
+	long := func(caption []notionapi.RichText) bool {
+		return runeLen(plainText(caption)) > maxLen
+	}
+	switch b := block.(type) {
+	case *notionapi.ImageBlock:
+		if long(b.Image.Caption) {
+			c := *b
+			c.Image.Caption = nil
+			return &c, b.Image.Caption
+		}
+	case *notionapi.VideoBlock:
+		if long(b.Video.Caption) {
+			c := *b
+			c.Video.Caption = nil
+			return &c, b.Video.Caption
+		}
+	case *notionapi.FileBlock:
+		if long(b.File.Caption) {
+			c := *b
+			c.File.Caption = nil
+			return &c, b.File.Caption
+		}
+	case *notionapi.PdfBlock:
+		if long(b.Pdf.Caption) {
+			c := *b
+			c.Pdf.Caption = nil
+			return &c, b.Pdf.Caption
+		}
+	case *notionapi.EmbedBlock:
+		if long(b.Embed.Caption) {
+			c := *b
+			c.Embed.Caption = nil
+			return &c, b.Embed.Caption
+		}
+	case *notionapi.BookmarkBlock:
+		if long(b.Bookmark.Caption) {
+			c := *b
+			c.Bookmark.Caption = nil
+			return &c, b.Bookmark.Caption
+		}
+	}
+	return block, nil
+}
+
+// addComments records the comments on a block rendered as markdown and
+// returns the block, with references to the comments in footnote style.
+func (d *document) addComments(markdown string, comments []notionapi.Comment, resolve func(string) string, config *RenderConfig) string {
+	refs := ""
+	for _, c := range comments {
+		text := strings.ReplaceAll(richTextArrToMarkdown(c.RichText, resolve, config), "\n", " ")
+		if c.CreatedBy.Name != "" {
+			text = c.CreatedBy.Name + ": " + text
+		}
+		if config.CommentStyle != CommentStyleFootnotes {
+			d.comments = append(d.comments, text)
+			continue
+		}
+		ref := "[^comment-" + strconv.Itoa(len(d.comments)+1) + "]"
+		d.comments = append(d.comments, ref+": "+text)
+		refs += ref
+	}
+	return appendRefs(markdown, refs)
+}
+
+// addFootnote records a numbered footnote with the given text and returns
+// its reference, e.g. "[^1]".
+func (d *document) addFootnote(text string) string {
+	ref := "[^" + strconv.Itoa(len(d.footnotes)+1) + "]"
+	d.footnotes = append(d.footnotes, ref+": "+strings.ReplaceAll(text, "\n", " "))
+	return ref
+}
+
+// appendRefs adds footnote references to the first line of a rendered block
+func appendRefs(markdown, refs string) string {
+	if refs == "" {
+		return markdown
+	}
+	// References cannot go inside fenced code or math, so they follow it
+	first := strings.TrimSpace(strings.SplitN(markdown, "\n", 2)[0])
+	if first == "" || strings.HasPrefix(first, "```") || strings.HasPrefix(first, "~~~") || strings.HasPrefix(first, "$$") {
+		return strings.TrimLeft(markdown+"\n\n"+refs, "\n")
+	}
+	if i := strings.Index(markdown, "\n"); i >= 0 {
+		return markdown[:i] + refs + markdown[i:]
+	}
+	return markdown + refs
+}
+
+// commentSection returns the footnote definitions or HTML comment holding
+// the recorded comments, placed at the end of the page.
+func (d *document) commentSection(style string) string {
+	if len(d.comments) == 0 {
+		return ""
+	}
+	if style == CommentStyleFootnotes {
+		return strings.Join(d.comments, "\n")
+	}
+	lines := make([]string, len(d.comments))
+	for i, c := range d.comments {
+		// "--" would end the HTML comment early
+		lines[i] = strings.ReplaceAll(c, "--", "- -")
+	}
+	return "<!--\nComments:\n" + strings.Join(lines, "\n") + "\n-->"
+}
+
+// tocEntry is a heading as emitted in the toc front matter field
+type tocEntry struct {
+	Text   string `yaml:"text"`
+	Anchor string `yaml:"anchor"`
+	Level  int    `yaml:"level"`
+}
+
+// addHeading records a heading of the given level found in the body and
+// returns its unique anchor ID.
+func (d *document) addHeading(level int, richText []notionapi.RichText) string {
+	text := plainText(richText)
+	anchor := d.uniqueAnchor(headingAnchor(text))
+	d.headings = append(d.headings, tocEntry{
+		Text:   text,
+		Anchor: anchor,
+		Level:  level,
+	})
+	return anchor
+}
+
+// uniqueAnchor suffixes repeated anchors with "-1", "-2", ... like GitHub's
+// slugger does for duplicate headings.
+func (d *document) uniqueAnchor(base string) string {
+	if d.anchors == nil {
+		d.anchors = map[string]int{}
+	}
+	anchor := base
+	for {
+		if _, taken := d.anchors[anchor]; !taken {
+			break
+		}
+		d.anchors[base]++
+		anchor = base + "-" + strconv.Itoa(d.anchors[base])
+	}
+	d.anchors[anchor] = 0
+	return anchor
+}
+
+// addReadingStats adds the configured word count and reading time fields,
+// computed from the rendered body, to the page's front matter.
+func (r *Renderer) addReadingStats(m *metadata, body string) {
+	if r.config.WordCountField == "" && r.config.ReadingTimeField == "" {
+		return
+	}
+	words := countWords(body)
+	if r.config.WordCountField != "" {
+		m.Properties[r.config.WordCountField] = words
+	}
+	if r.config.ReadingTimeField != "" {
+		wpm := r.config.WordsPerMinute
+		if wpm <= 0 {
+			wpm = DefaultWordsPerMinute
+		}
+		minutes := (words + wpm - 1) / wpm
+		m.Properties[r.config.ReadingTimeField] = minutes
+	}
+}
+
+// GetPageTitle returns the title of a page as used in front matter.
+func (r *Renderer) GetPageTitle(page notionapi.Page) string {
+	return r.parseMetadata(page).Title
+}
+
 // GetPageSlug is a small helper used by callers that need a page's slug
 // without rendering the entire page. It mirrors the logic used by parseMetadata
 // and returns the final slugified value.
@@ -218,7 +1221,34 @@ func (r *Renderer) GetPageSlug(page notionapi.Page) string {
 // GetPagePath returns the Hugo site-relative path for a page (e.g. "/posts/slug/")
 // without rendering the entire page. This is used for building the resolver map.
 func (r *Renderer) GetPagePath(page notionapi.Page) string {
-	m := r.parseMetadata(page)
+	return withBasePrefix(r.pagePath(r.parseMetadata(page)), r.config.LinkBasePrefix)
+}
+
+// pagePath returns the site path of the page described by m, without the
+// base prefix
+func (r *Renderer) pagePath(m metadata) string {
+	p := r.basePagePath(m)
+	if m.lang == "" || m.lang == strings.ToLower(r.config.DefaultLanguage) {
+		return p
+	}
+	return "/" + m.lang + p
+}
+
+// basePagePath returns the site path of the page described by m, ignoring
+// its language
+func (r *Renderer) basePagePath(m metadata) string {
+	if m.path != "" {
+		return "/" + m.path + "/"
+	}
+	switch r.config.PathStyle {
+	case PathStyleJekyll:
+		return jekyllPagePath(m)
+	case PathStyleHexo:
+		return hexoPagePath(m)
+	}
+	if m.parent != nil {
+		return r.basePagePath(*m.parent) + m.Slug + "/"
+	}
 	safeType := slugify(m.pathType)
 
 	// default posts
@@ -231,7 +1261,85 @@ func (r *Renderer) GetPagePath(page notionapi.Page) string {
 	return "/" + safeType + "/" + m.Slug + "/"
 }
 
+// DatabasePath returns the site path listing the pages of a database, the
+// section of its default type (e.g. "/posts/"), or "" when there is none:
+// for the "pages" type and the Jekyll and Hexo layouts.
+func (r *Renderer) DatabasePath(databaseID string) string {
+	if r.config.PathStyle == PathStyleJekyll || r.config.PathStyle == PathStyleHexo {
+		return ""
+	}
+	page := notionapi.Page{Parent: notionapi.Parent{DatabaseID: notionapi.DatabaseID(databaseID)}}
+	safeType := slugify(r.pageDefaultType(page))
+	switch safeType {
+	case "":
+		safeType = "posts"
+	case "pages":
+		return ""
+	}
+	return withBasePrefix("/"+safeType+"/", r.config.LinkBasePrefix)
+}
+
+// SectionIndex returns the "_index.md" file that makes the directory of the
+// page's bundle a Zola section, and its content, with PathStyleZola. Pages in
+// a page tree, with a PathProperty value or at the top level have none.
+func (r *Renderer) SectionIndex(page notionapi.Page) (filename, content string, ok bool) {
+	if r.config.PathStyle != PathStyleZola {
+		return "", "", false
+	}
+	m := r.parseMetadata(page)
+	if m.parent != nil || m.path != "" {
+		return "", "", false
+	}
+	dir := path.Dir(path.Dir(r.buildFilename(m)))
+	if dir == "." {
+		return "", "", false
+	}
+	content = "+++\ntitle = " + tomlString(path.Base(dir)) + "\nsort_by = \"date\"\n+++\n"
+	return dir + "/_index.md", content, true
+}
+
+// withBasePrefix prepends prefix (e.g. "/blog") to the absolute site path p,
+// unless p already starts with it. A page type named like the prefix (a
+// "blog" type under "/blog") is therefore not doubled either.
+func withBasePrefix(p, prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" || !strings.HasPrefix(p, "/") {
+		return p
+	}
+	if p == "/"+prefix || strings.HasPrefix(p, "/"+prefix+"/") {
+		return p
+	}
+	return "/" + prefix + p
+}
+
+// buildFilename returns the output file of the page described by m,
+// relative to the output directory
 func (r *Renderer) buildFilename(m metadata) string {
+	filename := r.baseFilename(m)
+	if m.lang == "" {
+		return filename
+	}
+	if r.config.LanguageStyle == LanguageStyleDirectory {
+		return m.lang + "/" + filename
+	}
+	return strings.TrimSuffix(filename, ".md") + "." + m.lang + ".md"
+}
+
+// baseFilename returns the output file of the page described by m,
+// ignoring its language
+func (r *Renderer) baseFilename(m metadata) string {
+	if m.path != "" {
+		return m.path + "/index.md"
+	}
+	switch r.config.PathStyle {
+	case PathStyleJekyll:
+		return jekyllFilename(m)
+	case PathStyleHexo:
+		return hexoFilename(m)
+	}
+	if m.parent != nil {
+		return path.Join(path.Dir(r.baseFilename(*m.parent)), m.Slug, "index.md")
+	}
 	safeType := slugify(m.pathType)
 	// default posts
 	if safeType == "" {
@@ -243,9 +1351,112 @@ func (r *Renderer) buildFilename(m metadata) string {
 	return filepath.ToSlash(filepath.Join(safeType, m.Slug, "index.md"))
 }
 
+// jekyllFilename lays posts out as Jekyll expects:
+// "<category>/_posts/YYYY-MM-DD-slug.md", with undated posts in "_drafts".
+// Pages go to the site root and other types to a "_<type>" collection.
+func jekyllFilename(m metadata) string {
+	safeType := slugify(m.pathType)
+	switch safeType {
+	case "", "posts":
+		date, category := jekyllPostInfo(m)
+		if date == "" {
+			return filepath.ToSlash(filepath.Join("_drafts", m.Slug+".md"))
+		}
+		return filepath.ToSlash(filepath.Join(category, "_posts", date+"-"+m.Slug+".md"))
+	case "pages":
+		return m.Slug + ".md"
+	}
+	return filepath.ToSlash(filepath.Join("_"+safeType, m.Slug+".md"))
+}
+
+// jekyllPagePath returns the URL Jekyll generates for a page with its default
+// "date" permalink style, e.g. "/category/2025/01/15/slug.html".
+func jekyllPagePath(m metadata) string {
+	safeType := slugify(m.pathType)
+	switch safeType {
+	case "", "posts":
+		date, category := jekyllPostInfo(m)
+		path := "/"
+		if category != "" {
+			path += category + "/"
+		}
+		if date != "" {
+			path += strings.ReplaceAll(date, "-", "/") + "/"
+		}
+		return path + m.Slug + ".html"
+	case "pages":
+		return "/" + m.Slug + ".html"
+	}
+	return "/" + safeType + "/" + m.Slug + ".html"
+}
+
+// hexoFilename lays pages out as Hexo expects below its source directory:
+// posts as "_posts/slug.md" and everything else as "<type>/slug/index.md".
+func hexoFilename(m metadata) string {
+	safeType := slugify(m.pathType)
+	switch safeType {
+	case "", "posts":
+		return filepath.ToSlash(filepath.Join("_posts", m.Slug+".md"))
+	case "pages":
+		return filepath.ToSlash(filepath.Join(m.Slug, "index.md"))
+	}
+	return filepath.ToSlash(filepath.Join(safeType, m.Slug, "index.md"))
+}
+
+// hexoPagePath returns the URL Hexo generates for a page with its default
+// ":year/:month/:day/:title/" permalink.
+func hexoPagePath(m metadata) string {
+	safeType := slugify(m.pathType)
+	switch safeType {
+	case "", "posts":
+		date, _ := jekyllPostInfo(m)
+		if date == "" {
+			return "/" + m.Slug + "/"
+		}
+		return "/" + strings.ReplaceAll(date, "-", "/") + "/" + m.Slug + "/"
+	case "pages":
+		return "/" + m.Slug + "/"
+	}
+	return "/" + safeType + "/" + m.Slug + "/"
+}
+
+// jekyllPostInfo returns a post's date as YYYY-MM-DD and the slug of its
+// first category, either of which may be empty.
+func jekyllPostInfo(m metadata) (date, category string) {
+	if d, ok := m.Properties["date"].(string); ok && len(d) >= 10 {
+		date = d[:10]
+	}
+	for k, v := range m.Properties {
+		lower := strings.ToLower(k)
+		if lower != "categories" && lower != "category" {
+			continue
+		}
+		switch value := v.(type) {
+		case []string:
+			if len(value) > 0 {
+				category = slugify(value[0])
+			}
+		case string:
+			category = slugify(value)
+		}
+	}
+	return date, category
+}
+
 func (r *Renderer) buildFrontMatter(m metadata) (string, error) {
-	// Use the Properties map directly for YAML marshaling
-	out, err := yaml.Marshal(m.Properties)
+	if r.config.FrontMatterTemplate != "" {
+		return r.templateFrontMatter(m)
+	}
+	props := r.renameFrontMatterKeys(m)
+	switch r.config.FrontMatterFormat {
+	case FrontMatterTOML:
+		return "+++\n" + encodeTOML(props, r.frontMatterOrder(props)) + "+++\n\n", nil
+	case FrontMatterZola:
+		zola := zolaFrontMatter(props)
+		return "+++\n" + encodeTOML(zola, sortedKeys(zola)) + "+++\n\n", nil
+	}
+
+	out, err := marshalYAMLOrdered(props, r.frontMatterOrder(props))
 	if err != nil {
 		// Fallback to minimal frontmatter on error
 		return "", err
@@ -253,9 +1464,84 @@ func (r *Renderer) buildFrontMatter(m metadata) (string, error) {
 	return "---\n" + string(out) + "---\n\n", nil
 }
 
+// renameFrontMatterKeys returns the page properties with the keys configured
+// in FrontMatterKeys renamed. A renamed "slug" key always carries the page's
+// slug, since tools like Docusaurus need an explicit document ID; properties
+// landing on the same key are dropped.
+func (r *Renderer) renameFrontMatterKeys(m metadata) map[string]interface{} {
+	if len(r.config.FrontMatterKeys) == 0 {
+		return m.Properties
+	}
+	renamed := make(map[string]interface{}, len(m.Properties)+1)
+	slugKey, renameSlug := r.config.FrontMatterKeys["slug"]
+	for k, v := range m.Properties {
+		key := r.frontMatterKey(k)
+		if renameSlug && strings.EqualFold(key, slugKey) {
+			continue
+		}
+		renamed[key] = v
+	}
+	if renameSlug {
+		renamed[slugKey] = m.Slug
+	}
+	return renamed
+}
+
+// frontMatterKey returns the key property k is written as
+func (r *Renderer) frontMatterKey(k string) string {
+	for from, to := range r.config.FrontMatterKeys {
+		if strings.EqualFold(k, from) {
+			return to
+		}
+	}
+	return k
+}
+
+// frontMatterOrder returns the keys of props in the order they are written:
+// alphabetically, or with SchemaOrder the title first, then the database's
+// properties in column order and the remaining keys alphabetically.
+func (r *Renderer) frontMatterOrder(props map[string]interface{}) []string {
+	keys := sortedKeys(props)
+	if !r.config.SchemaOrder || len(r.propertyOrder) == 0 {
+		return keys
+	}
+	rank := map[string]int{}
+	for i, name := range r.propertyOrder {
+		key := strings.ToLower(r.frontMatterKey(name))
+		if _, ok := rank[key]; !ok {
+			rank[key] = i + 1
+		}
+	}
+	rank[strings.ToLower(r.frontMatterKey("title"))] = 0
+	position := func(k string) int {
+		if i, ok := rank[strings.ToLower(k)]; ok {
+			return i
+		}
+		return len(r.propertyOrder) + 1
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return position(keys[i]) < position(keys[j])
+	})
+	return keys
+}
+
+// marshalYAMLOrdered encodes props as a YAML mapping with keys in order
+func marshalYAMLOrdered(props map[string]interface{}, keys []string) ([]byte, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, k := range keys {
+		var value yaml.Node
+		if err := value.Encode(props[k]); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, &value)
+	}
+	return yaml.Marshal(node)
+}
+
 // renderBlocksRecursive renders top-level blocks and recursively fetches children
-// via getChildren. It returns the combined markdown body.
-func (r *Renderer) renderBlocksRecursive(blocks []notionapi.Block, getChildren func(notionapi.BlockID) ([]notionapi.Block, error), resolve func(string) string, articlePath string) (string, error) {
+// via getChildren. It returns the combined markdown body and records headings
+// in doc.
+func (r *Renderer) renderBlocksRecursive(doc *document, blocks []notionapi.Block, getChildren func(notionapi.BlockID) ([]notionapi.Block, error), resolve func(string) string, articlePath string) (string, error) {
 	// helper to detect ID/HasChildren
 	getBlockIDAndHasChildren := func(block notionapi.Block) (notionapi.BlockID, bool) {
 		switch b := block.(type) {
@@ -308,25 +1594,65 @@ func (r *Renderer) renderBlocksRecursive(blocks []notionapi.Block, getChildren f
 		}
 	}
 
-	var renderBlock func(notionapi.Block) (string, bool, error)
-	renderBlock = func(block notionapi.Block) (string, bool, error) {
+	listIndent := r.config.ListIndent
+	if listIndent <= 0 {
+		listIndent = DefaultListIndent
+	}
+
+	var renderBlock func(notionapi.Block, int) (string, bool, error)
+	// renderRun renders a run from blockRuns: a single block or a gallery.
+	// number is the position of a numbered list item in its list.
+	renderRun := func(run []notionapi.Block, number int) (string, bool, error) {
+		if len(run) == 1 {
+			return renderBlock(run[0], number)
+		}
+		images := make([]string, 0, len(run))
+		for _, block := range run {
+			s, _, err := renderBlock(block, 0)
+			if err != nil {
+				return "", false, err
+			}
+			images = append(images, s)
+		}
+		return renderTemplate(r.config.GalleryTemplate, map[string]string{"Content": strings.Join(images, "\n")}), false, nil
+	}
+	renderBlock = func(block notionapi.Block, number int) (string, bool, error) {
+		// record headings before their (toggleable) children
+		anchor := ""
+		switch b := block.(type) {
+		case *notionapi.Heading1Block:
+			anchor = doc.addHeading(1, b.Heading1.RichText)
+		case *notionapi.Heading2Block:
+			anchor = doc.addHeading(2, b.Heading2.RichText)
+		case *notionapi.Heading3Block:
+			anchor = doc.addHeading(3, b.Heading3.RichText)
+		}
+
 		childContent := ""
+		// widths are the width ratios of a column list's columns
+		var widths []float64
 		if id, has := getBlockIDAndHasChildren(block); has && getChildren != nil {
 			children, err := getChildren(id)
 			if err != nil {
 				return "", false, err
 			}
+			var content strings.Builder
 			prevChildIsList := false
 			_, isColumnList := block.(*notionapi.ColumnListBlock)
-			for _, cb := range children {
-				cstr, childIsList, err := renderBlock(cb)
+			childNumber := 0
+			for _, run := range r.blockRuns(children) {
+				childNumber = listNumber(run, childNumber)
+				cstr, childIsList, err := renderRun(run, childNumber)
 				if err != nil {
 					return "", false, err
 				}
 				indent := ""
 				switch block.(type) {
-				case *notionapi.BulletedListItemBlock, *notionapi.NumberedListItemBlock, *notionapi.ToDoBlock:
-					indent = strings.Repeat(" ", 4)
+				case *notionapi.BulletedListItemBlock, *notionapi.ToDoBlock:
+					indent = strings.Repeat(" ", listIndent)
+				case *notionapi.NumberedListItemBlock:
+					// CommonMark nests only under the "1. " marker's full width
+					indent = strings.Repeat(" ", max(listIndent, len(numberedMarker(number, r.config))+1))
 				}
 				lines := strings.Split(strings.TrimRight(cstr, "\n"), "\n")
 				for i, l := range lines {
@@ -340,55 +1666,311 @@ func (r *Renderer) renderBlocksRecursive(blocks []notionapi.Block, getChildren f
 				if prevChildIsList && childIsList {
 					sep = "\n"
 				}
-				if childContent == "" {
-					childContent = rendered
-				} else {
-					childContent += sep + rendered
+				if content.Len() > 0 {
+					content.WriteString(sep)
 				}
+				content.WriteString(rendered)
 				prevChildIsList = childIsList
 				if isColumnList {
-					childContent += "\n__COLUMN_BREAK__\n"
+					content.WriteString("\n__COLUMN_BREAK__\n")
+				}
+			}
+			childContent = strings.TrimRight(content.String(), "\n")
+			if isColumnList && r.columnWidths != nil && r.config.ColumnTemplate != "" {
+				ratios, err := r.columnWidths(id)
+				if err != nil {
+					return "", false, fmt.Errorf("failed to fetch column widths: %w", err)
+				}
+				widths = make([]float64, len(children))
+				for i, child := range children {
+					widths[i] = ratios[child.GetID()]
 				}
 			}
-			childContent = strings.TrimRight(childContent, "\n")
+		}
+		var note []notionapi.RichText
+		if r.config.CaptionFootnoteLength > 0 {
+			block, note = splitLongCaption(block, r.config.CaptionFootnoteLength)
 		}
 		s, isList := blockToMarkdownWithCache(block, childContent, resolve, r.fileCache, articlePath, r.config)
+		if list, ok := block.(*notionapi.ColumnListBlock); ok && widths != nil {
+			s = columnListToMarkdown(list, childContent, widths, r.config)
+		}
+		if _, ok := block.(*notionapi.NumberedListItemBlock); ok && number > 1 {
+			s = numberedMarker(number, r.config) + strings.TrimPrefix(s, numberedMarker(1, r.config))
+		}
+		if anchor != "" && r.config.HeadingAnchorTemplate != "" {
+			s = renderTemplate(r.config.HeadingAnchorTemplate, map[string]string{"Heading": s, "ID": anchor})
+		}
+		if note != nil {
+			s = appendRefs(strings.TrimRight(s, "\n"), doc.addFootnote(richTextArrToMarkdown(note, resolve, r.config)))
+		}
+		if r.comments != nil && r.config.CommentStyle != "" && block.GetID() != "" {
+			comments, err := r.comments(block.GetID())
+			if err != nil {
+				return "", false, fmt.Errorf("failed to fetch comments: %w", err)
+			}
+			s = doc.addComments(strings.TrimRight(s, "\n"), comments, resolve, r.config)
+		}
 		return strings.TrimRight(s, "\n"), isList, nil
 	}
 
-	markdown := ""
+	var markdown strings.Builder
 	prevIsList := false
-	for _, block := range blocks {
-		s, isList, err := renderBlock(block)
+	number := 0
+	for _, run := range r.blockRuns(blocks) {
+		number = listNumber(run, number)
+		s, isList, err := renderRun(run, number)
 		if err != nil {
 			return "", err
 		}
 
 		// Add separator before current block (except for first block)
-		if markdown != "" {
+		if markdown.Len() > 0 {
 			if prevIsList && isList {
-				markdown += "\n"
+				markdown.WriteString("\n")
 			} else {
-				markdown += "\n\n"
+				markdown.WriteString("\n\n")
 			}
 		}
 
 		// Add the block content
-		markdown += s
+		markdown.WriteString(s)
 		prevIsList = isList
 	}
-	return markdown, nil
+	return markdown.String(), nil
+}
+
+var (
+	fencedCodeRe = regexp.MustCompile("(?s)```.*?```")
+	inlineCodeRe = regexp.MustCompile("`[^`\n]*`")
+	shortcodeRe  = regexp.MustCompile(`(?s)\{\{[<%].*?[%>]\}\}|\{%.*?%\}`)
+	htmlTagRe    = regexp.MustCompile(`<[^>]+>`)
+	linkTargetRe = regexp.MustCompile(`\]\([^)]*\)`)
+)
+
+// countWords counts the words of a Markdown body, ignoring code, markup,
+// shortcodes and link targets. CJK characters count as one word each.
+func countWords(markdown string) int {
+	text := fencedCodeRe.ReplaceAllString(markdown, " ")
+	text = inlineCodeRe.ReplaceAllString(text, " ")
+	text = shortcodeRe.ReplaceAllString(text, " ")
+	text = htmlTagRe.ReplaceAllString(text, " ")
+	text = linkTargetRe.ReplaceAllString(text, "] ")
+
+	count := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			count++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || (inWord && (r == '\'' || r == '’')):
+			if !inWord {
+				count++
+				inWord = true
+			}
+		default:
+			inWord = false
+		}
+	}
+	return count
+}
+
+// relativeResolver wraps resolve so that the absolute site paths it returns
+// are made relative to the page at pagePath. Anchors and URLs are kept.
+func relativeResolver(resolve func(string) string, pagePath string) func(string) string {
+	return func(id string) string {
+		target := resolve(id)
+		if !strings.HasPrefix(target, "/") {
+			return target
+		}
+		return relativeLink(pagePath, target)
+	}
 }
 
-// helper: simple slugifier for file names
+// relativeLink returns the link from the page at site path from to the site
+// path to, e.g. "/posts/a/" to "/posts/b/" gives "../b/". Paths ending in "/"
+// are directories (page bundles); others, like "/2025/a.html", are files.
+func relativeLink(from, to string) string {
+	fromDir := strings.Split(strings.Trim(from[:strings.LastIndex(from, "/")+1], "/"), "/")
+	toParts := strings.Split(strings.TrimPrefix(to, "/"), "/")
+	if fromDir[0] == "" {
+		fromDir = nil
+	}
+
+	common := 0
+	for common < len(fromDir) && common < len(toParts)-1 && fromDir[common] == toParts[common] {
+		common++
+	}
+	link := strings.Repeat("../", len(fromDir)-common) + strings.Join(toParts[common:], "/")
+	if link == "" {
+		return "./"
+	}
+	return link
+}
+
+// blockRuns splits blocks into runs rendered as one unit: consecutive images
+// form a gallery when GalleryTemplate is set, other blocks are on their own.
+func (r *Renderer) blockRuns(blocks []notionapi.Block) [][]notionapi.Block {
+	runs := make([][]notionapi.Block, 0, len(blocks))
+	prevIsImage := false
+	for _, block := range blocks {
+		_, isImage := block.(*notionapi.ImageBlock)
+		if isImage && prevIsImage && r.config.GalleryTemplate != "" {
+			runs[len(runs)-1] = append(runs[len(runs)-1], block)
+			continue
+		}
+		runs = append(runs, []notionapi.Block{block})
+		prevIsImage = isImage
+	}
+	return runs
+}
+
+// listNumber returns the position of a run's numbered list item in its
+// list, given the previous run's, or 0 when the run is not one.
+func listNumber(run []notionapi.Block, prev int) int {
+	if len(run) != 1 {
+		return 0
+	}
+	if _, ok := run[0].(*notionapi.NumberedListItemBlock); !ok {
+		return 0
+	}
+	return prev + 1
+}
+
+// collapseBlankLines reduces runs of blank lines, which empty blocks can
+// leave behind, to a single blank line. Fenced code blocks are kept verbatim.
+func collapseBlankLines(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	out := lines[:0]
+	fence := ""
+	blank := false
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if fence == "" {
+			if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+				fence = trimmed[:3]
+			}
+		} else if strings.HasPrefix(trimmed, fence) {
+			fence = ""
+			blank = false
+			out = append(out, line)
+			continue
+		}
+		if fence == "" && strings.TrimSpace(line) == "" {
+			if blank {
+				continue
+			}
+			blank = true
+			line = ""
+		} else {
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// plainText concatenates the plain text of rich text segments
+func plainText(arr []notionapi.RichText) string {
+	var b strings.Builder
+	for _, t := range arr {
+		b.WriteString(t.PlainText)
+	}
+	return b.String()
+}
+
+// headingAnchor derives a heading's anchor ID the way GitHub does: lowercase,
+// punctuation removed and spaces replaced by dashes. Duplicates are handled by
+// document.uniqueAnchor.
+func headingAnchor(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+// helper: simple slugifier for file names. Runs of dashes are collapsed and
+// leading or trailing dashes trimmed.
 func slugify(s string) string {
-	s = strings.ToLower(s)
+	return slugifyCase(strings.ToLower(s))
+}
+
+// slugifyCase is slugify without lowercasing, for case-sensitive hosts
+func slugifyCase(s string) string {
 	s = strings.ReplaceAll(s, " ", "-")
 	safe := make([]rune, 0, len(s))
 	for _, r := range s {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+		if r == '-' && (len(safe) == 0 || safe[len(safe)-1] == '-') {
+			// collapse runs of dashes and drop leading ones
+			continue
+		}
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
 			safe = append(safe, r)
 		}
 	}
-	return string(safe)
+	return strings.TrimRight(string(safe), "-")
+}
+
+// windowsReservedNames are device names Windows does not allow as file
+// names, with or without an extension
+var windowsReservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true,
+	"com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true,
+	"lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// windowsSafeName makes name usable as a file or directory name on Windows:
+// illegal characters become dashes, trailing dots and spaces are dropped and
+// reserved device names get a "_" suffix.
+func windowsSafeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '-'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, ". ")
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReservedNames[strings.ToLower(base)] {
+		return base + "_" + strings.TrimPrefix(name, base)
+	}
+	return name
+}
+
+// slugHashLength is the length of the hash truncateSlug appends
+const slugHashLength = 6
+
+// truncateSlug shortens slug to at most max characters, cutting at the last
+// dash that fits so no word is split, and appends a short hash of the whole
+// slug so that long titles sharing a prefix keep distinct slugs. A first
+// word longer than the room left is cut. max <= 0 means no limit.
+func truncateSlug(slug string, max int) string {
+	runes := []rune(slug)
+	if max <= 0 || len(runes) <= max {
+		return slug
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(slug)))[:slugHashLength]
+	room := max - len(hash) - 1
+	if room <= 0 {
+		return hash[:min(max, len(hash))]
+	}
+	cut := string(runes[:room])
+	if runes[room] != '-' {
+		if i := strings.LastIndex(cut, "-"); i > 0 {
+			cut = cut[:i]
+		}
+	}
+	if cut = strings.TrimRight(cut, "-_"); cut == "" {
+		return hash
+	}
+	return cut + "-" + hash
 }
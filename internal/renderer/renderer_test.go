@@ -0,0 +1,835 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// newTestPage builds a minimal Notion page with the given title
+func newTestPage(title string) notionapi.Page {
+	now := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	return notionapi.Page{
+		ID:             "11111111-2222-3333-4444-555555555555",
+		CreatedTime:    now,
+		LastEditedTime: now,
+		Properties: notionapi.Properties{
+			"Title": &notionapi.TitleProperty{
+				Title: []notionapi.RichText{{PlainText: title}},
+			},
+		},
+	}
+}
+
+func paragraph(text string) *notionapi.ParagraphBlock {
+	return &notionapi.ParagraphBlock{
+		Paragraph: notionapi.Paragraph{
+			RichText: []notionapi.RichText{{PlainText: text}},
+		},
+	}
+}
+
+func TestRenderPage_Transformer(t *testing.T) {
+	r := New(nil, t.TempDir(), nil)
+	page := newTestPage("Transform Test")
+	blocks := []notionapi.Block{paragraph("Hello world")}
+
+	var seenTitle string
+	footer := func(p notionapi.Page, markdown string) (string, error) {
+		seenTitle = extractPropertyValue(p.Properties["Title"]).(string)
+		return markdown + "\n\n---\nThanks for reading!", nil
+	}
+
+	_, content, err := r.RenderPage(page, blocks, nil, nil, footer)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+
+	if seenTitle != "Transform Test" {
+		t.Errorf("Expected transformer to receive the page, got title '%s'", seenTitle)
+	}
+	if !strings.HasSuffix(content, "Hello world\n\n---\nThanks for reading!\n") {
+		t.Errorf("Expected footer to be appended to the body, got:\n%s", content)
+	}
+	if !strings.HasPrefix(content, "---\n") {
+		t.Errorf("Expected front matter to be kept in front of the body, got:\n%s", content)
+	}
+}
+
+func TestCountWords(t *testing.T) {
+	markdown := "# Getting Started\n\n" +
+		"This guide shows **how** to [install the tool](https://example.com/install) quickly.\n\n" +
+		"```go\nfunc main() { fmt.Println(\"ignored code words\") }\n```\n\n" +
+		"Run `go build ./...` and you're done.\n\n" +
+		"{{< video src=\"./demo.mp4\" >}}\n\n" +
+		"你好世界"
+
+	// 2 (heading) + 10 (sentence) + 3 (run ... done) + 4 CJK characters
+	if got := countWords(markdown); got != 19 {
+		t.Errorf("Expected 19 words, got %d", got)
+	}
+}
+
+func TestRenderPage_ReadingStats(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.WordCountField = "wordcount"
+	config.ReadingTimeField = "readingtime"
+	config.WordsPerMinute = 100
+
+	r := New(nil, t.TempDir(), config)
+	blocks := []notionapi.Block{paragraph(strings.Repeat("word ", 250))}
+
+	_, content, err := r.RenderPage(newTestPage("Stats"), blocks, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+	if !strings.Contains(content, "wordcount: 250\n") {
+		t.Errorf("Expected word count in front matter, got:\n%s", content)
+	}
+	if !strings.Contains(content, "readingtime: 3\n") {
+		t.Errorf("Expected reading time of 3 minutes in front matter, got:\n%s", content)
+	}
+
+	// Disabled by default
+	_, content, err = New(nil, t.TempDir(), nil).RenderPage(newTestPage("Stats"), blocks, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+	if strings.Contains(content, "wordcount") || strings.Contains(content, "readingtime") {
+		t.Errorf("Expected no reading stats by default, got:\n%s", content)
+	}
+}
+
+func TestRenderPage_TOCField(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.TOCField = "toc"
+
+	heading := func(text string) []notionapi.RichText {
+		return []notionapi.RichText{{PlainText: text, Text: &notionapi.Text{Content: text}}}
+	}
+	blocks := []notionapi.Block{
+		&notionapi.Heading1Block{Heading1: notionapi.Heading{RichText: heading("Getting Started")}},
+		paragraph("Intro"),
+		&notionapi.Heading3Block{Heading3: notionapi.Heading{RichText: heading("Install, then run!")}},
+		&notionapi.Heading2Block{Heading2: notionapi.Heading{RichText: heading("FAQ")}},
+	}
+
+	_, content, err := New(nil, t.TempDir(), config).RenderPage(newTestPage("TOC"), blocks, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+	expected := `toc:
+    - text: Getting Started
+      anchor: getting-started
+      level: 1
+    - text: Install, then run!
+      anchor: install-then-run
+      level: 3
+    - text: FAQ
+      anchor: faq
+      level: 2
+`
+	if !strings.Contains(content, expected) {
+		t.Errorf("Expected toc front matter:\n%s\ngot:\n%s", expected, content)
+	}
+}
+
+func TestRenderBody_NestedToggles(t *testing.T) {
+	text := func(s string) []notionapi.RichText {
+		return []notionapi.RichText{{PlainText: s, Text: &notionapi.Text{Content: s}}}
+	}
+	outer := &notionapi.ToggleBlock{
+		BasicBlock: notionapi.BasicBlock{ID: "outer", HasChildren: true},
+		Toggle:     notionapi.Toggle{RichText: text("Outer")},
+	}
+	inner := &notionapi.ToggleBlock{
+		BasicBlock: notionapi.BasicBlock{ID: "inner", HasChildren: true},
+		Toggle:     notionapi.Toggle{RichText: text("Inner")},
+	}
+	item := &notionapi.BulletedListItemBlock{
+		BasicBlock:       notionapi.BasicBlock{ID: "item", HasChildren: true},
+		BulletedListItem: notionapi.ListItem{RichText: text("item")},
+	}
+	subItem := &notionapi.BulletedListItemBlock{
+		BulletedListItem: notionapi.ListItem{RichText: text("sub item")},
+	}
+	children := map[notionapi.BlockID][]notionapi.Block{
+		"outer": {paragraph("Before"), inner},
+		"inner": {item},
+		"item":  {subItem},
+	}
+	getChildren := func(id notionapi.BlockID) ([]notionapi.Block, error) {
+		return children[id], nil
+	}
+
+	config := DefaultRenderConfig()
+	config.DetailsTemplate = "<details>\n<summary>{{.Summary}}</summary>\n\n{{.Content}}\n</details>"
+	body, err := New(nil, t.TempDir(), config).RenderBody(newTestPage("Toggles"), []notionapi.Block{outer}, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+
+	expected := `<details>
+<summary>Outer</summary>
+
+Before
+
+<details>
+<summary>Inner</summary>
+
+- item
+    - sub item
+</details>
+</details>`
+	if body != expected {
+		t.Errorf("Expected nested details:\n%s\ngot:\n%s", expected, body)
+	}
+}
+
+func TestRenderBody_MultiParagraphQuote(t *testing.T) {
+	quote := &notionapi.QuoteBlock{
+		BasicBlock: notionapi.BasicBlock{ID: "quote", HasChildren: true},
+		Quote: notionapi.Quote{
+			RichText: []notionapi.RichText{{PlainText: "First paragraph", Text: &notionapi.Text{Content: "First paragraph"}}},
+		},
+	}
+	getChildren := func(id notionapi.BlockID) ([]notionapi.Block, error) {
+		if id == "quote" {
+			return []notionapi.Block{paragraph("Second paragraph")}, nil
+		}
+		return nil, nil
+	}
+
+	body, err := New(nil, t.TempDir(), nil).RenderBody(newTestPage("Quote"), []notionapi.Block{quote}, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected := "> First paragraph\n>\n> Second paragraph"
+	if body != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, body)
+	}
+}
+
+func TestRenderBody_ParagraphChildren(t *testing.T) {
+	parent := paragraph("Shopping list:")
+	parent.BasicBlock = notionapi.BasicBlock{ID: "parent", HasChildren: true}
+	item := func(text string) *notionapi.BulletedListItemBlock {
+		return &notionapi.BulletedListItemBlock{BulletedListItem: notionapi.ListItem{RichText: []notionapi.RichText{{PlainText: text}}}}
+	}
+	getChildren := func(id notionapi.BlockID) ([]notionapi.Block, error) {
+		if id == "parent" {
+			return []notionapi.Block{item("Milk"), item("Eggs")}, nil
+		}
+		return nil, nil
+	}
+
+	blocks := []notionapi.Block{parent, paragraph("After")}
+	body, err := New(nil, t.TempDir(), nil).RenderBody(newTestPage("Paragraph"), blocks, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	if expected := "Shopping list:\n\n- Milk\n- Eggs\n\nAfter"; body != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, body)
+	}
+}
+
+func TestRenderBody_NestedToDoIndent(t *testing.T) {
+	todo := func(id, text string, checked, hasChildren bool) *notionapi.ToDoBlock {
+		return &notionapi.ToDoBlock{
+			BasicBlock: notionapi.BasicBlock{ID: notionapi.BlockID(id), HasChildren: hasChildren},
+			ToDo: notionapi.ToDo{
+				RichText: []notionapi.RichText{{PlainText: text, Text: &notionapi.Text{Content: text}}},
+				Checked:  checked,
+			},
+		}
+	}
+	blocks := []notionapi.Block{todo("parent", "Release", false, true)}
+	getChildren := func(id notionapi.BlockID) ([]notionapi.Block, error) {
+		if id == "parent" {
+			return []notionapi.Block{todo("child", "Write changelog", true, false)}, nil
+		}
+		return nil, nil
+	}
+
+	body, err := New(nil, t.TempDir(), nil).RenderBody(newTestPage("Tasks"), blocks, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	if expected := "- [ ] Release\n    - [x] Write changelog"; body != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, body)
+	}
+
+	config := DefaultRenderConfig()
+	config.ListIndent = 2
+	body, err = New(nil, t.TempDir(), config).RenderBody(newTestPage("Tasks"), blocks, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	if expected := "- [ ] Release\n  - [x] Write changelog"; body != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, body)
+	}
+}
+
+func TestRenderBody_BulletMarker(t *testing.T) {
+	bullet := func(id, text string, hasChildren bool) *notionapi.BulletedListItemBlock {
+		return &notionapi.BulletedListItemBlock{
+			BasicBlock: notionapi.BasicBlock{ID: notionapi.BlockID(id), HasChildren: hasChildren},
+			BulletedListItem: notionapi.ListItem{
+				RichText: []notionapi.RichText{{PlainText: text, Text: &notionapi.Text{Content: text}}},
+			},
+		}
+	}
+	blocks := []notionapi.Block{bullet("fruit", "Fruit", true), bullet("veg", "Vegetables", false)}
+	getChildren := func(id notionapi.BlockID) ([]notionapi.Block, error) {
+		switch id {
+		case "fruit":
+			return []notionapi.Block{bullet("apple", "Apple", true)}, nil
+		case "apple":
+			return []notionapi.Block{bullet("gala", "Gala", false)}, nil
+		}
+		return nil, nil
+	}
+
+	config := DefaultRenderConfig()
+	config.BulletMarker = "*"
+	body, err := New(nil, t.TempDir(), config).RenderBody(newTestPage("Shopping"), blocks, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	if expected := "* Fruit\n    * Apple\n        * Gala\n* Vegetables"; body != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, body)
+	}
+}
+
+func TestRenderBody_NumberedListStyle(t *testing.T) {
+	item := func(id, text string, hasChildren bool) *notionapi.NumberedListItemBlock {
+		return &notionapi.NumberedListItemBlock{
+			BasicBlock: notionapi.BasicBlock{ID: notionapi.BlockID(id), HasChildren: hasChildren},
+			NumberedListItem: notionapi.ListItem{
+				RichText: []notionapi.RichText{{PlainText: text, Text: &notionapi.Text{Content: text}}},
+			},
+		}
+	}
+	blocks := []notionapi.Block{
+		item("install", "Install", false),
+		item("configure", "Configure", true),
+		paragraph("Then:"),
+		item("run", "Run", false),
+	}
+	getChildren := func(id notionapi.BlockID) ([]notionapi.Block, error) {
+		if id == "configure" {
+			return []notionapi.Block{item("token", "Set the token", false), item("database", "Set the database", false)}, nil
+		}
+		return nil, nil
+	}
+
+	config := DefaultRenderConfig()
+	config.NumberedListDelimiter = ")"
+	body, err := New(nil, t.TempDir(), config).RenderBody(newTestPage("Setup"), blocks, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected := "1) Install\n1) Configure\n    1) Set the token\n    1) Set the database\n\nThen:\n\n1) Run"
+	if body != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, body)
+	}
+
+	config.NumberedListIncrement = true
+	body, err = New(nil, t.TempDir(), config).RenderBody(newTestPage("Setup"), blocks, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected = "1) Install\n2) Configure\n    1) Set the token\n    2) Set the database\n\nThen:\n\n1) Run"
+	if body != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, body)
+	}
+}
+
+func TestRenderBody_HeadingAnchors(t *testing.T) {
+	heading := func(text string) *notionapi.Heading2Block {
+		return &notionapi.Heading2Block{Heading2: notionapi.Heading{
+			RichText: []notionapi.RichText{{PlainText: text, Text: &notionapi.Text{Content: text}}},
+		}}
+	}
+	blocks := []notionapi.Block{
+		heading("What's New?"),
+		heading("Usage"),
+		heading("Usage"),
+		heading("Usage-1"),
+		heading("Usage"),
+		heading("Ünïcode_Names"),
+	}
+
+	config := DefaultRenderConfig()
+	config.HeadingAnchorTemplate = "{{.Heading}} {#{{.ID}}}"
+	body, err := New(nil, t.TempDir(), config).RenderBody(newTestPage("Anchors"), blocks, nil, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+
+	// Matches GitHub's slugger: the "Usage-1" heading collides with the
+	// generated "usage-1" and gets its own suffix
+	expected := strings.Join([]string{
+		"## What's New? {#whats-new}",
+		"## Usage {#usage}",
+		"## Usage {#usage-1}",
+		"## Usage-1 {#usage-1-1}",
+		"## Usage {#usage-2}",
+		"## Ünïcode_Names {#ünïcode_names}",
+	}, "\n\n")
+	if body != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, body)
+	}
+}
+
+func TestRenderBody_CollapsesBlankLines(t *testing.T) {
+	code := &notionapi.CodeBlock{
+		Code: notionapi.Code{
+			Language: "text",
+			RichText: []notionapi.RichText{{PlainText: "first\n\n\n\nlast"}},
+		},
+	}
+	blocks := []notionapi.Block{paragraph("Before"), paragraph(""), paragraph(""), paragraph("After"), code}
+
+	body, err := New(nil, t.TempDir(), nil).RenderBody(newTestPage("Blank Lines"), blocks, nil, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	if !strings.HasPrefix(body, "Before\n\nAfter\n\n") {
+		t.Errorf("Expected blank lines between paragraphs to collapse, got '%s'", body)
+	}
+	if !strings.Contains(body, "first\n\n\n\nlast") {
+		t.Errorf("Expected code block content to be kept verbatim, got '%s'", body)
+	}
+}
+
+func TestRenderPage_TrailingNewline(t *testing.T) {
+	r := New(nil, t.TempDir(), nil)
+	cases := map[string][]notionapi.Block{
+		"paragraph": {paragraph("Last line")},
+		"code":      {&notionapi.CodeBlock{Code: notionapi.Code{Language: "go", RichText: []notionapi.RichText{{PlainText: "x := 1\n\n"}}}}},
+		"empty":     nil,
+	}
+	for name, blocks := range cases {
+		_, content, err := r.RenderPage(newTestPage("Newline"), blocks, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error rendering page: %v", name, err)
+		}
+		if !strings.HasSuffix(content, "\n") || strings.HasSuffix(content, "\n\n") {
+			t.Errorf("%s: expected exactly one trailing newline, got %q", name, content)
+		}
+	}
+}
+
+func TestRenderBody_Gallery(t *testing.T) {
+	image := func(name string) *notionapi.ImageBlock {
+		return &notionapi.ImageBlock{Image: notionapi.Image{External: &notionapi.FileObject{URL: "https://example.com/" + name}}}
+	}
+	blocks := []notionapi.Block{
+		image("a.png"), image("b.png"), image("c.png"),
+		paragraph("Between"),
+		image("single.png"),
+	}
+
+	config := DefaultRenderConfig()
+	config.GalleryTemplate = "{{< gallery >}}\n{{.Content}}\n{{< /gallery >}}"
+	config.ImageTemplate = "![]({{.URL}})"
+	body, err := New(nil, t.TempDir(), config).RenderBody(newTestPage("Gallery"), blocks, nil, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected := "{{< gallery >}}\n" +
+		"![](https://example.com/a.png)\n" +
+		"![](https://example.com/b.png)\n" +
+		"![](https://example.com/c.png)\n" +
+		"{{< /gallery >}}\n\n" +
+		"Between\n\n" +
+		"![](https://example.com/single.png)"
+	if body != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, body)
+	}
+}
+
+func TestRenderPage_BodyPrefixSuffix(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.BodyPrefix = "> Draft notes on {{.Title}}"
+	config.BodySuffix = "[Source]({{.URL}}) · {{.Path}}"
+	page := newTestPage("Wrapped")
+	page.URL = "https://www.notion.so/Wrapped-11111111222233334444555555555555"
+
+	_, content, err := New(nil, t.TempDir(), config).RenderPage(page, []notionapi.Block{paragraph("Body text")}, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+	expected := "---\n\n> Draft notes on Wrapped\n\nBody text\n\n[Source](" + page.URL + ") · /posts/wrapped/\n"
+	if !strings.HasSuffix(content, expected) {
+		t.Errorf("Expected body to be wrapped, got:\n%s", content)
+	}
+}
+
+func TestRenderPage_FrontMatterTemplate(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.FrontMatterKeys = map[string]string{"tags": "keywords"}
+	config.FrontMatterTemplate = `---
+title: {{json .Title}}
+permalink: {{.Path}}
+{{with .Properties.keywords}}keywords: [{{join . ", "}}]
+{{end}}{{with .Properties.summary}}description: {{json .}}
+{{end}}---`
+	page := newTestPage("Custom Front Matter")
+	page.Properties["Tags"] = &notionapi.MultiSelectProperty{MultiSelect: []notionapi.Option{{Name: "go"}, {Name: "yaml"}}}
+
+	_, content, err := New(nil, t.TempDir(), config).RenderPage(page, []notionapi.Block{paragraph("Body text")}, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+	expected := "---\ntitle: \"Custom Front Matter\"\npermalink: /posts/custom-front-matter/\nkeywords: [go, yaml]\n---\n\nBody text\n"
+	if content != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, content)
+	}
+
+	// .Path matches the links written to the page
+	config.LinkBasePrefix = "/blog/"
+	config.FrontMatterTemplate = "---\npermalink: {{.Path}}\n---"
+	r := New(nil, t.TempDir(), config)
+	_, content, err = r.RenderPage(page, []notionapi.Block{paragraph("Body text")}, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+	expected = "---\npermalink: /blog/posts/custom-front-matter/\n---\n\n"
+	if !strings.HasPrefix(content, expected) || r.GetPagePath(page) != "/blog/posts/custom-front-matter/" {
+		t.Errorf("Expected front matter:\n%s\ngot:\n%s", expected, content)
+	}
+
+	config.FrontMatterTemplate = "{{.Unknown"
+	if _, _, err := New(nil, t.TempDir(), config).RenderPage(page, nil, nil, nil); err == nil {
+		t.Error("Expected an error for an invalid template")
+	}
+}
+
+func TestRenderPage_EditLink(t *testing.T) {
+	config := DefaultRenderConfig()
+	config.EditLinkTemplate = "[Edit this page in Notion]({{.URL}})"
+
+	_, content, err := New(nil, t.TempDir(), config).RenderPage(newTestPage("Editable"), []notionapi.Block{paragraph("Body text")}, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering page: %v", err)
+	}
+	expected := "Body text\n\n[Edit this page in Notion](https://www.notion.so/11111111222233334444555555555555)\n"
+	if !strings.HasSuffix(content, expected) {
+		t.Errorf("Expected a link to the Notion page, got:\n%s", content)
+	}
+}
+
+func TestRenderBody_SanitizeHTML(t *testing.T) {
+	underlined := &notionapi.ParagraphBlock{Paragraph: notionapi.Paragraph{RichText: []notionapi.RichText{
+		{PlainText: "Keep ", Text: &notionapi.Text{Content: "Keep "}},
+		{PlainText: "this", Text: &notionapi.Text{Content: "this"}, Annotations: &notionapi.Annotations{Underline: true}},
+	}}}
+	raw := &notionapi.CodeBlock{Code: notionapi.Code{Language: "html", RichText: []notionapi.RichText{{
+		PlainText: "<script>alert('x')</script>\n<a href=\"javascript:alert(1)\" onclick=\"x()\" class=\"btn\">Go</a>",
+	}}}}
+	shown := &notionapi.CodeBlock{Code: notionapi.Code{Language: "js", RichText: []notionapi.RichText{{PlainText: "<script>ok()</script>"}}}}
+	blocks := []notionapi.Block{underlined, raw, shown}
+
+	config := DefaultRenderConfig()
+	config.RawHTMLLanguage = "html"
+	config.SanitizeHTML = true
+	body, err := New(nil, t.TempDir(), config).RenderBody(newTestPage("Sanitize"), blocks, nil, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected := "Keep <u>this</u>\n\n" +
+		"\n<a class=\"btn\">Go</a>\n\n" +
+		"```js\n<script>ok()</script>\n```"
+	if body != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, body)
+	}
+
+	// Off by default
+	config.SanitizeHTML = false
+	body, err = New(nil, t.TempDir(), config).RenderBody(newTestPage("Sanitize"), blocks, nil, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	if !strings.Contains(body, "<script>alert('x')</script>") {
+		t.Errorf("Expected HTML to be kept by default, got '%s'", body)
+	}
+}
+
+func TestSanitizeHTML_Bypasses(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		// Tag names separated from attributes by "/" instead of whitespace
+		{`<img/src=x/onerror=alert(1)>`, `<img src="x/onerror=alert(1)">`},
+		{`<svg/onload=alert(1)>`, ``},
+		{`<a/href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		// Entity-encoded and whitespace-split schemes
+		{`<a href="javascript&colon;alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="&#106;avascript:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="java&#x09;script:alert(1)">x</a>`, `<a>x</a>`},
+		// Markdown link, image and reference targets
+		{`[x](javascript:alert(1))`, `[x]()`},
+		{`![x](JaVaScRiPt&colon;alert(1) "t")`, `![x]( "t")`},
+		{`[x](<javascript:alert(1)>)`, `[x]()`},
+		{"[x]: javascript:alert(1)", "[x]: <>"},
+		{`<javascript:alert(1)>`, ``},
+		// Safe Markdown is left alone
+		{`[x](https://example.com/a_(b)) <https://example.com> <me@example.com>`, `[x](https://example.com/a_(b)) <https://example.com> <me@example.com>`},
+		{"a < b && c > d <!-- more -->", "a < b && c > d <!-- more -->"},
+	}
+	for _, tc := range testCases {
+		if got := sanitizeHTML(tc.input, nil); got != tc.expected {
+			t.Errorf("For %s expected %q, got %q", tc.input, tc.expected, got)
+		}
+	}
+}
+
+func TestRenderBody_ColumnWidths(t *testing.T) {
+	column := func(id string) *notionapi.ColumnBlock {
+		return &notionapi.ColumnBlock{BasicBlock: notionapi.BasicBlock{ID: notionapi.BlockID(id), HasChildren: true}}
+	}
+	list := &notionapi.ColumnListBlock{BasicBlock: notionapi.BasicBlock{ID: "list", HasChildren: true}}
+	children := map[notionapi.BlockID][]notionapi.Block{
+		"list":  {column("left"), column("right")},
+		"left":  {paragraph("Narrow")},
+		"right": {paragraph("Wide")},
+	}
+	getChildren := func(id notionapi.BlockID) ([]notionapi.Block, error) {
+		return children[id], nil
+	}
+
+	config := DefaultRenderConfig()
+	config.ColumnsTemplate = "<div class=\"row\">\n{{.Content}}\n</div>"
+	config.ColumnTemplate = `<div style="width: {{.Width}}%">{{.Content}}</div>`
+	r := New(nil, t.TempDir(), config)
+	r.SetColumnWidthGetter(func(id notionapi.BlockID) (map[notionapi.BlockID]float64, error) {
+		if id != "list" {
+			t.Errorf("Expected the widths of the column list, got %s", id)
+		}
+		return map[notionapi.BlockID]float64{"left": 0.3, "right": 0.7}, nil
+	})
+	body, err := r.RenderBody(newTestPage("Columns"), []notionapi.Block{list}, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected := "<div class=\"row\">\n<div style=\"width: 30%\">Narrow</div>\n<div style=\"width: 70%\">Wide</div>\n</div>"
+	if body != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, body)
+	}
+}
+
+func TestRenderBody_Comments(t *testing.T) {
+	commented := paragraph("Ship on Friday")
+	commented.ID = "para"
+	code := &notionapi.CodeBlock{
+		BasicBlock: notionapi.BasicBlock{ID: "code"},
+		Code:       notionapi.Code{Language: "go", RichText: []notionapi.RichText{{PlainText: "ship()"}}},
+	}
+	comment := func(name, text string) notionapi.Comment {
+		return notionapi.Comment{CreatedBy: notionapi.User{Name: name}, RichText: []notionapi.RichText{{PlainText: text}}}
+	}
+	getComments := func(id notionapi.BlockID) ([]notionapi.Comment, error) {
+		switch id {
+		case "para":
+			return []notionapi.Comment{comment("Jane", "Too soon?"), comment("Max", "Fine by me")}, nil
+		case "code":
+			return []notionapi.Comment{comment("", "Needs a test -- really")}, nil
+		}
+		return nil, nil
+	}
+	blocks := []notionapi.Block{commented, code}
+
+	config := DefaultRenderConfig()
+	config.CommentStyle = CommentStyleFootnotes
+	r := New(nil, t.TempDir(), config)
+	r.SetCommentGetter(getComments)
+	body, err := r.RenderBody(newTestPage("Comments"), blocks, nil, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected := "Ship on Friday[^comment-1][^comment-2]\n\n" +
+		"```go\nship()\n```\n\n[^comment-3]\n\n" +
+		"[^comment-1]: Jane: Too soon?\n[^comment-2]: Max: Fine by me\n[^comment-3]: Needs a test -- really"
+	if body != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, body)
+	}
+
+	config.CommentStyle = CommentStyleHTML
+	body, err = r.RenderBody(newTestPage("Comments"), blocks, nil, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected = "Ship on Friday\n\n```go\nship()\n```\n\n" +
+		"<!--\nComments:\nJane: Too soon?\nMax: Fine by me\nNeeds a test - - really\n-->"
+	if body != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, body)
+	}
+
+	// Comments are not fetched unless enabled
+	config.CommentStyle = ""
+	r.SetCommentGetter(func(notionapi.BlockID) ([]notionapi.Comment, error) {
+		t.Fatal("Unexpected comment fetch")
+		return nil, nil
+	})
+	if _, err := r.RenderBody(newTestPage("Comments"), blocks, nil, nil, ""); err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+}
+
+func TestRenderBody_CaptionFootnotes(t *testing.T) {
+	image := func(caption string) *notionapi.ImageBlock {
+		return &notionapi.ImageBlock{Image: notionapi.Image{
+			External: &notionapi.FileObject{URL: "https://example.com/chart.png"},
+			Caption:  []notionapi.RichText{{PlainText: caption, Text: &notionapi.Text{Content: caption}}},
+		}}
+	}
+	long := "Revenue per quarter, adjusted for inflation and excluding one-off sales"
+	blocks := []notionapi.Block{image("Short caption"), image(long)}
+
+	config := DefaultRenderConfig()
+	config.CaptionFootnoteLength = 40
+	body, err := New(nil, t.TempDir(), config).RenderBody(newTestPage("Footnotes"), blocks, nil, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected := "![Short caption](https://example.com/chart.png)\n\n" +
+		"![example.com/.../chart.png](https://example.com/chart.png)[^1]\n\n" +
+		"[^1]: " + long
+	if body != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, body)
+	}
+}
+
+// tableBlocks returns a table block with the given rows and a getChildren
+// function serving them
+func tableBlocks(table notionapi.Table, rows ...[]string) (*notionapi.TableBlock, func(notionapi.BlockID) ([]notionapi.Block, error)) {
+	block := &notionapi.TableBlock{BasicBlock: notionapi.BasicBlock{ID: "table", HasChildren: true}, Table: table}
+	children := make([]notionapi.Block, 0, len(rows))
+	for _, row := range rows {
+		cells := make([][]notionapi.RichText, len(row))
+		for i, text := range row {
+			if text != "" {
+				cells[i] = []notionapi.RichText{{PlainText: text}}
+			}
+		}
+		children = append(children, &notionapi.TableRowBlock{TableRow: notionapi.TableRow{Cells: cells}})
+	}
+	return block, func(id notionapi.BlockID) ([]notionapi.Block, error) {
+		if id == "table" {
+			return children, nil
+		}
+		return nil, nil
+	}
+}
+
+func TestRenderBody_EmptyTableHeader(t *testing.T) {
+	table, getChildren := tableBlocks(notionapi.Table{TableWidth: 3, HasColumnHeader: true},
+		[]string{"", "", ""},
+		[]string{"a", "", "c"},
+	)
+	body, err := New(nil, t.TempDir(), nil).RenderBody(newTestPage("Table"), []notionapi.Block{table}, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected := "| Col1 | Col2 | Col3 |\n| --- | --- | --- |\n| a |  | c |"
+	if body != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, body)
+	}
+}
+
+func TestRenderBody_TableRowHeader(t *testing.T) {
+	table, getChildren := tableBlocks(notionapi.Table{TableWidth: 3, HasColumnHeader: true, HasRowHeader: true},
+		[]string{"", "Q1", "Q2"},
+		[]string{"Revenue", "10", "12"},
+		[]string{"**Costs**", "8", "9"},
+		[]string{"", "1", "2"},
+	)
+	body, err := New(nil, t.TempDir(), nil).RenderBody(newTestPage("Table"), []notionapi.Block{table}, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected := "|  | Q1 | Q2 |\n| --- | --- | --- |\n| **Revenue** | 10 | 12 |\n| **Costs** | 8 | 9 |\n|  | 1 | 2 |"
+	if body != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, body)
+	}
+}
+
+func TestRenderBody_CachesChildren(t *testing.T) {
+	toggle := func(id string) *notionapi.ToggleBlock {
+		return &notionapi.ToggleBlock{
+			BasicBlock: notionapi.BasicBlock{ID: notionapi.BlockID(id), HasChildren: true},
+			Toggle:     notionapi.Toggle{RichText: []notionapi.RichText{{PlainText: "Details"}}},
+		}
+	}
+	fetches := map[notionapi.BlockID]int{}
+	getChildren := func(id notionapi.BlockID) ([]notionapi.Block, error) {
+		fetches[id]++
+		if id == "outer" {
+			return []notionapi.Block{toggle("shared")}, nil
+		}
+		return []notionapi.Block{paragraph("Shared content")}, nil
+	}
+
+	r := New(nil, t.TempDir(), nil)
+	blocks := []notionapi.Block{toggle("outer"), toggle("shared")}
+	first, err := r.RenderBody(newTestPage("First"), blocks, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	second, err := r.RenderBody(newTestPage("Second"), blocks, getChildren, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	if first != second {
+		t.Errorf("Expected identical output from cached children, got:\n%s\nand:\n%s", first, second)
+	}
+	for id, n := range fetches {
+		if n != 1 {
+			t.Errorf("Expected the children of %s to be fetched once, got %d fetches", id, n)
+		}
+	}
+	if len(fetches) != 2 {
+		t.Errorf("Expected fetches for 2 blocks, got %v", fetches)
+	}
+}
+
+func TestRenderBody_BodyProperties(t *testing.T) {
+	start := notionapi.Date(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+	page := newTestPage("Release Notes")
+	page.Properties["Author"] = &notionapi.RichTextProperty{RichText: []notionapi.RichText{{PlainText: "Jane | Joe"}}}
+	page.Properties["Tags"] = &notionapi.MultiSelectProperty{MultiSelect: []notionapi.Option{{Name: "go"}, {Name: "notion"}}}
+	page.Properties["Due"] = &notionapi.DateProperty{Date: &notionapi.DateObject{Start: &start}}
+	page.Properties["Reviewer"] = &notionapi.RichTextProperty{}
+	blocks := []notionapi.Block{paragraph("Hello")}
+
+	config := DefaultRenderConfig()
+	config.BodyProperties = []string{"tags", "Author", "Reviewer", "Due", "Missing"}
+	body, err := New(nil, t.TempDir(), config).RenderBody(page, blocks, nil, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected := "| Tags | Author | Due |\n| --- | --- | --- |\n| go, notion | Jane \\| Joe | 2025-03-01 |\n\nHello"
+	if body != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, body)
+	}
+
+	config.BodyPropertiesStyle = BodyPropertiesDefinitions
+	body, err = New(nil, t.TempDir(), config).RenderBody(page, blocks, nil, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error rendering body: %v", err)
+	}
+	expected = "Tags\n: go, notion\n\nAuthor\n: Jane | Joe\n\nDue\n: 2025-03-01\n\nHello"
+	if body != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, body)
+	}
+
+	// Empty pages stay empty
+	if body, _ := New(nil, t.TempDir(), config).RenderBody(page, nil, nil, nil, ""); body != "" {
+		t.Errorf("Expected an empty body, got:\n%s", body)
+	}
+}
@@ -0,0 +1,296 @@
+package renderer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultHTMLAllowlist lists the HTML tags, and the attributes of each,
+// kept by SanitizeHTML when no HTMLAllowlist is configured. Attributes under
+// "*" are allowed on every tag.
+var DefaultHTMLAllowlist = map[string][]string{
+	"*":          {"class", "id", "title"},
+	"a":          {"href"},
+	"b":          nil,
+	"blockquote": nil,
+	"br":         nil,
+	"code":       nil,
+	"del":        nil,
+	"details":    {"open"},
+	"div":        nil,
+	"em":         nil,
+	"figcaption": nil,
+	"figure":     nil,
+	"hr":         nil,
+	"i":          nil,
+	"iframe":     {"src", "width", "height", "allowfullscreen"},
+	"img":        {"src", "alt", "width", "height"},
+	"kbd":        nil,
+	"li":         nil,
+	"mark":       nil,
+	"ol":         {"start"},
+	"p":          nil,
+	"pre":        nil,
+	"s":          nil,
+	"source":     {"src", "type"},
+	"span":       nil,
+	"strong":     nil,
+	"sub":        nil,
+	"summary":    nil,
+	"sup":        nil,
+	"table":      nil,
+	"tbody":      nil,
+	"td":         {"colspan", "rowspan"},
+	"th":         {"colspan", "rowspan"},
+	"thead":      nil,
+	"tr":         nil,
+	"u":          nil,
+	"ul":         nil,
+	"video":      {"src", "controls", "width", "height", "poster"},
+}
+
+var (
+	// Elements removed together with their content
+	htmlDropTags      = map[string]bool{"script": true, "style": true}
+	inlineCodePattern = regexp.MustCompile("`+[^`\n]*`+")
+	autolinkPattern   = regexp.MustCompile(`<(?:[a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*|[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	linkDefPattern    = regexp.MustCompile(`(?m)^( {0,3}\[[^\]\n]+\]:[ \t]*)(<[^>\n]*>|\S+)`)
+)
+
+// urlAttributes are checked for script URLs
+var urlAttributes = map[string]bool{"href": true, "src": true, "poster": true}
+
+// sanitizeHTML removes HTML tags and attributes that are not in allowlist
+// from markdown. Script and style elements are removed with their content,
+// and script URLs are removed from attributes and Markdown links. Fenced and
+// inline code is left untouched, since it is displayed rather than
+// interpreted.
+func sanitizeHTML(markdown string, allowlist map[string][]string) string {
+	if allowlist == nil {
+		allowlist = DefaultHTMLAllowlist
+	}
+	text, code := protectCode(markdown)
+	// Autolinks look like tags to an HTML tokenizer, so set them aside too
+	text = autolinkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		if unsafeURL(link[1 : len(link)-1]) {
+			return ""
+		}
+		code = append(code, link)
+		return codePlaceholder(len(code) - 1)
+	})
+	text = sanitizeLinkTargets(text)
+	text = sanitizeTags(text, allowlist)
+	for i, c := range code {
+		text = strings.Replace(text, codePlaceholder(i), c, 1)
+	}
+	return text
+}
+
+// sanitizeTags tokenizes text the way a browser would and rebuilds every tag
+// with only its allowed attributes, dropping tags that are not allowed.
+// Text between tags is kept as written.
+func sanitizeTags(text string, allowlist map[string][]string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(text))
+	dropping := ""
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			// Keep a trailing unterminated tag as text, escaped so it cannot
+			// become one
+			b.WriteString(strings.ReplaceAll(string(z.Raw()), "<", "&lt;"))
+			return b.String()
+		}
+		raw := string(z.Raw())
+		tok := z.Token()
+		if dropping != "" {
+			if tt == html.EndTagToken && tok.Data == dropping {
+				dropping = ""
+			}
+			continue
+		}
+		switch tt {
+		case html.TextToken:
+			b.WriteString(raw)
+		case html.CommentToken:
+			// Only real comments; bogus ones such as <?...> are dropped
+			if strings.HasPrefix(raw, "<!--") {
+				b.WriteString(raw)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if htmlDropTags[tok.Data] {
+				if tt == html.StartTagToken {
+					dropping = tok.Data
+				}
+				continue
+			}
+			// Sanitize the content of textarea, title and the like as markup
+			z.NextIsNotRawText()
+			b.WriteString(sanitizeTag(tok, tt == html.SelfClosingTagToken, allowlist))
+		case html.EndTagToken:
+			if _, ok := allowlist[tok.Data]; ok {
+				b.WriteString("</" + tok.Data + ">")
+			}
+		}
+	}
+}
+
+// sanitizeTag rebuilds tok with only its allowed attributes, or drops it
+func sanitizeTag(tok html.Token, selfClosing bool, allowlist map[string][]string) string {
+	allowedAttrs, ok := allowlist[tok.Data]
+	if !ok {
+		return ""
+	}
+	allowed := func(attr string) bool {
+		return containsFold(allowedAttrs, attr) || containsFold(allowlist["*"], attr)
+	}
+	var b strings.Builder
+	b.WriteString("<" + tok.Data)
+	for _, a := range tok.Attr {
+		if a.Namespace != "" || !allowed(a.Key) {
+			continue
+		}
+		if urlAttributes[a.Key] && unsafeURL(a.Val) {
+			continue
+		}
+		if a.Val == "" {
+			b.WriteString(" " + a.Key)
+			continue
+		}
+		b.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+	}
+	if selfClosing {
+		b.WriteString(" /")
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// sanitizeLinkTargets empties the targets of Markdown links, images and
+// link reference definitions that would run script.
+func sanitizeLinkTargets(text string) string {
+	text = linkDefPattern.ReplaceAllStringFunc(text, func(def string) string {
+		m := linkDefPattern.FindStringSubmatch(def)
+		if unsafeURL(strings.Trim(m[2], "<>")) {
+			return m[1] + "<>"
+		}
+		return def
+	})
+
+	var b strings.Builder
+	for {
+		i := strings.Index(text, "](")
+		if i < 0 {
+			b.WriteString(text)
+			return b.String()
+		}
+		b.WriteString(text[:i+2])
+		text = text[i+2:]
+		start := len(text) - len(strings.TrimLeft(text, " \t\n"))
+		end := linkDestinationEnd(text, start)
+		if unsafeURL(text[start:end]) {
+			b.WriteString(text[:start])
+			text = text[end:]
+		}
+	}
+}
+
+// linkDestinationEnd returns the index just past the Markdown link
+// destination starting at text[start]: either <...> or a run of
+// non-space characters with balanced parentheses.
+func linkDestinationEnd(text string, start int) int {
+	if strings.HasPrefix(text[start:], "<") {
+		if j := strings.IndexAny(text[start:], ">\n"); j >= 0 && text[start+j] == '>' {
+			return start + j + 1
+		}
+	}
+	depth := 0
+	for i := start; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text):
+			i++
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case c <= ' ':
+			return i
+		}
+	}
+	return len(text)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// unsafeURL reports whether a link target would run script. Entities and
+// backslash escapes are decoded and whitespace and control characters
+// removed first, as browsers and Markdown parsers do.
+func unsafeURL(value string) bool {
+	value = strings.Trim(value, "<>")
+	value = strings.ReplaceAll(value, "\\", "")
+	// Decode twice so an entity hidden behind &amp; is caught as well
+	value = html.UnescapeString(html.UnescapeString(value))
+	v := strings.Map(func(r rune) rune {
+		if r <= ' ' || unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, strings.ToLower(value))
+	return strings.HasPrefix(v, "javascript:") || strings.HasPrefix(v, "vbscript:") ||
+		(strings.HasPrefix(v, "data:") && !strings.HasPrefix(v, "data:image/"))
+}
+
+// protectCode replaces fenced code blocks and inline code spans with
+// placeholders, returning the text and the code they stand for.
+func protectCode(markdown string) (string, []string) {
+	var code []string
+	lines := strings.Split(markdown, "\n")
+	out := make([]string, 0, len(lines))
+	var block []string
+	fence := ""
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if fence != "" {
+			block = append(block, line)
+			if strings.HasPrefix(trimmed, fence) {
+				out = append(out, codePlaceholder(len(code)))
+				code = append(code, strings.Join(block, "\n"))
+				block, fence = nil, ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			block = []string{line}
+			continue
+		}
+		out = append(out, inlineCodePattern.ReplaceAllStringFunc(line, func(span string) string {
+			code = append(code, span)
+			return codePlaceholder(len(code) - 1)
+		}))
+	}
+	// An unterminated fence runs to the end of the document
+	if fence != "" {
+		out = append(out, codePlaceholder(len(code)))
+		code = append(code, strings.Join(block, "\n"))
+	}
+	return strings.Join(out, "\n"), code
+}
+
+func codePlaceholder(i int) string {
+	return fmt.Sprintf("\x00code%d\x00", i)
+}
@@ -5,11 +5,11 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 
-	"github.com/ManassehZhou/notion-to-markdown/internal/notionclient"
-	"github.com/ManassehZhou/notion-to-markdown/internal/renderer"
-	"github.com/ManassehZhou/notion-to-markdown/internal/writer"
+	"github.com/ManassehZhou/notion-to-markdown/converter"
 
 	"github.com/jomei/notionapi"
 )
@@ -31,6 +31,56 @@ func newLogger(level slog.Level) *slog.Logger {
 	}))
 }
 
+// commandTransformer returns a converter.Transformer that pipes each page body
+// through a shell command and uses its stdout as the new body.
+func commandTransformer(command string) converter.Transformer {
+	return func(page notionapi.Page, markdown string) (string, error) {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdin = strings.NewReader(markdown)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("transform command failed for page %s: %w", page.ID, err)
+		}
+		return string(out), nil
+	}
+}
+
+// isWithin reports whether path is dir or lies inside it
+func isWithin(path, dir string) bool {
+	absPath, err1 := filepath.Abs(path)
+	absDir, err2 := filepath.Abs(dir)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// listFlag collects the values of a flag that may be repeated, each of
+// which may also be a comma-separated list.
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(value string) error {
+	*f = append(*f, splitList(value)...)
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func main() {
 	// Setup structured logging
 	logger := newLogger(slog.LevelInfo)
@@ -40,9 +90,18 @@ func main() {
 
 	// CLI flags with environment fallbacks
 	tokenFlag := flag.String("token", "", "Notion integration token (or set NOTION_TOKEN)")
-	dbFlag := flag.String("database", "", "Notion database ID (or set NOTION_DATABASE_ID)")
+	var dbFlag listFlag
+	flag.Var(&dbFlag, "database", "Notion database ID, repeatable or comma-separated (or set NOTION_DATABASE_ID)")
+	pageFlag := flag.String("page", "", "Notion page ID to export with its child pages instead of a database (or set NOTION_PAGE_ID)")
+	publishedFlag := flag.String("published-property", "", "Only export pages whose checkbox property of this name is ticked, e.g. Published")
 	outFlag := flag.String("out", "content", "Output directory for generated markdown files")
-	configFlag := flag.String("config", "config/notion-to-markdown.yaml", "Path to YAML configuration file")
+	configFlag := flag.String("config", "config/notion-to-markdown.yaml", "Path to YAML configuration file (\"-\" reads from stdin)")
+	presetFlag := flag.String("preset", "", "Built-in preset to start the configuration from ("+strings.Join(converter.PresetNames(), ", ")+")")
+	singleFileFlag := flag.String("single-file", "", "Write all pages into this one Markdown file (relative to -out)")
+	transformCmdFlag := flag.String("transform-cmd", "", "Shell command each page body is piped through before writing")
+	fixturesFlag := flag.String("fixtures", "", "Directory API responses are replayed from, recording any that are missing (no token needed to replay)")
+	cleanFlag := flag.Bool("clean", false, "Remove the output directory before writing")
+	strictAssetsFlag := flag.Bool("strict-assets", false, "Fail when a Notion file cannot be downloaded")
 	verboseFlag := flag.Bool("verbose", false, "Enable verbose logging")
 	versionFlag := flag.Bool("version", false, "Show version information")
 	flag.Parse()
@@ -59,9 +118,13 @@ func main() {
 	if notionToken == "" {
 		notionToken = os.Getenv("NOTION_TOKEN")
 	}
-	databaseID := *dbFlag
-	if databaseID == "" {
-		databaseID = os.Getenv("NOTION_DATABASE_ID")
+	databaseIDs := []string(dbFlag)
+	if len(databaseIDs) == 0 {
+		databaseIDs = splitList(os.Getenv("NOTION_DATABASE_ID"))
+	}
+	pageID := *pageFlag
+	if pageID == "" {
+		pageID = os.Getenv("NOTION_PAGE_ID")
 	}
 	outDir := *outFlag
 	configPath := *configFlag
@@ -78,119 +141,103 @@ func main() {
 		slog.SetDefault(logger)
 	}
 
-	if notionToken == "" || databaseID == "" {
+	if notionToken == "" && *fixturesFlag == "" || len(databaseIDs) == 0 && pageID == "" {
 		slog.Error("❌ Error: Missing required parameters")
-		slog.Info("Usage: notion-to-markdown -token TOKEN -database DATABASE_ID [-out DIR] [-config CONFIG.yaml]")
-		slog.Info("You can also set NOTION_TOKEN and NOTION_DATABASE_ID environment variables.")
+		slog.Info("Usage: notion-to-markdown -token TOKEN (-database DATABASE_ID | -page PAGE_ID) [-out DIR] [-config CONFIG.yaml]")
+		slog.Info("You can also set NOTION_TOKEN and NOTION_DATABASE_ID (or NOTION_PAGE_ID) environment variables.")
 		os.Exit(1)
 	}
 
 	if verbose {
 		slog.Debug("📂 Output directory", "path", outDir)
 		slog.Debug("⚙️ Config file", "path", configPath)
-		slog.Debug("🗄️ Database ID", "id", databaseID)
+		if pageID != "" {
+			slog.Debug("📄 Page ID", "id", pageID)
+		} else {
+			slog.Debug("🗄️ Database IDs", "ids", databaseIDs)
+		}
 	}
 
-	nc := notionclient.New(notionToken)
-	// We'll build a resolver map from the database pages so internal Notion links
-	// can be converted to site-relative Hugo links.
-	w := writer.New()
-
 	// Load render configuration from YAML file
 	if verbose {
 		slog.Debug("📄 Loading configuration", "path", configPath)
 	}
-	config := renderer.LoadConfigWithFallback(configPath)
-
-	if verbose {
-		slog.Info("🔄 Fetching pages from Notion database...")
-	}
-	pages, err := nc.FetchPages(databaseID)
+	config, configUsed, err := converter.LoadConfigWithPreset(configPath, *presetFlag)
 	if err != nil {
-		slog.Error("❌ Failed to query Notion database", "error", err)
+		slog.Error("❌ Invalid preset", "error", err)
 		os.Exit(1)
 	}
-
-	if verbose {
-		slog.Info("📊 Found pages in database", "count", len(pages))
-		if len(pages) > 100 {
-			slog.Warn("Large number of pages detected, processing may take time", "count", len(pages))
-		}
-	}
-
-	// build page ID -> path map for resolver
 	if verbose {
-		slog.Info("🔗 Building page resolver map...")
-	}
-	pageMap := map[string]string{}
-	resolve := func(pageID string) string {
-		if path, ok := pageMap[pageID]; ok {
-			return path
+		if configUsed != "" {
+			slog.Debug("⚙️ Using configuration", "path", configUsed)
+		} else {
+			slog.Debug("⚙️ Using default configuration")
 		}
-		return ""
-	}
-	r := renderer.New(resolve, outDir, config)
-
-	for _, p := range pages {
-		// Get the full path including content type, not just slug
-		path := r.GetPagePath(p)
-
-		// Use page ID and normalize it by removing dashes
-		normalizedID := strings.ReplaceAll(string(p.ID), "-", "")
-		pageMap[normalizedID] = path
 	}
 
-	// Update renderer with the resolver
-	filesGenerated := 0
-
-	if verbose {
-		slog.Info("📝 Converting pages to Markdown...")
-	}
-
-	for i, p := range pages {
-		if verbose {
-			slog.Debug("Processing page", "current", i+1, "total", len(pages))
-		}
-
-		// Fetch top-level blocks for the page (convert ObjectID to BlockID)
-		blocks, err := nc.GetChildren(notionapi.BlockID(p.ID))
-		if err != nil {
-			slog.Error("❌ Failed to fetch page blocks", "error", err)
+	if *cleanFlag {
+		// The download cache must survive cleaning, or every file would be
+		// downloaded again
+		if config.CacheDir != "" && isWithin(config.CacheDir, outDir) {
+			slog.Error("❌ Refusing to clean the output directory: it contains cache_dir", "out", outDir, "cache_dir", config.CacheDir)
 			os.Exit(1)
 		}
-		filename, content, err := r.RenderPage(p, blocks, nc.GetChildren, resolve)
-		if err != nil {
-			slog.Error("❌ Failed to render page", "error", err)
-			os.Exit(1)
-		}
-		// ensure we write into the requested output directory
-		// if filename already contains a top-level path like "posts/..." we keep it,
-		// otherwise prefix with outDir
-		finalPath := filename
-		if outDir != "" && !strings.HasPrefix(filename, outDir+"/") {
-			finalPath = outDir + "/" + filename
-		}
-
-		if err := w.WriteFile(finalPath, content); err != nil {
-			slog.Error("❌ Failed to write file", "error", err)
+		slog.Debug("🧹 Cleaning output directory", "path", outDir)
+		if err := converter.CleanOutputDir(outDir); err != nil {
+			slog.Error("❌ Failed to clean output directory", "error", err)
 			os.Exit(1)
 		}
+	}
 
-		if verbose {
-			slog.Info("✅ Generated file", "path", finalPath)
-		} else {
-			// Print progress dot for non-verbose mode
-			print(".")
-		}
-		filesGenerated++
+	var transforms []converter.Transformer
+	if *transformCmdFlag != "" {
+		transforms = append(transforms, commandTransformer(*transformCmdFlag))
 	}
 
+	// Print progress dots unless every generated file is logged
+	var progress converter.ProgressReporter = converter.NopProgress{}
 	if !verbose {
-		println() // New line after dots
+		progress = converter.NewTerminalProgress(os.Stdout)
+	}
+
+	// The converter builds a resolver map from the database pages so internal
+	// Notion links can be converted to site-relative links.
+	var clientOpts []converter.ClientOption
+	if *publishedFlag != "" {
+		clientOpts = append(clientOpts, converter.WithPublishedProperty(*publishedFlag))
+	}
+	var client converter.Client
+	if *fixturesFlag != "" {
+		client = converter.NewRecordingClient(notionToken, *fixturesFlag, clientOpts...)
+	} else {
+		client = converter.NewClient(notionToken, clientOpts...)
+	}
+	conv := converter.New(client, converter.Options{
+		OutDir:       outDir,
+		Config:       config,
+		Transformers: transforms,
+		StrictAssets: *strictAssetsFlag,
+		Progress:     progress,
+		SingleFile:   *singleFileFlag,
+	})
+
+	var filesGenerated int
+	if pageID != "" {
+		filesGenerated, err = conv.ConvertPageTree(pageID)
+	} else {
+		filesGenerated, err = conv.ConvertDatabases(databaseIDs)
+	}
+	if err != nil {
+		slog.Error("❌ Conversion failed", "error", err)
+		os.Exit(1)
 	}
 
 	slog.Info("🎉 Successfully generated markdown files", "count", filesGenerated, "directory", outDir)
 
+	if failures := conv.AssetFailures(); len(failures) > 0 {
+		slog.Warn("⚠️ Some files could not be downloaded and link to Notion instead", "count", len(failures))
+	}
+
 	// Warn about large numbers of files
 	if filesGenerated > 50 {
 		slog.Warn("Large number of files generated, check repository size limits", "count", filesGenerated)